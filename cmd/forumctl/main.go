@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"forum/config"
+	"forum/pkg/database"
+)
+
+const usage = `usage: forumctl <command> [flags]
+
+commands:
+  migrate up              apply pending migrations
+  migrate down [-steps N] roll back the last N migrations (default 1)
+  migrate status          list migrations and whether they are applied
+  role <username> <role>  set role: user, moderator or administrator
+  votes reconcile [-dry-run]
+                          recompute vote counters from the votes table and
+                          report the ones that drifted
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cnf, err := config.New()
+	if err != nil {
+		log.Fatalf("Init Config Error: %v\n", err)
+	}
+
+	db, err := database.InitDB(cnf)
+	if err != nil {
+		log.Fatalf("forumctl: init db: %v\n", err)
+	}
+	defer db.Close()
+
+	switch os.Args[1] {
+	case "migrate":
+		err = migrate(db, os.Args[2:])
+	case "role":
+		err = role(db, cnf, os.Args[2:])
+	case "votes":
+		err = votes(db, cnf, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("forumctl: %s: %v\n", os.Args[1], err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+
+	"forum/migrations"
+	"forum/pkg/database"
+)
+
+func migrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing subcommand: up, down or status")
+	}
+
+	switch args[0] {
+	case "up":
+		return database.Migrate(db, migrations.FS)
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "number of migrations to roll back, at least 1")
+		fs.Parse(args[1:])
+		return database.Rollback(db, migrations.FS, *steps)
+	case "status":
+		statuses, err := database.Status(db, migrations.FS)
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
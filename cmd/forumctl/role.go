@@ -0,0 +1,38 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"forum/config"
+	"forum/internal/entity"
+	"forum/internal/repository"
+)
+
+// role sets the role of a user, it is the way to appoint the first administrator
+func role(db *sql.DB, cnf *config.Config, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: forumctl role <username> <user|moderator|administrator>")
+	}
+	username, newRole := args[0], args[1]
+
+	if !entity.ValidRole(newRole) {
+		return fmt.Errorf("unknown role %q", newRole)
+	}
+
+	repo := repository.NewRepository(db, cnf)
+
+	user, err := repo.Authorization.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	return repo.Moderation.SetUserRole(user.Username, newRole, entity.AuditEntry{
+		Actor:      "forumctl",
+		Action:     entity.AuditSetRole,
+		TargetType: "user",
+		TargetId:   user.Username,
+		Details:    fmt.Sprintf("%s -> %s", user.Role, newRole),
+	})
+}
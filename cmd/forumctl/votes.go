@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"forum/config"
+	"forum/internal/entity"
+	"forum/internal/repository"
+)
+
+// votes reconcile recomputes the likes and dislikes counters of posts and
+// comments from the votes table and prints every counter that had drifted
+func votes(db *sql.DB, cnf *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "reconcile" {
+		return errors.New("usage: forumctl votes reconcile [-dry-run] [-timeout 10m]")
+	}
+
+	fs := flag.NewFlagSet("votes reconcile", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only report the drift, leave the counters as they are")
+	timeout := fs.Duration("timeout", 10*time.Minute, "give up and change nothing after this long, 0 for no limit")
+	fs.Parse(args[1:])
+
+	// a large database takes longer than a request may, ^C rolls back
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	repo := repository.NewRepository(db, cnf)
+
+	drifts, err := repo.PostVoter.ReconcileVotes(ctx, !*dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range drifts {
+		fmt.Printf("%s %d\tlikes %d -> %d\tdislikes %d -> %d\n",
+			d.TargetType, d.TargetId, d.StoredLikes, d.Likes, d.StoredDislikes, d.Dislikes)
+	}
+
+	if *dryRun {
+		fmt.Printf("%d counters drifted, nothing changed\n", len(drifts))
+		return nil
+	}
+	fmt.Printf("%d counters drifted and were fixed\n", len(drifts))
+
+	if len(drifts) == 0 {
+		return nil
+	}
+
+	return repo.Moderation.CreateAuditEntry(entity.AuditEntry{
+		Actor:      "forumctl",
+		Action:     entity.AuditReconcileVotes,
+		TargetType: "votes",
+		Details:    fmt.Sprintf("%d counters fixed", len(drifts)),
+	})
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -11,6 +12,32 @@ const (
 	maxHeader  = 1 >> 20
 	writeTO    = 5 * time.Second
 	shutDownTO = 3 * time.Second
+
+	defaultUploadDir     = "./uploads"
+	defaultMaxUploadSize = 5 << 20
+
+	defaultMailer   = "file"
+	defaultMailDir  = "./mail"
+	defaultMailFrom = "forum@localhost"
+	defaultSMTPPort = 587
+
+	defaultCookieSameSite  = "lax"
+	defaultSessionTTLHours = 12
+	defaultSessionMaxHours = 30 * 24
+
+	defaultRateLimitStore   = "memory"
+	defaultAuthBurst        = 5
+	defaultAuthEverySeconds = 12
+	defaultLockoutFailures  = 10
+	defaultLockoutMinutes   = 15
+
+	defaultTOTPIssuer = "Forum"
+
+	defaultPasswordHasher  = "bcrypt"
+	defaultBcryptCost      = 14
+	defaultArgon2Time      = 3
+	defaultArgon2MemoryKiB = 64 * 1024
+	defaultArgon2Threads   = 2
 )
 
 type Config struct {
@@ -23,6 +50,86 @@ type Config struct {
 	DbNameAndPath   string `json:"dbNameAndPath"`
 	DbDriver        string `json:"dbDriver"`
 	CtxTimeout      int    `json:"ctxTimeout"`
+	// CommentMaxDepth is how deep replies nest before they are shown flat
+	CommentMaxDepth int `json:"commentMaxDepth"`
+	// CommentCollapseAfter hides replies after the first N behind a toggle
+	CommentCollapseAfter int `json:"commentCollapseAfter"`
+	// PageSize is how many posts a feed page shows unless the client asks for a limit
+	PageSize int `json:"pageSize"`
+	// UploadDir keeps post attachments, MaxUploadSize limits one file in bytes
+	UploadDir      string `json:"uploadDir"`
+	MaxUploadSize  int64  `json:"maxUploadSize"`
+	MaxAttachments int    `json:"maxAttachments"`
+	// BaseURL is the public address of the forum used in links sent by email
+	BaseURL string `json:"baseUrl"`
+	// Mailer is "smtp" or "file", the file mailer stores messages in MailDir
+	// or writes them to the log when MailDir is empty
+	Mailer       string `json:"mailer"`
+	MailFrom     string `json:"mailFrom"`
+	MailDir      string `json:"mailDir"`
+	SMTPHost     string `json:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"smtpPassword"`
+	// OAuthProviders enable sign-in with GitHub, Google or another OpenID
+	// Connect provider, a provider without a client id is disabled
+	OAuthProviders []OAuthProvider `json:"oauthProviders"`
+	// CookieSecure sends the session cookie over https only, it is always on
+	// when BaseURL is https. CookieSameSite is "lax", "strict" or "none".
+	CookieSecure   bool   `json:"cookieSecure"`
+	CookieSameSite string `json:"cookieSameSite"`
+	CookieDomain   string `json:"cookieDomain"`
+	// SessionTTLHours is how long a session lasts after it was last used,
+	// SessionMaxHours how long it lasts at most after the sign-in
+	SessionTTLHours int `json:"sessionTtlHours"`
+	SessionMaxHours int `json:"sessionMaxHours"`
+	// RateLimitStore is "memory" or "sqlite". Sign-in and sign-up allow
+	// AuthBurst attempts per IP and per username at once and one more every
+	// AuthEverySeconds, LockoutFailures failed sign-ins in a row lock the
+	// username and the IP for LockoutMinutes.
+	RateLimitStore   string `json:"rateLimitStore"`
+	AuthBurst        int    `json:"authBurst"`
+	AuthEverySeconds int    `json:"authEverySeconds"`
+	LockoutFailures  int    `json:"lockoutFailures"`
+	LockoutMinutes   int    `json:"lockoutMinutes"`
+	// TOTPIssuer names the forum in authenticator apps
+	TOTPIssuer string `json:"totpIssuer"`
+	// PasswordPolicy is checked when a password is set, without it a password
+	// is 6 to 20 characters with an upper case letter, a number and a symbol
+	PasswordPolicy *PasswordPolicy `json:"passwordPolicy"`
+	// PasswordHasher is "bcrypt" with BcryptCost or "argon2id" with
+	// Argon2Time passes over Argon2MemoryKiB of memory in Argon2Threads
+	// threads. A password hashed otherwise is hashed again at the next sign-in.
+	PasswordHasher  string `json:"passwordHasher"`
+	BcryptCost      int    `json:"bcryptCost"`
+	Argon2Time      int    `json:"argon2Time"`
+	Argon2MemoryKiB int    `json:"argon2MemoryKiB"`
+	Argon2Threads   int    `json:"argon2Threads"`
+}
+
+// PasswordPolicy configures the rules for new passwords. CommonPasswords is
+// a file of common or breached passwords, one per line, that are refused.
+type PasswordPolicy struct {
+	MinLength       int    `json:"minLength"`
+	MaxLength       int    `json:"maxLength"`
+	RequireUpper    bool   `json:"requireUpper"`
+	RequireLower    bool   `json:"requireLower"`
+	RequireDigit    bool   `json:"requireDigit"`
+	RequireSymbol   bool   `json:"requireSymbol"`
+	CommonPasswords string `json:"commonPasswords"`
+}
+
+// OAuthProvider configures one OAuth2 provider. Empty endpoints and scopes
+// use the defaults of "github" and "google", other providers need them all.
+type OAuthProvider struct {
+	Name         string   `json:"name"`
+	ClientID     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	AuthURL      string   `json:"authUrl"`
+	TokenURL     string   `json:"tokenUrl"`
+	UserInfoURL  string   `json:"userInfoUrl"`
+	EmailsURL    string   `json:"emailsUrl"`
+	Scopes       []string `json:"scopes"`
 }
 
 func New() (*Config, error) {
@@ -36,6 +143,83 @@ func New() (*Config, error) {
 	}
 	// defer configFile.Close()
 
+	if config.UploadDir == "" {
+		config.UploadDir = defaultUploadDir
+	}
+	if config.MaxUploadSize <= 0 {
+		config.MaxUploadSize = defaultMaxUploadSize
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "http://localhost:" + config.Port
+	}
+	if config.Mailer == "" {
+		config.Mailer = defaultMailer
+		config.MailDir = defaultMailDir
+	}
+	if config.MailFrom == "" {
+		config.MailFrom = defaultMailFrom
+	}
+	if config.SMTPPort == 0 {
+		config.SMTPPort = defaultSMTPPort
+	}
+	if strings.HasPrefix(config.BaseURL, "https://") {
+		config.CookieSecure = true
+	}
+	if config.CookieSameSite == "" {
+		config.CookieSameSite = defaultCookieSameSite
+	}
+	if config.SessionTTLHours <= 0 {
+		config.SessionTTLHours = defaultSessionTTLHours
+	}
+	if config.SessionMaxHours <= 0 {
+		config.SessionMaxHours = defaultSessionMaxHours
+	}
+	if config.SessionMaxHours < config.SessionTTLHours {
+		config.SessionMaxHours = config.SessionTTLHours
+	}
+	if config.RateLimitStore == "" {
+		config.RateLimitStore = defaultRateLimitStore
+	}
+	if config.AuthBurst <= 0 {
+		config.AuthBurst = defaultAuthBurst
+	}
+	if config.AuthEverySeconds <= 0 {
+		config.AuthEverySeconds = defaultAuthEverySeconds
+	}
+	if config.LockoutFailures <= 0 {
+		config.LockoutFailures = defaultLockoutFailures
+	}
+	if config.LockoutMinutes <= 0 {
+		config.LockoutMinutes = defaultLockoutMinutes
+	}
+	if config.TOTPIssuer == "" {
+		config.TOTPIssuer = defaultTOTPIssuer
+	}
+	if config.PasswordPolicy == nil {
+		config.PasswordPolicy = &PasswordPolicy{
+			MinLength:     6,
+			MaxLength:     20,
+			RequireUpper:  true,
+			RequireDigit:  true,
+			RequireSymbol: true,
+		}
+	}
+	if config.PasswordHasher == "" {
+		config.PasswordHasher = defaultPasswordHasher
+	}
+	if config.BcryptCost <= 0 {
+		config.BcryptCost = defaultBcryptCost
+	}
+	if config.Argon2Time <= 0 {
+		config.Argon2Time = defaultArgon2Time
+	}
+	if config.Argon2MemoryKiB <= 0 {
+		config.Argon2MemoryKiB = defaultArgon2MemoryKiB
+	}
+	if config.Argon2Threads <= 0 {
+		config.Argon2Threads = defaultArgon2Threads
+	}
+
 	return &Config{
 		Port:            config.Port,
 		MaxHeaderBytes:  maxHeader,
@@ -45,5 +229,45 @@ func New() (*Config, error) {
 		DbNameAndPath:   config.DbNameAndPath,
 		DbDriver:        config.DbDriver,
 		CtxTimeout:      config.CtxTimeout,
+
+		CommentMaxDepth:      config.CommentMaxDepth,
+		CommentCollapseAfter: config.CommentCollapseAfter,
+		PageSize:             config.PageSize,
+
+		UploadDir:      config.UploadDir,
+		MaxUploadSize:  config.MaxUploadSize,
+		MaxAttachments: config.MaxAttachments,
+
+		BaseURL:      strings.TrimRight(config.BaseURL, "/"),
+		Mailer:       config.Mailer,
+		MailFrom:     config.MailFrom,
+		MailDir:      config.MailDir,
+		SMTPHost:     config.SMTPHost,
+		SMTPPort:     config.SMTPPort,
+		SMTPUsername: config.SMTPUsername,
+		SMTPPassword: config.SMTPPassword,
+
+		OAuthProviders: config.OAuthProviders,
+
+		CookieSecure:    config.CookieSecure,
+		CookieSameSite:  strings.ToLower(config.CookieSameSite),
+		CookieDomain:    config.CookieDomain,
+		SessionTTLHours: config.SessionTTLHours,
+		SessionMaxHours: config.SessionMaxHours,
+
+		RateLimitStore:   config.RateLimitStore,
+		AuthBurst:        config.AuthBurst,
+		AuthEverySeconds: config.AuthEverySeconds,
+		LockoutFailures:  config.LockoutFailures,
+		LockoutMinutes:   config.LockoutMinutes,
+
+		TOTPIssuer: config.TOTPIssuer,
+
+		PasswordPolicy:  config.PasswordPolicy,
+		PasswordHasher:  strings.ToLower(config.PasswordHasher),
+		BcryptCost:      config.BcryptCost,
+		Argon2Time:      config.Argon2Time,
+		Argon2MemoryKiB: config.Argon2MemoryKiB,
+		Argon2Threads:   config.Argon2Threads,
 	}, nil
 }
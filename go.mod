@@ -7,6 +7,11 @@ require (
 	golang.org/x/crypto v0.5.0
 )
 
-require github.com/gofrs/uuid v4.3.1+incompatible
+require (
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/text v0.14.0
+)
+
+require golang.org/x/sys v0.5.0 // indirect
 
 // require github.com/joho/godotenv v1.4.0
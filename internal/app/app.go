@@ -1,17 +1,24 @@
 package app
 
 import (
+	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"forum/config"
 	"forum/internal/controller"
 	"forum/internal/repository"
 	"forum/internal/server"
 	"forum/internal/usecase"
+	"forum/migrations"
 	"forum/pkg/database"
+	"forum/pkg/mailer"
+	"forum/pkg/password"
+	"forum/pkg/ratelimit"
 )
 
 type App struct {
@@ -31,17 +38,32 @@ func (a *App) Start() {
 		log.Fatalf("app - start - repository init error: %v\n", err)
 	}
 
-	// initialise tables - what the best way to place creation of tables?
-	if err := database.CreateTables(db); err != nil {
-		log.Fatalf("app - start - create tables: %v\n", err)
+	// apply pending schema migrations embedded into the binary
+	if err := database.Migrate(db, migrations.FS); err != nil {
+		log.Fatalf("app - start - migrate: %v\n", err)
 	}
 
 	// repository layer
 	userRepository := repository.NewRepository(db, a.config)
+	// outgoing email
+	mail, err := newMailer(a.config)
+	if err != nil {
+		log.Fatalf("app - start - mailer: %v\n", err)
+	}
+	// throttling of sign-in and sign-up
+	limiter, err := newLimiter(a.config, db)
+	if err != nil {
+		log.Fatalf("app - start - rate limiter: %v\n", err)
+	}
+	// rules and hashing of passwords
+	policy, hasher, err := newPasswords(a.config)
+	if err != nil {
+		log.Fatalf("app - start - passwords: %v\n", err)
+	}
 	// usecase layer
-	useCase := usecase.NewUseCase(userRepository)
+	useCase := usecase.NewUseCase(userRepository, a.config, mail, limiter, policy, hasher)
 	// handler
-	handler := controller.NewHandler(useCase)
+	handler := controller.NewHandler(useCase, a.config)
 
 	router := controller.SetupRouter(handler)
 
@@ -63,3 +85,81 @@ func (a *App) Start() {
 		log.Printf("app: start: server.Shutdown: %v\n", err)
 	}
 }
+
+// newMailer builds the mailer selected in the config
+func newMailer(cnf *config.Config) (mailer.Mailer, error) {
+	switch cnf.Mailer {
+	case "smtp":
+		return mailer.NewSMTP(cnf.SMTPHost, cnf.SMTPPort, cnf.SMTPUsername, cnf.SMTPPassword, cnf.MailFrom), nil
+	case "file":
+		return mailer.NewFile(cnf.MailDir, cnf.MailFrom), nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q", cnf.Mailer)
+}
+
+// newLimiter builds the sign-in and sign-up limiter on the store selected in the config
+func newLimiter(cnf *config.Config, db *sql.DB) (*ratelimit.Limiter, error) {
+	var store ratelimit.Store
+	switch cnf.RateLimitStore {
+	case "memory":
+		store = ratelimit.NewMemory()
+	case "sqlite":
+		store = ratelimit.NewSQLite(db)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cnf.RateLimitStore)
+	}
+
+	return ratelimit.New(store, ratelimit.Config{
+		Burst:       cnf.AuthBurst,
+		Every:       time.Duration(cnf.AuthEverySeconds) * time.Second,
+		MaxFailures: cnf.LockoutFailures,
+		Lockout:     time.Duration(cnf.LockoutMinutes) * time.Minute,
+	})
+}
+
+// newPasswords builds the password policy, with its list of common passwords,
+// and the hasher selected in the config
+func newPasswords(cnf *config.Config) (*password.Policy, password.Hasher, error) {
+	p := cnf.PasswordPolicy
+	if p.MinLength < 1 || (p.MaxLength > 0 && p.MaxLength < p.MinLength) {
+		return nil, nil, fmt.Errorf("invalid password length %d to %d", p.MinLength, p.MaxLength)
+	}
+
+	policy := &password.Policy{
+		MinLength:     p.MinLength,
+		MaxLength:     p.MaxLength,
+		RequireUpper:  p.RequireUpper,
+		RequireLower:  p.RequireLower,
+		RequireDigit:  p.RequireDigit,
+		RequireSymbol: p.RequireSymbol,
+	}
+	if p.CommonPasswords != "" {
+		list, err := password.LoadList(p.CommonPasswords)
+		if err != nil {
+			return nil, nil, fmt.Errorf("common passwords: %w", err)
+		}
+		policy.Common = list
+	}
+
+	var (
+		hasher password.Hasher
+		err    error
+	)
+	switch cnf.PasswordHasher {
+	case "bcrypt":
+		hasher, err = password.NewBcrypt(cnf.BcryptCost)
+		policy.MaxBytes = password.BcryptMaxBytes
+	case "argon2id":
+		if cnf.Argon2Threads > 255 {
+			return nil, nil, fmt.Errorf("too many argon2 threads %d", cnf.Argon2Threads)
+		}
+		hasher, err = password.NewArgon2id(uint32(cnf.Argon2Time), uint32(cnf.Argon2MemoryKiB), uint8(cnf.Argon2Threads))
+	default:
+		return nil, nil, fmt.Errorf("unknown password hasher %q", cnf.PasswordHasher)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return policy, hasher, nil
+}
@@ -0,0 +1,538 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// apiError is the body of every failed /api/v1 response
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiPost struct {
+	Id      int    `json:"id"`
+	Author  string `json:"author"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// ContentHTML is Content rendered from Markdown and sanitized
+	ContentHTML string     `json:"contentHtml"`
+	Categories  []string   `json:"categories"`
+	Likes       int        `json:"likes"`
+	Dislikes    int        `json:"dislikes"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty"`
+	// Attachments are only filled in for a single post
+	Attachments []apiAttachment `json:"attachments,omitempty"`
+}
+
+type apiAttachment struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+type apiComment struct {
+	Id          int          `json:"id"`
+	PostId      int          `json:"postId"`
+	ParentId    int          `json:"parentId,omitempty"`
+	Author      string       `json:"author"`
+	Content     string       `json:"content"`
+	ContentHTML string       `json:"contentHtml,omitempty"`
+	Likes       int          `json:"likes"`
+	Dislikes    int          `json:"dislikes"`
+	Deleted     bool         `json:"deleted"`
+	UpdatedAt   *time.Time   `json:"updatedAt,omitempty"`
+	Replies     []apiComment `json:"replies"`
+}
+
+type apiUser struct {
+	Id          int    `json:"id"`
+	Username    string `json:"username"`
+	Posts       int    `json:"posts"`
+	DisplayName string `json:"displayName,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+	Website     string `json:"website,omitempty"`
+}
+
+type apiToken struct {
+	Token     string    `json:"token"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// optionalTime drops zero times from the json output
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func toAPIPost(post entity.Post) apiPost {
+	categories := post.Category
+	if categories == nil {
+		categories = []string{}
+	}
+	var attachments []apiAttachment
+	for _, a := range post.Attachments {
+		attachments = append(attachments, apiAttachment{
+			URL:          a.URL(),
+			ThumbnailURL: a.ThumbnailURL(),
+			Name:         a.OriginalName,
+			ContentType:  a.ContentType,
+			Size:         a.Size,
+			Width:        a.Width,
+			Height:       a.Height,
+		})
+	}
+	return apiPost{
+		Id:          post.PostId,
+		Author:      post.PostAuthor,
+		Title:       post.Title,
+		Content:     post.Content,
+		ContentHTML: post.ContentHTML,
+		Categories:  categories,
+		Likes:       post.Likes,
+		Dislikes:    post.Dislikes,
+		CreatedAt:   post.CreationTime,
+		UpdatedAt:   optionalTime(post.UpdatedTime),
+		Attachments: attachments,
+	}
+}
+
+func toAPIPosts(posts []entity.Post) []apiPost {
+	list := make([]apiPost, 0, len(posts))
+	for _, post := range posts {
+		list = append(list, toAPIPost(post))
+	}
+	return list
+}
+
+// toAPIComments flattens collapsed replies back into replies, clients decide how to show them
+func toAPIComments(comments []entity.Comments) []apiComment {
+	list := make([]apiComment, 0, len(comments))
+	for _, comment := range comments {
+		author := comment.Author
+		if comment.Deleted {
+			author = entity.DeletedComment
+		}
+		list = append(list, apiComment{
+			Id:          comment.CommentId,
+			PostId:      comment.PostId,
+			ParentId:    comment.ParentId,
+			Author:      author,
+			Content:     comment.Content,
+			ContentHTML: comment.ContentHTML,
+			Likes:       comment.Likes,
+			Dislikes:    comment.Dislikes,
+			Deleted:     comment.Deleted,
+			UpdatedAt:   optionalTime(comment.UpdatedTime),
+			Replies:     toAPIComments(append(comment.Replies, comment.CollapsedReplies...)),
+		})
+	}
+	return list
+}
+
+func (h *handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("error encode json: %v", err)
+	}
+}
+
+func (h *handler) apiErrorHandler(w http.ResponseWriter, status int, code, message string) {
+	log.Printf("api error occured: %s", message)
+	h.writeJSON(w, status, apiError{Error: apiErrorBody{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}})
+}
+
+// apiUsecaseError maps usecase and repository errors to a status and an error code
+func (h *handler) apiUsecaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		h.apiErrorHandler(w, http.StatusNotFound, "not_found", "resource not found")
+	case errors.Is(err, usecase.ErrUserNotFound),
+		errors.Is(err, usecase.ErrInvalidPassword):
+		h.apiErrorHandler(w, http.StatusUnauthorized, "invalid_credentials", "invalid username or password")
+	case errors.Is(err, usecase.ErrTwoFactorRequired):
+		h.apiErrorHandler(w, http.StatusUnauthorized, "two_factor_required", "send the code of the authenticator app in \"code\"")
+	case errors.Is(err, usecase.ErrInvalidCode):
+		h.apiErrorHandler(w, http.StatusUnauthorized, "invalid_code", usecase.ErrInvalidCode.Error())
+	case errors.Is(err, usecase.ErrTooManyAttempts):
+		retryAfter(w, err)
+		h.apiErrorHandler(w, http.StatusTooManyRequests, "too_many_attempts", "too many attempts, try again later")
+	case errors.Is(err, usecase.ErrNotAuthor),
+		errors.Is(err, usecase.ErrForbidden):
+		h.apiErrorHandler(w, http.StatusForbidden, "forbidden", err.Error())
+	case errors.Is(err, usecase.ErrPostLocked):
+		h.apiErrorHandler(w, http.StatusForbidden, "post_locked", err.Error())
+	case errors.Is(err, usecase.ErrUserSuspended):
+		h.apiErrorHandler(w, http.StatusForbidden, "user_suspended", err.Error())
+	case errors.Is(err, usecase.ErrInvalidQuery):
+		h.apiErrorHandler(w, http.StatusBadRequest, "invalid_query", err.Error())
+	case errors.Is(err, usecase.ErrInvalidContent),
+		errors.Is(err, usecase.ErrInvalidContentLength),
+		errors.Is(err, usecase.ErrInvalidTitleLength),
+		errors.Is(err, usecase.ErrNoContent),
+		errors.Is(err, usecase.ErrNoTitle),
+		errors.Is(err, usecase.ErrInvalidCharacter),
+		errors.Is(err, usecase.ErrInvalidParent),
+		errors.Is(err, usecase.ErrUnknownCategory):
+		h.apiErrorHandler(w, http.StatusBadRequest, "invalid_content", err.Error())
+	case errors.Is(err, usecase.ErrInvalidVote):
+		h.apiErrorHandler(w, http.StatusBadRequest, "invalid_vote", err.Error())
+	case errors.Is(err, usecase.ErrCommentDeleted):
+		h.apiErrorHandler(w, http.StatusConflict, "comment_deleted", err.Error())
+	default:
+		h.apiErrorHandler(w, http.StatusInternalServerError, "internal_error", http.StatusText(http.StatusInternalServerError))
+	}
+}
+
+// apiAuthUser returns the authenticated user or writes 401 and returns false
+func (h *handler) apiAuthUser(w http.ResponseWriter, r *http.Request) (entity.UserModel, bool) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+	if user == (entity.UserModel{}) {
+		h.apiErrorHandler(w, http.StatusUnauthorized, "unauthorized", "user unauthorized")
+		return entity.UserModel{}, false
+	}
+	return user, true
+}
+
+func (h *handler) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(v); err != nil {
+		h.apiErrorHandler(w, http.StatusBadRequest, "invalid_body", "request body must be valid json")
+		return false
+	}
+	return true
+}
+
+// POST /api/v1/auth/sign-in
+func (h *handler) apiSignIn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.apiErrorHandler(w, http.StatusMethodNotAllowed, "method_not_allowed", "incorrect method")
+		return
+	}
+
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		// Code is needed when the user has two-factor authentication on
+		Code string `json:"code"`
+	}
+	if !h.decodeJSON(w, r, &credentials) {
+		return
+	}
+
+	user, err := h.usecase.AuthorizationUsecase.CreateToken(credentials.Username, credentials.Password, clientOf(r))
+	var required *usecase.TwoFactorError
+	if errors.As(err, &required) && credentials.Code != "" {
+		user, err = h.usecase.AuthorizationUsecase.CompleteTwoFactor(required.Challenge, credentials.Code, clientOf(r))
+	}
+	if err != nil {
+		h.apiUsecaseError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, apiToken{
+		Token:     user.Token,
+		Username:  user.Username,
+		ExpiresAt: user.ExpirationTime,
+	})
+}
+
+// POST /api/v1/auth/logout
+func (h *handler) apiLogout(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.apiAuthUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.apiErrorHandler(w, http.StatusMethodNotAllowed, "method_not_allowed", "incorrect method")
+		return
+	}
+
+	if err := h.usecase.AuthorizationUsecase.DeleteToken(user.Token); err != nil {
+		h.apiUsecaseError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/v1/posts, POST /api/v1/posts
+func (h *handler) apiPosts(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	switch r.Method {
+	case http.MethodGet:
+		page, err := h.usecase.PostsUsecase.GetAllPostsFromFilter(user, r.URL.Query())
+		if err != nil {
+			h.apiUsecaseError(w, err)
+			return
+		}
+
+		h.writeJSON(w, http.StatusOK, map[string]interface{}{"posts": toAPIPosts(page.Posts), "next_cursor": page.Next})
+
+	case http.MethodPost:
+		user, ok := h.apiAuthUser(w, r)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Title      string   `json:"title"`
+			Content    string   `json:"content"`
+			Categories []string `json:"categories"`
+		}
+		if !h.decodeJSON(w, r, &body) {
+			return
+		}
+
+		post := entity.Post{
+			Title:      body.Title,
+			Content:    body.Content,
+			PostAuthor: user.Username,
+			Category:   body.Categories,
+		}
+		id, err := h.usecase.PostsUsecase.CreatePost(post)
+		if err != nil {
+			h.apiUsecaseError(w, err)
+			return
+		}
+
+		w.Header().Set("Location", "/api/v1/posts/"+strconv.Itoa(id))
+		h.writeJSON(w, http.StatusCreated, map[string]int{"id": id})
+
+	default:
+		h.apiErrorHandler(w, http.StatusMethodNotAllowed, "method_not_allowed", "incorrect method")
+	}
+}
+
+// /api/v1/posts/{id}, /api/v1/posts/{id}/comments, /api/v1/posts/{id}/like|dislike|vote
+func (h *handler) apiPost(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/posts/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 {
+		h.apiErrorHandler(w, http.StatusNotFound, "not_found", "incorrect path")
+		return
+	}
+
+	post, err := h.usecase.PostsUsecase.GetPostsById(id)
+	if err != nil {
+		h.apiUsecaseError(w, err)
+		return
+	}
+
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		h.writeJSON(w, http.StatusOK, toAPIPost(post))
+
+	case action == "comments" && r.Method == http.MethodGet:
+		comments, err := h.usecase.CommentsUsecase.GetCommentsByPostId(post.PostId)
+		if err != nil {
+			h.apiUsecaseError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, map[string]interface{}{"comments": toAPIComments(comments)})
+
+	case action == "comments" && r.Method == http.MethodPost:
+		user, ok := h.apiAuthUser(w, r)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Content  string `json:"content"`
+			ParentId int    `json:"parentId"`
+		}
+		if !h.decodeJSON(w, r, &body) {
+			return
+		}
+
+		comment := entity.Comments{
+			Content:  body.Content,
+			Author:   user.Username,
+			PostId:   post.PostId,
+			ParentId: body.ParentId,
+		}
+		id, err := h.usecase.CommentsUsecase.CreateComment(comment)
+		if err != nil {
+			h.apiUsecaseError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/api/v1/posts/"+strconv.Itoa(post.PostId)+"/comments")
+		h.writeJSON(w, http.StatusCreated, map[string]int{"id": id})
+
+	case (action == "like" || action == "dislike" || action == "vote") && r.Method == http.MethodPost:
+		user, ok := h.apiAuthUser(w, r)
+		if !ok {
+			return
+		}
+		value, ok := h.apiVoteValue(w, r, action)
+		if !ok {
+			return
+		}
+
+		count, err := h.usecase.PostsVoterUsecase.Vote(post.PostId, user.Username, value)
+		if err != nil {
+			h.apiUsecaseError(w, err)
+			return
+		}
+		if action == "vote" {
+			h.writeJSON(w, http.StatusOK, toAPIVote(count))
+			return
+		}
+
+		post, err = h.usecase.PostsUsecase.GetPostsById(post.PostId)
+		if err != nil {
+			h.apiUsecaseError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, toAPIPost(post))
+
+	case action == "" || action == "comments" || action == "like" || action == "dislike" || action == "vote":
+		h.apiErrorHandler(w, http.StatusMethodNotAllowed, "method_not_allowed", "incorrect method")
+
+	default:
+		h.apiErrorHandler(w, http.StatusNotFound, "not_found", "incorrect path")
+	}
+}
+
+// POST /api/v1/comments/{id}/like|dislike|vote
+func (h *handler) apiCommentVote(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/comments/"), "/")
+	if len(parts) != 2 || (parts[1] != "like" && parts[1] != "dislike" && parts[1] != "vote") {
+		h.apiErrorHandler(w, http.StatusNotFound, "not_found", "incorrect path")
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		h.apiErrorHandler(w, http.StatusNotFound, "not_found", "incorrect path")
+		return
+	}
+
+	user, ok := h.apiAuthUser(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.apiErrorHandler(w, http.StatusMethodNotAllowed, "method_not_allowed", "incorrect method")
+		return
+	}
+	value, ok := h.apiVoteValue(w, r, parts[1])
+	if !ok {
+		return
+	}
+
+	count, err := h.usecase.CommentsUsecase.Vote(id, user.Username, value)
+	if err != nil {
+		h.apiUsecaseError(w, err)
+		return
+	}
+	if parts[1] == "vote" {
+		h.writeJSON(w, http.StatusOK, toAPIVote(count))
+		return
+	}
+
+	comment, err := h.usecase.CommentsUsecase.GetCommentById(id)
+	if err != nil {
+		h.apiUsecaseError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, toAPIComments([]entity.Comments{comment})[0])
+}
+
+// apiVoteValue is 1 for like, -1 for dislike and the value of the
+// {"value": 1} body for vote
+func (h *handler) apiVoteValue(w http.ResponseWriter, r *http.Request, action string) (int, bool) {
+	switch action {
+	case "like":
+		return entity.VoteUp, true
+	case "dislike":
+		return entity.VoteDown, true
+	}
+
+	var body struct {
+		Value *int `json:"value"`
+	}
+	if !h.decodeJSON(w, r, &body) {
+		return 0, false
+	}
+	if body.Value == nil {
+		h.apiErrorHandler(w, http.StatusBadRequest, "invalid_vote", usecase.ErrInvalidVote.Error())
+		return 0, false
+	}
+	return *body.Value, true
+}
+
+// GET /api/v1/profile/{name}, ?posts=created|liked|disliked|commented adds the post list
+func (h *handler) apiProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.apiErrorHandler(w, http.StatusMethodNotAllowed, "method_not_allowed", "incorrect method")
+		return
+	}
+
+	profile, err := h.usecase.UsersUsecase.GetUserByName(strings.TrimPrefix(r.URL.Path, "/api/v1/profile/"))
+	if err != nil {
+		h.apiUsecaseError(w, err)
+		return
+	}
+
+	body := map[string]interface{}{
+		"user": apiUser{
+			Id:          profile.UserId,
+			Username:    profile.Username,
+			Posts:       profile.Posts,
+			DisplayName: profile.DisplayName,
+			Bio:         profile.Bio,
+			Avatar:      profile.AvatarURL(),
+			Website:     profile.Website,
+		},
+	}
+
+	if len(r.URL.Query()) != 0 {
+		page, err := h.usecase.UsersUsecase.GetPostsByName(profile.Username, r.URL.Query())
+		if err != nil {
+			h.apiUsecaseError(w, err)
+			return
+		}
+		body["posts"] = toAPIPosts(page.Posts)
+		body["next_cursor"] = page.Next
+	}
+
+	h.writeJSON(w, http.StatusOK, body)
+}
+
+func (h *handler) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	h.apiErrorHandler(w, http.StatusNotFound, "not_found", "incorrect path")
+}
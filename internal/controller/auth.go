@@ -2,8 +2,10 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"forum/internal/entity"
@@ -25,34 +27,36 @@ func (h *handler) Home(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var posts []entity.Post
-	var err error
-	if len(r.URL.Query()) == 0 {
-		posts, err = h.usecase.PostsUsecase.GetAllPosts()
-		if err != nil {
-			log.Printf("error home page: query len: %v\n", err)
-			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	// get all by filter, limit and cursor select the page
+	page, err := h.usecase.PostsUsecase.GetAllPostsFromFilter(user, r.URL.Query())
+	if err != nil {
+		if errors.Is(err, usecase.ErrUserNotFound) {
+			log.Printf("error: %v\n", err)
+			h.errorHandler(w, http.StatusUnauthorized, err.Error())
 			return
 		}
-	} else {
-		// get all by filter
-		posts, err = h.usecase.PostsUsecase.GetAllPostsFromFilter(user, r.URL.Query())
-		if err != nil {
-			if errors.Is(err, usecase.ErrUserNotFound) {
-				log.Printf("error: %v\n", err)
-				h.errorHandler(w, http.StatusUnauthorized, err.Error())
-				return
-			}
-			log.Printf("error %v\n", err)
-			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, usecase.ErrInvalidQuery) {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		log.Printf("error %v\n", err)
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	categories, err := h.usecase.CategoriesUsecase.GetCategories()
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+	first, next := pageLinks(r, page.Next)
 	info := entity.Profile{
-		Posts: posts,
-		User:  user,
+		Posts:      page.Posts,
+		User:       user,
+		Categories: categories,
+		FirstPage:  first,
+		NextPage:   next,
 	}
-	if err := h.execute(w, "ui/template/index.html", info); err != nil {
+	if err := h.execute(w, r, "ui/template/index.html", info); err != nil {
 		h.errorHandler(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -76,7 +80,7 @@ func (h *handler) signUp(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		if err := h.execute(w, "ui/template/register.html", nil); err != nil {
+		if err := h.execute(w, r, "ui/template/register.html", nil); err != nil {
 			h.errorHandler(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -124,9 +128,13 @@ func (h *handler) signUp(w http.ResponseWriter, r *http.Request) {
 			Usernamecheck: username[0],
 		}
 
-		if err := h.usecase.CreateUserandValidate(user); err != nil {
+		if err := h.usecase.CreateUserandValidate(user, clientOf(r)); err != nil {
 
 			switch {
+			case errors.Is(err, usecase.ErrTooManyAttempts):
+				log.Printf("error: %v", err)
+				userCheck.Username = tooManyAttempts(w, err)
+
 			case errors.Is(err, usecase.ErrInvalidEmail):
 				log.Printf("error: %v", err)
 				userCheck.Email = "Enter a valid email address"
@@ -145,7 +153,7 @@ func (h *handler) signUp(w http.ResponseWriter, r *http.Request) {
 
 			case errors.Is(err, usecase.ErrInvalidPassword):
 				log.Printf("error: %v", err)
-				userCheck.Password = "Your password must consist of english letters, at least 1 Upper case and special symbol, 6 to 20 long without spaces"
+				userCheck.Password = weakPassword(err)
 
 			case errors.Is(err, usecase.ErrConfirmPassword):
 				log.Printf("error: %v", err)
@@ -161,7 +169,7 @@ func (h *handler) signUp(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			if err := h.execute(w, "ui/template/register.html", userCheck); err != nil {
+			if err := h.execute(w, r, "ui/template/register.html", userCheck); err != nil {
 				h.errorHandler(w, http.StatusInternalServerError, err.Error())
 				return
 			}
@@ -192,7 +200,8 @@ func (h *handler) signIn(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		if err := h.execute(w, "ui/template/login.html", nil); err != nil {
+		info := entity.Profile{OAuthProviders: h.usecase.AuthorizationUsecase.OAuthProviders()}
+		if err := h.execute(w, r, "ui/template/login.html", info); err != nil {
 			h.errorHandler(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -216,33 +225,41 @@ func (h *handler) signIn(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		user, err := h.usecase.AuthorizationUsecase.CreateToken(username[0], password[0])
+		user, err := h.usecase.AuthorizationUsecase.CreateToken(username[0], password[0], clientOf(r))
 		if err != nil {
 			switch {
-			case errors.Is(err, usecase.ErrUserNotFound):
+			case errors.Is(err, usecase.ErrTwoFactorRequired):
+				h.startTwoFactor(w, r, err)
+				return
+			case errors.Is(err, usecase.ErrUserNotFound),
+				errors.Is(err, usecase.ErrInvalidPassword):
+				// the same answer for both, it must not tell which usernames exist
 				log.Printf("error: %v", err)
-				userCheck.Username = "No such user, please register"
-			case errors.Is(err, usecase.ErrInvalidPassword):
+				userCheck.Password = "Invalid username or password"
+			case errors.Is(err, usecase.ErrTooManyAttempts):
 				log.Printf("error: %v", err)
-				userCheck.Password = "Password is incorrect"
+				userCheck.Password = tooManyAttempts(w, err)
+			case errors.Is(err, usecase.ErrUserSuspended):
+				log.Printf("error: %v", err)
+				userCheck.Username = "Your account is suspended"
 			default:
 				log.Printf("error cannot create token\n")
 				h.errorHandler(w, http.StatusInternalServerError, err.Error())
 				return
 			}
 
-			if err := h.execute(w, "ui/template/login.html", userCheck); err != nil {
+			info := entity.Profile{
+				User:           userCheck,
+				OAuthProviders: h.usecase.AuthorizationUsecase.OAuthProviders(),
+			}
+			if err := h.execute(w, r, "ui/template/login.html", info); err != nil {
 				h.errorHandler(w, http.StatusInternalServerError, err.Error())
 				return
 			}
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:  "session_cookie",
-			Value: user.Token,
-			Path:  "/",
-		})
+		h.setSessionCookie(w, user)
 
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	default:
@@ -250,36 +267,78 @@ func (h *handler) signIn(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// logout asks for confirmation on GET, so a link on another site cannot sign
+// the user out, and ends the session on POST
 func (h *handler) logout(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
 	if r.URL.Path != "/auth/logout" {
 		log.Printf("error incorrect path\n")
 		h.errorHandler(w, http.StatusNotFound, "incorrect path")
 		return
 	}
-	if r.Method != http.MethodGet {
+
+	switch r.Method {
+	case http.MethodGet:
+		if user == (entity.UserModel{}) {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		if err := h.execute(w, r, "ui/template/logout.html", entity.Profile{User: user}); err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+
+	case http.MethodPost:
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			log.Printf("error in cookie\n")
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := h.usecase.AuthorizationUsecase.DeleteToken(cookie.Value); err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		h.clearSessionCookie(w)
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	default:
 		log.Printf("error incorrect method\n")
 		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
-		return
 	}
+}
 
-	cookie, err := r.Cookie("session_cookie")
-	if err != nil {
-		log.Printf("error in cookie\n")
-		h.errorHandler(w, http.StatusBadRequest, err.Error())
-		return
+// weakPassword is the message for a new password the policy refused
+func weakPassword(err error) string {
+	var weak *usecase.PasswordError
+	if errors.As(err, &weak) {
+		return "Your password " + weak.Reason
 	}
+	return "Your password does not meet the requirements"
+}
 
-	if err := h.usecase.AuthorizationUsecase.DeleteToken(cookie.Value); err != nil {
-		h.errorHandler(w, http.StatusInternalServerError, err.Error())
-		return
+// tooManyAttempts answers a throttled sign-in or sign-up with 429 and
+// Retry-After and returns the message for the form
+func tooManyAttempts(w http.ResponseWriter, err error) string {
+	seconds := retryAfter(w, err)
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	if seconds <= 60 {
+		return fmt.Sprintf("Too many attempts, try again in %d seconds", seconds)
 	}
+	return fmt.Sprintf("Too many attempts, try again in %d minutes", (seconds+59)/60)
+}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:    "session_cookie",
-		Value:   "",
-		Expires: time.Time{},
-		Path:    "/",
-	})
+// retryAfter sets the Retry-After header from the wait of a usecase.LimitError
+func retryAfter(w http.ResponseWriter, err error) int {
+	seconds := 60
+	var limit *usecase.LimitError
+	if errors.As(err, &limit) {
+		seconds = int((limit.Wait + time.Second - 1) / time.Second)
+	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return seconds
 }
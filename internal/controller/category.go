@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// categoryError writes the error page for errors returned by CategoriesUsecase
+func (h *handler) categoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		h.errorHandler(w, http.StatusNotFound, "category not found")
+	case errors.Is(err, usecase.ErrForbidden):
+		h.errorHandler(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, usecase.ErrInvalidCategory):
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, usecase.ErrCategoryExists),
+		errors.Is(err, usecase.ErrCategoryNotEmpty):
+		h.errorHandler(w, http.StatusConflict, err.Error())
+	default:
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// categories lists every category with its post count
+func (h *handler) categories(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if status := h.CheckMethod("/categories", http.MethodGet, r); status != http.StatusOK {
+		h.errorHandler(w, status, http.StatusText(status))
+		return
+	}
+
+	categories, err := h.usecase.CategoriesUsecase.GetCategories()
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	info := entity.Profile{
+		User:       user,
+		Categories: categories,
+	}
+	if err := h.execute(w, r, "ui/template/categories.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// categoryPage lists the posts of /category/{slug}, the feed filters and pagination apply too
+func (h *handler) categoryPage(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	category, err := h.usecase.CategoriesUsecase.GetCategoryBySlug(strings.TrimPrefix(r.URL.Path, "/category/"))
+	if err != nil {
+		h.categoryError(w, err)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Set("category", category.Name)
+	page, err := h.usecase.PostsUsecase.GetAllPostsFromFilter(user, query)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidQuery) {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	first, next := pageLinks(r, page.Next)
+	info := entity.Profile{
+		User:      user,
+		Category:  category,
+		Posts:     page.Posts,
+		FirstPage: first,
+		NextPage:  next,
+	}
+	if err := h.execute(w, r, "ui/template/category.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// adminCategories shows the category management page
+func (h *handler) adminCategories(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if status := h.CheckMethod("/admin/categories", http.MethodGet, r); status != http.StatusOK {
+		h.errorHandler(w, status, http.StatusText(status))
+		return
+	}
+
+	categories, err := h.usecase.CategoriesUsecase.GetCategories()
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	info := entity.Profile{
+		User:       user,
+		Categories: categories,
+	}
+	if err := h.execute(w, r, "ui/template/adminCategories.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// saveCategory creates a category on /admin/categories/create and
+// changes one on /admin/categories/update/{id}
+func (h *handler) saveCategory(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	var id int
+	if r.URL.Path != "/admin/categories/create" {
+		var err error
+		id, err = strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/admin/categories/update/"))
+		if err != nil {
+			h.errorHandler(w, http.StatusNotFound, "incorrect path")
+			return
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	position, err := strconv.Atoi(r.Form.Get("position"))
+	if err != nil {
+		h.errorHandler(w, http.StatusBadRequest, "incorrect position field")
+		return
+	}
+
+	category := entity.Category{
+		CategoryId:  id,
+		Slug:        r.Form.Get("slug"),
+		Name:        r.Form.Get("name"),
+		Description: r.Form.Get("description"),
+		Position:    position,
+	}
+
+	if id == 0 {
+		err = h.usecase.CategoriesUsecase.CreateCategory(user, category)
+	} else {
+		err = h.usecase.CategoriesUsecase.UpdateCategory(user, category)
+	}
+	if err != nil {
+		h.categoryError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
+
+func (h *handler) deleteCategory(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/admin/categories/delete/"))
+	if err != nil {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if err := h.usecase.CategoriesUsecase.DeleteCategory(user, id); err != nil {
+		h.categoryError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/categories", http.StatusSeeOther)
+}
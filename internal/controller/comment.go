@@ -4,15 +4,15 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"forum/internal/entity"
+	"forum/internal/usecase"
 )
 
-func (h *handler) likeComment(w http.ResponseWriter, r *http.Request) {
+func (h *handler) editComment(w http.ResponseWriter, r *http.Request) {
 	u := r.Context().Value(ctxKeyUser)
 	user := u.(entity.UserModel)
 
@@ -21,13 +21,7 @@ func (h *handler) likeComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Method != http.MethodPost {
-		log.Printf("error incorrect method\n")
-		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
-		return
-	}
-
-	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/comment/like/"))
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/comment/edit/"))
 	if err != nil {
 		h.errorHandler(w, http.StatusNotFound, "incorrect path")
 		return
@@ -39,20 +33,66 @@ func (h *handler) likeComment(w http.ResponseWriter, r *http.Request) {
 			h.errorHandler(w, http.StatusNotFound, "incorrect path")
 			return
 		}
-
 		h.errorHandler(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	if err := h.usecase.CommentsUsecase.LikeComment(id, user.Username); err != nil {
-		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	if comment.Author != user.Username {
+		h.errorHandler(w, http.StatusForbidden, usecase.ErrNotAuthor.Error())
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/post/%d", comment.PostId), http.StatusSeeOther)
+	switch r.Method {
+	case http.MethodGet:
+		if comment.Deleted {
+			h.errorHandler(w, http.StatusBadRequest, usecase.ErrCommentDeleted.Error())
+			return
+		}
+
+		info := entity.Profile{
+			User:     user,
+			Comments: []entity.Comments{comment},
+		}
+		if err := h.execute(w, r, "ui/template/editComment.html", info); err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		content, ok := r.Form["comment"]
+		if !ok {
+			h.errorHandler(w, http.StatusBadRequest, "comment field not found")
+			return
+		}
+		comment.Content = content[0]
+
+		if err := h.usecase.CommentsUsecase.UpdateComment(comment, user); err != nil {
+			if errors.Is(err, usecase.ErrNotAuthor) {
+				h.errorHandler(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if errors.Is(err, usecase.ErrInvalidContentLength) ||
+				errors.Is(err, usecase.ErrInvalidCharacter) ||
+				errors.Is(err, usecase.ErrCommentDeleted) {
+				h.errorHandler(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/post/%d", comment.PostId), http.StatusSeeOther)
+
+	default:
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+	}
 }
 
-func (h *handler) dislikeComment(w http.ResponseWriter, r *http.Request) {
+func (h *handler) deleteComment(w http.ResponseWriter, r *http.Request) {
 	u := r.Context().Value(ctxKeyUser)
 	user := u.(entity.UserModel)
 
@@ -65,7 +105,7 @@ func (h *handler) dislikeComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/comment/dislike/"))
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/comment/delete/"))
 	if err != nil {
 		h.errorHandler(w, http.StatusNotFound, "incorrect path")
 		return
@@ -81,7 +121,15 @@ func (h *handler) dislikeComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.usecase.CommentsUsecase.DislikeComment(id, user.Username); err != nil {
+	err = h.usecase.CommentsUsecase.DeleteComment(id, user)
+	if errors.Is(err, usecase.ErrNotAuthor) && user.Can(entity.PermDeleteAnyComment) {
+		err = h.usecase.ModerationUsecase.DeleteComment(user, id)
+	}
+	if err != nil {
+		if errors.Is(err, usecase.ErrNotAuthor) {
+			h.errorHandler(w, http.StatusForbidden, err.Error())
+			return
+		}
 		h.errorHandler(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
+
+	"forum/internal/entity"
 )
 
 func (h *handler) CheckMethod(path, method string, r *http.Request) int {
@@ -40,7 +45,7 @@ func (h *handler) errorHandler(w http.ResponseWriter, status int, errMessage str
 		log.Printf("error execute template:  %v", err)
 		return
 	}
-	// err := h.execute(w, "ui/template/error.html", errHandler)
+	// err := h.execute(w, r, "ui/template/error.html", errHandler)
 	// if err != nil {
 	// 	fmt.Printf("error execute template: %v", err)
 	// 	return
@@ -55,8 +60,54 @@ func (h *handler) setError(status int, err string) *Error {
 	}
 }
 
-func (h *handler) execute(w http.ResponseWriter, parse string, data interface{}) error {
-	html, err := template.ParseFiles(parse)
+// templateFuncs are available in every page template
+var templateFuncs = template.FuncMap{
+	// dict builds a map from key value pairs so nested templates can get several values
+	"dict": func(pairs ...interface{}) (map[string]interface{}, error) {
+		if len(pairs)%2 != 0 {
+			return nil, errors.New("dict: odd number of arguments")
+		}
+		m := make(map[string]interface{}, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			key, ok := pairs[i].(string)
+			if !ok {
+				return nil, errors.New("dict: key is not a string")
+			}
+			m[key] = pairs[i+1]
+		}
+		return m, nil
+	},
+	// highlight escapes a search snippet and turns its match markers into <mark> tags
+	"highlight": func(snippet string) template.HTML {
+		escaped := template.HTMLEscapeString(snippet)
+		escaped = strings.ReplaceAll(escaped, entity.SearchMatchStart, "<mark>")
+		escaped = strings.ReplaceAll(escaped, entity.SearchMatchEnd, "</mark>")
+		return template.HTML(escaped)
+	},
+	// rendered marks post and comment HTML produced by the markdown package
+	// as safe, it must not be used for anything else
+	"rendered": func(html string) template.HTML {
+		return template.HTML(html)
+	},
+	// otpauth lets the otpauth:// link of two-factor setup through as a link,
+	// anything else stays unsafe
+	"otpauth": func(uri string) template.URL {
+		if !strings.HasPrefix(uri, "otpauth://") {
+			return "#"
+		}
+		return template.URL(uri)
+	},
+}
+
+// execute renders the page template parse, forms in it get the CSRF token of
+// the request from csrfToken
+func (h *handler) execute(w http.ResponseWriter, r *http.Request, parse string, data interface{}) error {
+	token, _ := r.Context().Value(ctxKeyCSRF).(string)
+	funcs := template.FuncMap{
+		"csrfToken": func() string { return token },
+	}
+
+	html, err := template.New(filepath.Base(parse)).Funcs(templateFuncs).Funcs(funcs).ParseFiles(parse)
 	if err != nil {
 		log.Printf("error parse file: %v", err)
 		return fmt.Errorf("error parse files: %w", err)
@@ -70,3 +121,21 @@ func (h *handler) execute(w http.ResponseWriter, parse string, data interface{})
 
 	return nil
 }
+
+// pageLinks builds the links to the first page of the feed shown by r,
+// when r is not already on it, and to the page starting at cursor next
+func pageLinks(r *http.Request, next string) (first, nextLink string) {
+	query := r.URL.Query()
+
+	if query.Get("cursor") != "" {
+		query.Del("cursor")
+		first = r.URL.Path + "?" + query.Encode()
+	}
+
+	if next != "" {
+		query.Set("cursor", next)
+		nextLink = r.URL.Path + "?" + query.Encode()
+	}
+
+	return first, nextLink
+}
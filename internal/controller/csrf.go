@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net/http"
+
+	"forum/internal/entity"
+)
+
+const (
+	// csrfCookie holds the token of guests, csrfField and csrfHeader carry
+	// the token back in forms and scripts
+	csrfCookie = "csrf_token"
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// csrf rejects POST and other unsafe requests that do not carry the CSRF
+// token of the client. Signed in users get a token derived from their session
+// token, so every session has its own and nothing more is stored, guests get
+// a random one in a cookie. Requests authenticated by the Authorization header
+// are not sent by browsers on their own and need no token.
+func (h *handler) csrf(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var token string
+		if user := r.Context().Value(ctxKeyUser).(entity.UserModel); user != (entity.UserModel{}) {
+			token = sessionCSRFToken(user.Token)
+		} else if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+			token = cookie.Value
+		} else {
+			raw := make([]byte, 32)
+			if _, err := rand.Read(raw); err != nil {
+				h.reject(w, r, http.StatusInternalServerError, "internal_error", err.Error())
+				return
+			}
+			token = base64.RawURLEncoding.EncodeToString(raw)
+			http.SetCookie(w, h.cookie(csrfCookie, token))
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			sent, err := h.submittedCSRFToken(w, r)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					h.reject(w, r, http.StatusRequestEntityTooLarge, "request_too_large", "request is too large")
+					return
+				}
+				h.reject(w, r, http.StatusBadRequest, "invalid_body", err.Error())
+				return
+			}
+			if r.MultipartForm != nil {
+				defer r.MultipartForm.RemoveAll()
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				h.reject(w, r, http.StatusForbidden, "invalid_csrf_token", "the form has expired, reload the page and try again")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyCSRF, token)))
+	}
+}
+
+// submittedCSRFToken reads the token from the X-CSRF-Token header or from the
+// form. The form is parsed here with the limits of the handlers, which then
+// find it already parsed.
+func (h *handler) submittedCSRFToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if token := r.Header.Get(csrfHeader); token != "" {
+		return token, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxPostRequest())
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			return "", err
+		}
+	case "application/x-www-form-urlencoded":
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+		if err := r.ParseForm(); err != nil {
+			return "", err
+		}
+	}
+
+	return r.PostForm.Get(csrfField), nil
+}
+
+// sessionCSRFToken derives the CSRF token of a session from its token
+func sessionCSRFToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// verifyEmail confirms the email address of the token in the link sent at sign-up
+func (h *handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/auth/verify" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	info := entity.Profile{
+		User:   r.Context().Value(ctxKeyUser).(entity.UserModel),
+		Notice: "Your email address is confirmed.",
+	}
+	if err := h.usecase.AuthorizationUsecase.VerifyEmail(r.URL.Query().Get("token")); err != nil {
+		if !errors.Is(err, usecase.ErrInvalidToken) {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		info.Notice = "This link is invalid, already used or expired. You can request a new one on your profile page."
+	}
+
+	if err := h.execute(w, r, "ui/template/notice.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// resendVerification emails a new verification link to the logged in user
+func (h *handler) resendVerification(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+	if user == (entity.UserModel{}) {
+		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	if err := h.usecase.AuthorizationUsecase.SendVerification(user); err != nil && !errors.Is(err, usecase.ErrEmailVerified) {
+		log.Printf("error: %v", err)
+		h.errorHandler(w, http.StatusInternalServerError, "cannot send the email, try again later")
+		return
+	}
+
+	info := entity.Profile{
+		User:   user,
+		Notice: "We sent a new confirmation link to " + user.Email + ".",
+	}
+	if err := h.execute(w, r, "ui/template/notice.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// resetPassword asks for an email address and sends a reset link to it, the
+// link comes back here with a token and shows the form for the new password
+func (h *handler) resetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/auth/reset" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info := entity.Profile{Token: r.URL.Query().Get("token")}
+		if err := h.execute(w, r, "ui/template/reset.html", info); err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		token := r.PostForm.Get("token")
+		if token == "" {
+			err := h.usecase.AuthorizationUsecase.RequestPasswordReset(r.PostForm.Get("email"), clientOf(r))
+			switch {
+			case errors.Is(err, usecase.ErrTooManyAttempts):
+				log.Printf("error: %v", err)
+				info := entity.Profile{FormError: tooManyAttempts(w, err)}
+				if err := h.execute(w, r, "ui/template/reset.html", info); err != nil {
+					h.errorHandler(w, http.StatusInternalServerError, err.Error())
+				}
+				return
+			case err != nil:
+				log.Printf("error: %v", err)
+				h.errorHandler(w, http.StatusInternalServerError, "cannot send the email, try again later")
+				return
+			}
+			info := entity.Profile{Notice: "If an account uses this address, a link to reset the password is on its way."}
+			if err := h.execute(w, r, "ui/template/notice.html", info); err != nil {
+				h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+
+		err := h.usecase.AuthorizationUsecase.ResetPassword(token, r.PostForm.Get("password"), r.PostForm.Get("confirm_password"))
+		if err != nil {
+			info := entity.Profile{Token: token}
+			switch {
+			case errors.Is(err, usecase.ErrInvalidToken):
+				info = entity.Profile{Notice: "This link is invalid, already used or expired. Request a new one."}
+			case errors.Is(err, usecase.ErrInvalidPassword):
+				info.FormError = weakPassword(err)
+			case errors.Is(err, usecase.ErrConfirmPassword):
+				info.FormError = "password not the same"
+			default:
+				h.errorHandler(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			w.WriteHeader(http.StatusBadRequest)
+			page := "ui/template/reset.html"
+			if info.Notice != "" {
+				page = "ui/template/notice.html"
+			}
+			if err := h.execute(w, r, page, info); err != nil {
+				log.Printf("error: %v", err)
+			}
+			return
+		}
+
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+	default:
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+	}
+}
@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"forum/internal/entity"
+)
+
+// exportData is the account.json of a personal data export
+type exportData struct {
+	ExportedAt time.Time        `json:"exportedAt"`
+	Profile    exportProfile    `json:"profile"`
+	Posts      []apiPost        `json:"posts"`
+	Comments   []exportComment  `json:"comments"`
+	Votes      []exportVote     `json:"votes"`
+	Reports    []exportReport   `json:"reports"`
+	Sessions   []exportSession  `json:"sessions"`
+	Identities []exportIdentity `json:"identities"`
+}
+
+type exportProfile struct {
+	Id            int       `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"emailVerified"`
+	Role          string    `json:"role"`
+	CreatedAt     time.Time `json:"createdAt"`
+	DisplayName   string    `json:"displayName"`
+	Bio           string    `json:"bio"`
+	Avatar        string    `json:"avatar"`
+	Website       string    `json:"website"`
+	TwoFactor     bool      `json:"twoFactor"`
+}
+
+type exportComment struct {
+	Id        int        `json:"id"`
+	PostId    int        `json:"postId"`
+	ParentId  int        `json:"parentId,omitempty"`
+	Content   string     `json:"content"`
+	Likes     int        `json:"likes"`
+	Dislikes  int        `json:"dislikes"`
+	Deleted   bool       `json:"deleted"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type exportVote struct {
+	TargetType string    `json:"targetType"`
+	TargetId   int       `json:"targetId"`
+	Value      int       `json:"value"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type exportReport struct {
+	Id         int       `json:"id"`
+	TargetType string    `json:"targetType"`
+	TargetId   int       `json:"targetId"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details,omitempty"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type exportSession struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+}
+
+type exportIdentity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// exportAccount sends everything the forum keeps about the logged in user,
+// as a ZIP with account.json and the uploaded files or with ?format=json as
+// account.json alone
+func (h *handler) exportAccount(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/settings/export" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+	if user == (entity.UserModel{}) {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusFound)
+		return
+	}
+
+	data, err := h.usecase.AuthorizationUsecase.ExportAccount(user)
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	export := toExportData(data)
+	name := "forum-" + data.User.Username + "-" + export.ExportedAt.Format("20060102")
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(export); err != nil {
+			log.Printf("error: export account: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+	if err := h.writeExportZip(w, export, data); err != nil {
+		// the headers are gone already, the client gets a broken archive
+		log.Printf("error: export account: %v", err)
+	}
+}
+
+// writeExportZip writes account.json and the avatar and attachments of the
+// user under uploads/, files missing from the upload directory are skipped
+func (h *handler) writeExportZip(w io.Writer, export exportData, data entity.AccountData) error {
+	archive := zip.NewWriter(w)
+
+	f, err := archive.Create("account.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+
+	// uploads are named by their content, the same file may show up twice
+	files := map[string]bool{}
+	if data.User.Avatar != "" {
+		files[data.User.Avatar] = true
+	}
+	for _, post := range data.Posts {
+		for _, a := range post.Attachments {
+			files[a.Filename] = true
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := h.addUpload(archive, filepath.Base(name)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				log.Printf("export account: %s is missing from the uploads", name)
+				continue
+			}
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func (h *handler) addUpload(archive *zip.Writer, name string) error {
+	src, err := os.Open(filepath.Join(h.cnf.UploadDir, name))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := archive.Create("uploads/" + name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func toExportData(data entity.AccountData) exportData {
+	export := exportData{
+		ExportedAt: time.Now().UTC(),
+		Profile: exportProfile{
+			Id:            data.User.UserId,
+			Username:      data.User.Username,
+			Email:         data.User.Email,
+			EmailVerified: data.User.EmailVerified,
+			Role:          data.User.Role,
+			CreatedAt:     data.User.CreatedAt,
+			DisplayName:   data.User.DisplayName,
+			Bio:           data.User.Bio,
+			Avatar:        data.User.AvatarURL(),
+			Website:       data.User.Website,
+			TwoFactor:     data.TwoFactor,
+		},
+		Posts:      toAPIPosts(data.Posts),
+		Comments:   make([]exportComment, 0, len(data.Comments)),
+		Votes:      make([]exportVote, 0, len(data.Votes)),
+		Reports:    make([]exportReport, 0, len(data.Reports)),
+		Sessions:   make([]exportSession, 0, len(data.Sessions)),
+		Identities: make([]exportIdentity, 0, len(data.Identities)),
+	}
+
+	for _, c := range data.Comments {
+		export.Comments = append(export.Comments, exportComment{
+			Id:        c.CommentId,
+			PostId:    c.PostId,
+			ParentId:  c.ParentId,
+			Content:   c.Content,
+			Likes:     c.Likes,
+			Dislikes:  c.Dislikes,
+			Deleted:   c.Deleted,
+			UpdatedAt: optionalTime(c.UpdatedTime),
+		})
+	}
+	for _, v := range data.Votes {
+		export.Votes = append(export.Votes, exportVote{
+			TargetType: v.TargetType,
+			TargetId:   v.TargetId,
+			Value:      v.Value,
+			CreatedAt:  v.CreatedAt,
+		})
+	}
+	for _, r := range data.Reports {
+		export.Reports = append(export.Reports, exportReport{
+			Id:         r.ReportId,
+			TargetType: r.TargetType,
+			TargetId:   r.TargetId,
+			Reason:     r.Reason,
+			Details:    r.Details,
+			Status:     r.Status,
+			CreatedAt:  r.CreatedAt,
+		})
+	}
+	for _, s := range data.Sessions {
+		export.Sessions = append(export.Sessions, exportSession{
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+		})
+	}
+	for _, i := range data.Identities {
+		export.Identities = append(export.Identities, exportIdentity{
+			Provider:  i.Provider,
+			Email:     i.Email,
+			CreatedAt: i.CreatedAt,
+		})
+	}
+
+	return export
+}
@@ -1,15 +1,18 @@
 package controller
 
 import (
+	"forum/config"
 	"forum/internal/usecase"
 )
 
 type handler struct {
 	usecase *usecase.UseCase
+	cnf     *config.Config
 }
 
-func NewHandler(u *usecase.UseCase) *handler {
+func NewHandler(u *usecase.UseCase, cnf *config.Config) *handler {
 	return &handler{
 		usecase: u,
+		cnf:     cnf,
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"forum/internal/entity"
@@ -12,22 +13,49 @@ import (
 
 type ctxKey int8
 
-const ctxKeyUser ctxKey = iota
+const (
+	ctxKeyUser ctxKey = iota
+	ctxKeyCSRF
+)
+
+const sessionCookie = "session_cookie"
+
+// sessionToken returns the token from "Authorization: Bearer <token>" header
+// used by api clients or from the session cookie used by the browser
+func sessionToken(r *http.Request) (string, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if token == auth || token == "" {
+			return "", errors.New("invalid authorization header")
+		}
+		return token, nil
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
 
+// verification finds the user of the session and checks the CSRF token of
+// state-changing requests before next runs
 func (h *handler) verification(next http.HandlerFunc) http.HandlerFunc {
+	next = h.csrf(next)
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_cookie")
+		token, err := sessionToken(r)
 		if err != nil {
 			if errors.Is(err, http.ErrNoCookie) {
 				// log.Printf("error: no cookie\n")
 				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, entity.UserModel{})))
 				return
 			}
-			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			h.reject(w, r, http.StatusBadRequest, "invalid_authorization", err.Error())
 			return
 		}
 
-		user, err := h.usecase.ParseToken(cookie.Value)
+		user, renewed, err := h.usecase.ParseToken(token)
 		if err != nil {
 			// log.Printf("error: parse token %v\n", err)
 			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, entity.UserModel{})))
@@ -35,15 +63,65 @@ func (h *handler) verification(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		if user.ExpirationTime.Before(time.Now()) {
-			if err = h.usecase.DeleteToken(cookie.Value); err != nil {
+			if err = h.usecase.DeleteToken(token); err != nil {
 				log.Printf("middleware: delete token: %v\n", err)
-				h.errorHandler(w, http.StatusInternalServerError, err.Error())
+				h.reject(w, r, http.StatusInternalServerError, "internal_error", err.Error())
 				return
 			}
+			if r.Header.Get("Authorization") == "" {
+				h.clearSessionCookie(w)
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, entity.UserModel{})))
+			return
+		}
+
+		// the browser keeps the cookie as long as the session now lasts
+		if renewed && r.Header.Get("Authorization") == "" {
+			h.setSessionCookie(w, user)
+		}
 
+		// suspended users browse the forum as guests
+		if user.SuspendedUntil.After(time.Now()) {
 			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, entity.UserModel{})))
 			return
 		}
 		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyUser, user)))
 	}
 }
+
+// requirePermission lets the request through only when the user verified by
+// verification middleware has the permission
+func (h *handler) requirePermission(p entity.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+		if user == (entity.UserModel{}) {
+			h.reject(w, r, http.StatusUnauthorized, "unauthorized", "user unauthorized")
+			return
+		}
+
+		if !user.Can(p) {
+			h.reject(w, r, http.StatusForbidden, "forbidden", "permission denied")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// reject answers a request that is turned away, clients that read json get
+// the json error body with code and the others the error page
+func (h *handler) reject(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if wantsJSON(r) {
+		h.apiErrorHandler(w, status, code, message)
+		return
+	}
+	h.errorHandler(w, status, message)
+}
+
+// wantsJSON reports whether the client reads json: api requests and the
+// scripts of the pages that ask for it with Accept
+func wantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// moderationError writes the error page for errors returned by ModerationUsecase
+func (h *handler) moderationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+	case errors.Is(err, usecase.ErrUserNotFound):
+		h.errorHandler(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, usecase.ErrForbidden):
+		h.errorHandler(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, usecase.ErrInvalidRole):
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+	default:
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// lockPost locks a post for new comments, form field locked=false unlocks it
+func (h *handler) lockPost(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/moderation/post/lock/"))
+	if err != nil {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locked := r.Form.Get("locked") != "false"
+	if err := h.usecase.ModerationUsecase.LockPost(user, id, locked); err != nil {
+		h.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", id), http.StatusSeeOther)
+}
+
+// suspendUser suspends a user for the number of days in the form, 0 days lifts the suspension
+func (h *handler) suspendUser(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	username := strings.TrimPrefix(r.URL.Path, "/moderation/user/suspend/")
+
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	days, err := strconv.Atoi(r.Form.Get("days"))
+	if err != nil || days < 0 {
+		h.errorHandler(w, http.StatusBadRequest, "incorrect days field")
+		return
+	}
+
+	var until time.Time
+	if days > 0 {
+		until = time.Now().AddDate(0, 0, days)
+	}
+
+	if err := h.usecase.ModerationUsecase.SuspendUser(user, username, until); err != nil {
+		h.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/profile/"+username, http.StatusSeeOther)
+}
+
+func (h *handler) setUserRole(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	username := strings.TrimPrefix(r.URL.Path, "/moderation/user/role/")
+
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.usecase.ModerationUsecase.SetRole(user, username, r.Form.Get("role")); err != nil {
+		h.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/profile/"+username, http.StatusSeeOther)
+}
+
+// resetTwoFactor turns two-factor authentication off for a user who lost their codes
+func (h *handler) resetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	username := strings.TrimPrefix(r.URL.Path, "/moderation/user/2fa/reset/")
+
+	if err := h.usecase.ModerationUsecase.ResetTwoFactor(user, username); err != nil {
+		h.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/profile/"+username, http.StatusSeeOther)
+}
+
+func (h *handler) auditLog(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/moderation/audit" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	entries, err := h.usecase.ModerationUsecase.GetAuditLog(user)
+	if err != nil {
+		h.moderationError(w, err)
+		return
+	}
+
+	info := entity.Profile{
+		User:     user,
+		AuditLog: entries,
+	}
+	if err := h.execute(w, r, "ui/template/audit.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+	"forum/pkg/oauth"
+)
+
+// oauthCookie keeps provider, state and PKCE verifier between the redirect
+// to the provider and its callback
+const oauthCookie = "oauth_flow"
+
+// oauth serves /auth/oauth/{provider}, which sends the user to the provider,
+// and /auth/oauth/{provider}/callback where the provider sends them back
+func (h *handler) oauth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	provider := strings.TrimPrefix(r.URL.Path, "/auth/oauth/")
+	callback := strings.HasSuffix(provider, "/callback")
+	provider = strings.TrimSuffix(provider, "/callback")
+	if provider == "" || strings.Contains(provider, "/") {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if callback {
+		h.oauthCallback(w, r, provider)
+		return
+	}
+
+	state, err := oauth.RandomString()
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	verifier, err := oauth.RandomString()
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	target, err := h.usecase.AuthorizationUsecase.OAuthLoginURL(provider, state, verifier)
+	if err != nil {
+		if errors.Is(err, usecase.ErrUnknownProvider) {
+			h.errorHandler(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthCookie,
+		Value:    provider + "." + state + "." + verifier,
+		Path:     "/auth/oauth/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || h.cnf.CookieSecure,
+		// the callback is a top level redirect from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (h *handler) oauthCallback(w http.ResponseWriter, r *http.Request, provider string) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	cookie, err := r.Cookie(oauthCookie)
+	http.SetCookie(w, &http.Cookie{Name: oauthCookie, Value: "", Path: "/auth/oauth/", MaxAge: -1})
+	if err != nil {
+		h.errorHandler(w, http.StatusBadRequest, "sign-in expired, try again")
+		return
+	}
+
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		h.errorHandler(w, http.StatusBadRequest, "sign-in was cancelled: "+reason)
+		return
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || parts[0] != provider ||
+		subtle.ConstantTimeCompare([]byte(parts[1]), []byte(query.Get("state"))) != 1 {
+		h.errorHandler(w, http.StatusBadRequest, "sign-in state does not match, try again")
+		return
+	}
+
+	session, err := h.usecase.AuthorizationUsecase.OAuthLogin(provider, query.Get("code"), parts[2], user, clientOf(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnknownProvider):
+			h.errorHandler(w, http.StatusNotFound, usecase.ErrUnknownProvider.Error())
+		case errors.Is(err, usecase.ErrOAuthFailed):
+			log.Printf("error: %v", err)
+			h.errorHandler(w, http.StatusBadGateway, usecase.ErrOAuthFailed.Error())
+		case errors.Is(err, usecase.ErrIdentityTaken):
+			h.errorHandler(w, http.StatusConflict, usecase.ErrIdentityTaken.Error())
+		case errors.Is(err, usecase.ErrTwoFactorRequired):
+			h.startTwoFactor(w, r, err)
+		case errors.Is(err, usecase.ErrUserSuspended):
+			h.errorHandler(w, http.StatusForbidden, "Your account is suspended")
+		default:
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.setSessionCookie(w, session)
+
+	target := "/"
+	if user != (entity.UserModel{}) {
+		target = "/profile/" + session.Username
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
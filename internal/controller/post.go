@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -29,18 +30,31 @@ func (h *handler) createPost(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
+		categories, err := h.usecase.CategoriesUsecase.GetCategories()
+		if err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 		info := entity.Profile{
-			User: user,
+			User:       user,
+			Categories: categories,
 		}
-		if err := h.execute(w, "ui/template/createPost.html", info); err != nil {
+		if err := h.execute(w, r, "ui/template/createPost.html", info); err != nil {
 			h.errorHandler(w, http.StatusInternalServerError, err.Error())
 		}
 
 	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxPostRequest())
 		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				h.errorHandler(w, http.StatusRequestEntityTooLarge, "request is too large")
+				return
+			}
 			h.errorHandler(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		defer r.MultipartForm.RemoveAll()
 
 		title, ok := r.Form["title"]
 		if !ok {
@@ -65,12 +79,34 @@ func (h *handler) createPost(w http.ResponseWriter, r *http.Request) {
 			Category:   category,
 		}
 
-		if err := h.usecase.PostsUsecase.CreatePost(post); err != nil {
-			if errors.Is(err, usecase.ErrInvalidContent) ||
+		var uploads []entity.Upload
+		for _, header := range r.MultipartForm.File["attachments"] {
+			// an empty file input still sends a part without a name
+			if header.Filename == "" && header.Size == 0 {
+				continue
+			}
+			file, err := header.Open()
+			if err != nil {
+				h.errorHandler(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			defer file.Close()
+			uploads = append(uploads, entity.Upload{Filename: header.Filename, Content: file})
+		}
+
+		if _, err := h.usecase.PostsUsecase.CreatePost(post, uploads...); err != nil {
+			if errors.Is(err, usecase.ErrAttachmentTooLarge) {
+				h.errorHandler(w, http.StatusRequestEntityTooLarge, err.Error())
+				return
+			}
+			if errors.Is(err, usecase.ErrTooManyAttachments) ||
+				errors.Is(err, usecase.ErrUnsupportedAttachment) ||
+				errors.Is(err, usecase.ErrInvalidContent) ||
 				errors.Is(err, usecase.ErrInvalidContentLength) ||
 				errors.Is(err, usecase.ErrInvalidTitleLength) ||
 				errors.Is(err, usecase.ErrNoContent) ||
-				errors.Is(err, usecase.ErrNoTitle) {
+				errors.Is(err, usecase.ErrNoTitle) ||
+				errors.Is(err, usecase.ErrUnknownCategory) {
 				h.errorHandler(w, http.StatusBadRequest, err.Error())
 				return
 			}
@@ -84,58 +120,47 @@ func (h *handler) createPost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *handler) likePost(w http.ResponseWriter, r *http.Request) {
-	u := r.Context().Value(ctxKeyUser)
-	user := u.(entity.UserModel)
+// maxPostRequest limits the size of a new post: every attachment may use the
+// whole size limit, plus room for the text fields
+func (h *handler) maxPostRequest() int64 {
+	return h.cnf.MaxUploadSize*int64(h.cnf.MaxAttachments) + 1<<20
+}
 
+// previewPost renders the Markdown sent in the content field and writes it
+// as an HTML fragment for the post editor
+func (h *handler) previewPost(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
 	if user == (entity.UserModel{}) {
 		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
 		return
 	}
 
-	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/post/like/"))
-	if err != nil {
-		h.errorHandler(w, http.StatusNotFound, "incorrect path")
-		return
-	}
-
 	if r.Method != http.MethodPost {
 		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
 		return
 	}
 
-	if err := h.usecase.PostsVoterUsecase.LikePost(id, user.Username); err != nil {
-		h.errorHandler(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	http.Redirect(w, r, fmt.Sprintf("/post/%d", id), http.StatusSeeOther)
-}
-
-func (h *handler) disLikePost(w http.ResponseWriter, r *http.Request) {
-	u := r.Context().Value(ctxKeyUser)
-	user := u.(entity.UserModel)
-
-	if user == (entity.UserModel{}) {
-		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/post/dislike/"))
+	html, err := h.usecase.PostsUsecase.PreviewPost(r.PostForm.Get("content"))
 	if err != nil {
-		h.errorHandler(w, http.StatusNotFound, "incorrect path")
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
-		return
-	}
-
-	if err := h.usecase.PostsVoterUsecase.DisikePost(id, user.Username); err != nil {
+		if errors.Is(err, usecase.ErrInvalidContent) ||
+			errors.Is(err, usecase.ErrInvalidContentLength) ||
+			errors.Is(err, usecase.ErrNoContent) {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		h.errorHandler(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	http.Redirect(w, r, fmt.Sprintf("/post/%d", id), http.StatusSeeOther)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(w, html)
 }
 
 func (h *handler) postPage(w http.ResponseWriter, r *http.Request) {
@@ -186,17 +211,29 @@ func (h *handler) postPage(w http.ResponseWriter, r *http.Request) {
 			h.errorHandler(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		revisions, err := h.usecase.PostsUsecase.GetPostRevisions(post.PostId)
+		if err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if user != (entity.UserModel{}) {
+			post.Vote = voteOf(user.Username, postLikes, postDislikes)
+			markCommentVotes(comments, user.Username, commentsLikes, commentsDislikes)
+		}
 
 		info := entity.Profile{
 			Post:             post,
+			Revisions:        revisions,
 			PostLikes:        postLikes,
 			PostDislikes:     postDislikes,
 			User:             user,
 			Comments:         comments,
 			CommentsLikes:    commentsLikes,
 			CommentsDislikes: commentsDislikes,
+			ReportReasons:    entity.ReportReasons,
 		}
-		if err := h.execute(w, "ui/template/post.html", info); err != nil {
+		if err := h.execute(w, r, "ui/template/post.html", info); err != nil {
 			h.errorHandler(w, http.StatusInternalServerError, err.Error())
 		}
 
@@ -224,9 +261,22 @@ func (h *handler) postPage(w http.ResponseWriter, r *http.Request) {
 			PostId:  post.PostId,
 		}
 
-		if err := h.usecase.CommentsUsecase.CreateComment(nComment); err != nil {
+		if parent := r.Form.Get("parent_id"); parent != "" {
+			nComment.ParentId, err = strconv.Atoi(parent)
+			if err != nil {
+				h.errorHandler(w, http.StatusBadRequest, "incorrect parent_id field")
+				return
+			}
+		}
+
+		if _, err := h.usecase.CommentsUsecase.CreateComment(nComment); err != nil {
+			if errors.Is(err, usecase.ErrPostLocked) {
+				h.errorHandler(w, http.StatusForbidden, err.Error())
+				return
+			}
 			if errors.Is(err, usecase.ErrInvalidContentLength) ||
-				errors.Is(err, usecase.ErrInvalidCharacter) {
+				errors.Is(err, usecase.ErrInvalidCharacter) ||
+				errors.Is(err, usecase.ErrInvalidParent) {
 				h.errorHandler(w, http.StatusBadRequest, err.Error())
 				return
 			}
@@ -240,3 +290,141 @@ func (h *handler) postPage(w http.ResponseWriter, r *http.Request) {
 		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
 	}
 }
+
+func (h *handler) editPost(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxKeyUser)
+	user := u.(entity.UserModel)
+
+	if user == (entity.UserModel{}) {
+		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/post/edit/"))
+	if err != nil {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	post, err := h.usecase.PostsUsecase.GetPostsById(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.errorHandler(w, http.StatusNotFound, "incorrect path")
+			return
+		}
+
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if post.PostAuthor != user.Username {
+		h.errorHandler(w, http.StatusForbidden, usecase.ErrNotAuthor.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		categories, err := h.usecase.CategoriesUsecase.GetCategories()
+		if err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		info := entity.Profile{
+			User:       user,
+			Post:       post,
+			Categories: categories,
+		}
+		if err := h.execute(w, r, "ui/template/editPost.html", info); err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		title, ok := r.Form["title"]
+		if !ok {
+			h.errorHandler(w, http.StatusBadRequest, "title field not found ")
+			return
+		}
+		content, ok := r.Form["content"]
+		if !ok {
+			h.errorHandler(w, http.StatusBadRequest, "content field not found")
+			return
+		}
+		category, ok := r.Form["categories"]
+		if !ok {
+			h.errorHandler(w, http.StatusBadRequest, "empty category field")
+			return
+		}
+
+		post.Title = title[0]
+		post.Content = content[0]
+		post.Category = category
+
+		if err := h.usecase.PostsUsecase.UpdatePost(post, user); err != nil {
+			if errors.Is(err, usecase.ErrNotAuthor) {
+				h.errorHandler(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if errors.Is(err, usecase.ErrInvalidContent) ||
+				errors.Is(err, usecase.ErrInvalidContentLength) ||
+				errors.Is(err, usecase.ErrInvalidTitleLength) ||
+				errors.Is(err, usecase.ErrNoContent) ||
+				errors.Is(err, usecase.ErrNoTitle) ||
+				errors.Is(err, usecase.ErrUnknownCategory) {
+				h.errorHandler(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/post/%d", post.PostId), http.StatusSeeOther)
+
+	default:
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+	}
+}
+
+func (h *handler) deletePost(w http.ResponseWriter, r *http.Request) {
+	u := r.Context().Value(ctxKeyUser)
+	user := u.(entity.UserModel)
+
+	if user == (entity.UserModel{}) {
+		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/post/delete/"))
+	if err != nil {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	err = h.usecase.PostsUsecase.DeletePost(id, user)
+	if errors.Is(err, usecase.ErrNotAuthor) && user.Can(entity.PermDeleteAnyPost) {
+		err = h.usecase.ModerationUsecase.DeletePost(user, id)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.errorHandler(w, http.StatusNotFound, "incorrect path")
+			return
+		}
+		if errors.Is(err, usecase.ErrNotAuthor) {
+			h.errorHandler(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// reportContent files a report against /report/post/{id} or /report/comment/{id}
+func (h *handler) reportContent(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if user == (entity.UserModel{}) {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/report/"), "/")
+	if len(path) != 2 {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	targetType := path[0]
+	id, err := strconv.Atoi(path[1])
+	if err != nil || (targetType != entity.ReportTargetPost && targetType != entity.ReportTargetComment) {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	postId, err := strconv.Atoi(r.Form.Get("post_id"))
+	if err != nil {
+		h.errorHandler(w, http.StatusBadRequest, "incorrect post_id field")
+		return
+	}
+
+	report := entity.Report{
+		Reporter:   user.Username,
+		TargetType: targetType,
+		TargetId:   id,
+		Reason:     r.Form.Get("reason"),
+		Details:    r.Form.Get("details"),
+	}
+	if err := h.usecase.ReportsUsecase.CreateReport(report); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		case errors.Is(err, usecase.ErrAlreadyReported):
+			h.errorHandler(w, http.StatusConflict, "you have already reported this")
+		case errors.Is(err, usecase.ErrInvalidReport), errors.Is(err, usecase.ErrInvalidContentLength),
+			errors.Is(err, usecase.ErrCommentDeleted):
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+		default:
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", postId), http.StatusSeeOther)
+}
+
+func (h *handler) reportQueue(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/moderation/reports" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	groups, err := h.usecase.ReportsUsecase.GetReportQueue(user)
+	if err != nil {
+		h.moderationError(w, err)
+		return
+	}
+
+	info := entity.Profile{
+		User:    user,
+		Reports: groups,
+	}
+	if err := h.execute(w, r, "ui/template/reports.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// closeReports resolves or dismisses all open reports of one piece of content
+func (h *handler) closeReports(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/moderation/reports/close" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	targetId, err := strconv.Atoi(r.Form.Get("target_id"))
+	if err != nil {
+		h.errorHandler(w, http.StatusBadRequest, "incorrect target_id field")
+		return
+	}
+	targetType := r.Form.Get("target_type")
+
+	switch r.Form.Get("action") {
+	case "resolve":
+		err = h.usecase.ReportsUsecase.ResolveReports(user, targetType, targetId, r.Form.Get("delete") == "on")
+	case "dismiss":
+		err = h.usecase.ReportsUsecase.DismissReports(user, targetType, targetId)
+	default:
+		h.errorHandler(w, http.StatusBadRequest, "incorrect action field")
+		return
+	}
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidReport) {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.moderationError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/moderation/reports", http.StatusSeeOther)
+}
@@ -2,6 +2,8 @@ package controller
 
 import (
 	"net/http"
+
+	"forum/internal/entity"
 )
 
 func SetupRouter(h *handler) *http.ServeMux {
@@ -9,22 +11,67 @@ func SetupRouter(h *handler) *http.ServeMux {
 	styles := http.FileServer(http.Dir("ui/static/"))
 
 	router.Handle("/static/", http.StripPrefix("/static/", styles))
+	router.Handle(entity.UploadsPath, http.StripPrefix(entity.UploadsPath, h.uploads()))
 
 	router.HandleFunc("/", h.verification(h.Home))
 
+	router.HandleFunc("/search", h.verification(h.search))
+
 	router.HandleFunc("/auth/sign-up", h.verification(h.signUp))
 	router.HandleFunc("/auth/sign-in", h.verification(h.signIn))
+	router.HandleFunc("/auth/sign-in/2fa", h.verification(h.signInTwoFactor))
 
 	router.HandleFunc("/auth/logout", h.verification(h.logout))
+	router.HandleFunc("/auth/verify", h.verification(h.verifyEmail))
+	router.HandleFunc("/auth/verify/resend", h.verification(h.resendVerification))
+	router.HandleFunc("/auth/email/confirm", h.verification(h.confirmEmail))
+	router.HandleFunc("/auth/reset", h.verification(h.resetPassword))
+	router.HandleFunc("/auth/oauth/", h.verification(h.oauth))
+	router.HandleFunc("/auth/sessions", h.verification(h.sessions))
+	router.HandleFunc("/auth/sessions/revoke/", h.verification(h.revokeSession))
+	router.HandleFunc("/auth/2fa", h.verification(h.twoFactor))
+	router.HandleFunc("/auth/2fa/", h.verification(h.twoFactorAction))
 	router.HandleFunc("/post/create", h.verification(h.createPost))
-	router.HandleFunc("/post/like/", h.verification(h.likePost))
-	router.HandleFunc("/post/dislike/", h.verification(h.disLikePost))
+	router.HandleFunc("/post/preview", h.verification(h.previewPost))
+	router.HandleFunc("/post/vote/", h.verification(h.vote))
+	router.HandleFunc("/post/edit/", h.verification(h.editPost))
+	router.HandleFunc("/post/delete/", h.verification(h.deletePost))
 	router.HandleFunc("/post/", h.verification(h.postPage))
 
-	router.HandleFunc("/comment/like/", h.verification(h.likeComment))
-	router.HandleFunc("/comment/dislike/", h.verification(h.dislikeComment))
+	router.HandleFunc("/comment/vote/", h.verification(h.vote))
+	router.HandleFunc("/comment/edit/", h.verification(h.editComment))
+	router.HandleFunc("/comment/delete/", h.verification(h.deleteComment))
+
+	router.HandleFunc("/categories", h.verification(h.categories))
+	router.HandleFunc("/category/", h.verification(h.categoryPage))
+
+	router.HandleFunc("/report/", h.verification(h.reportContent))
 
 	router.HandleFunc("/profile/", h.verification(h.userProfile))
+	router.HandleFunc("/settings", h.verification(h.settings))
+	router.HandleFunc("/settings/export", h.verification(h.exportAccount))
+	router.HandleFunc("/settings/", h.verification(h.settingsAction))
+
+	router.HandleFunc("/moderation/post/lock/", h.verification(h.requirePermission(entity.PermLockThread, h.lockPost)))
+	router.HandleFunc("/moderation/user/suspend/", h.verification(h.requirePermission(entity.PermSuspendUser, h.suspendUser)))
+	router.HandleFunc("/moderation/user/role/", h.verification(h.requirePermission(entity.PermManageRoles, h.setUserRole)))
+	router.HandleFunc("/moderation/user/2fa/reset/", h.verification(h.requirePermission(entity.PermResetTwoFactor, h.resetTwoFactor)))
+	router.HandleFunc("/moderation/reports", h.verification(h.requirePermission(entity.PermHandleReports, h.reportQueue)))
+	router.HandleFunc("/moderation/reports/close", h.verification(h.requirePermission(entity.PermHandleReports, h.closeReports)))
+	router.HandleFunc("/admin/categories", h.verification(h.requirePermission(entity.PermManageCategories, h.adminCategories)))
+	router.HandleFunc("/admin/categories/create", h.verification(h.requirePermission(entity.PermManageCategories, h.saveCategory)))
+	router.HandleFunc("/admin/categories/update/", h.verification(h.requirePermission(entity.PermManageCategories, h.saveCategory)))
+	router.HandleFunc("/admin/categories/delete/", h.verification(h.requirePermission(entity.PermManageCategories, h.deleteCategory)))
+	router.HandleFunc("/moderation/audit", h.verification(h.requirePermission(entity.PermViewAuditLog, h.auditLog)))
+
+	// json api, authenticated by bearer token or session cookie
+	router.HandleFunc("/api/", h.apiNotFound)
+	router.HandleFunc("/api/v1/auth/sign-in", h.apiSignIn)
+	router.HandleFunc("/api/v1/auth/logout", h.verification(h.apiLogout))
+	router.HandleFunc("/api/v1/posts", h.verification(h.apiPosts))
+	router.HandleFunc("/api/v1/posts/", h.verification(h.apiPost))
+	router.HandleFunc("/api/v1/comments/", h.verification(h.apiCommentVote))
+	router.HandleFunc("/api/v1/profile/", h.verification(h.apiProfile))
 
 	return router
 }
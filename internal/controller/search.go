@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"net/http"
+
+	"forum/internal/entity"
+)
+
+// search shows posts and comments matching ?q=, see usecase.ParseSearchQuery for the syntax
+func (h *handler) search(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if status := h.CheckMethod("/search", http.MethodGet, r); status != http.StatusOK {
+		h.errorHandler(w, status, http.StatusText(status))
+		return
+	}
+
+	q := r.URL.Query().Get("q")
+	results, err := h.usecase.PostsUsecase.Search(q)
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	info := entity.Profile{
+		User:          user,
+		Search:        q,
+		SearchResults: results,
+	}
+	if err := h.execute(w, r, "ui/template/search.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// clientOf describes the client of a sign-in request for the sessions page
+func clientOf(r *http.Request) entity.Client {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return entity.Client{UserAgent: r.UserAgent(), IP: ip}
+}
+
+// setSessionCookie stores the session token in the browser until the session expires
+func (h *handler) setSessionCookie(w http.ResponseWriter, user entity.UserModel) {
+	cookie := h.cookie(sessionCookie, user.Token)
+	cookie.Expires = user.ExpirationTime
+	http.SetCookie(w, cookie)
+}
+
+func (h *handler) clearSessionCookie(w http.ResponseWriter) {
+	cookie := h.cookie(sessionCookie, "")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// cookie applies the cookie attributes of the configuration, scripts never
+// need to read the forum's cookies
+func (h *handler) cookie(name, value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   h.cnf.CookieDomain,
+		HttpOnly: true,
+		Secure:   h.cnf.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch h.cnf.CookieSameSite {
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		// browsers drop SameSite=None cookies without Secure
+		cookie.SameSite = http.SameSiteNoneMode
+		cookie.Secure = true
+	}
+	return cookie
+}
+
+// sessions lists where the user is signed in
+func (h *handler) sessions(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/auth/sessions" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+	if user == (entity.UserModel{}) {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusFound)
+		return
+	}
+
+	sessions, err := h.usecase.AuthorizationUsecase.GetSessions(user)
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	info := entity.Profile{
+		User:     user,
+		Sessions: sessions,
+	}
+	if err := h.execute(w, r, "ui/template/sessions.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// revokeSession signs out one session, /auth/sessions/revoke/all signs out all of them
+func (h *handler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+	if user == (entity.UserModel{}) {
+		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
+		return
+	}
+
+	target := strings.TrimPrefix(r.URL.Path, "/auth/sessions/revoke/")
+	if target == "all" {
+		if err := h.usecase.AuthorizationUsecase.RevokeAllSessions(user); err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		h.clearSessionCookie(w)
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return
+	}
+
+	id, err := strconv.Atoi(target)
+	if err != nil {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if err := h.usecase.AuthorizationUsecase.RevokeSession(user, id); err != nil {
+		if errors.Is(err, usecase.ErrSessionNotFound) {
+			h.errorHandler(w, http.StatusNotFound, usecase.ErrSessionNotFound.Error())
+			return
+		}
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if id == user.SessionId {
+		h.clearSessionCookie(w)
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/auth/sessions", http.StatusSeeOther)
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// settings shows the forms that change the profile, the email address and
+// the password of the logged in user
+func (h *handler) settings(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/settings" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+	if user == (entity.UserModel{}) {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusFound)
+		return
+	}
+
+	h.renderSettings(w, r, entity.Profile{User: user})
+}
+
+// settingsAction handles the forms of the settings page: /settings/profile,
+// /settings/email, /settings/password and /settings/delete
+func (h *handler) settingsAction(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+	if user == (entity.UserModel{}) {
+		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
+		return
+	}
+
+	info := entity.Profile{User: user}
+	var err error
+	switch strings.TrimPrefix(r.URL.Path, "/settings/") {
+	case "profile":
+		settings := entity.ProfileSettings{
+			DisplayName:  r.FormValue("display_name"),
+			Bio:          r.FormValue("bio"),
+			Website:      r.FormValue("website"),
+			RemoveAvatar: r.FormValue("remove_avatar") != "",
+		}
+
+		file, header, ferr := r.FormFile("avatar")
+		switch {
+		case ferr == nil:
+			defer file.Close()
+			// an empty file input still sends a part without a name
+			if header.Filename != "" || header.Size != 0 {
+				settings.Avatar = &entity.Upload{Filename: header.Filename, Content: file}
+			}
+		case !errors.Is(ferr, http.ErrMissingFile) && !errors.Is(ferr, http.ErrNotMultipart):
+			h.errorHandler(w, http.StatusBadRequest, ferr.Error())
+			return
+		}
+
+		var updated entity.UserModel
+		if updated, err = h.usecase.AuthorizationUsecase.UpdateProfile(user, settings); err == nil {
+			info.User = updated
+			info.Notice = "Your profile is saved."
+		}
+	case "email":
+		email := strings.TrimSpace(r.FormValue("email"))
+		err = h.usecase.AuthorizationUsecase.RequestEmailChange(user, email, r.FormValue("password"), clientOf(r))
+		if err == nil {
+			info.Notice = "We sent a confirmation link to " + email + ". Your address changes once you open it."
+		}
+	case "password":
+		var session entity.UserModel
+		session, err = h.usecase.AuthorizationUsecase.ChangePassword(user, r.FormValue("current_password"),
+			r.FormValue("password"), r.FormValue("confirm_password"), clientOf(r))
+		if err == nil {
+			// the other sessions ended with the old password, this one goes on with a new token
+			h.setSessionCookie(w, session)
+			info.Notice = "Your password is changed, your other sessions are signed out."
+		}
+	case "delete":
+		err = h.usecase.AuthorizationUsecase.DeleteAccount(user, r.FormValue("mode"), r.FormValue("confirm_username"),
+			r.FormValue("password"), clientOf(r))
+		if err == nil {
+			h.clearSessionCookie(w)
+			notice := entity.Profile{Notice: "Your account is deleted."}
+			if err := h.execute(w, r, "ui/template/notice.html", notice); err != nil {
+				h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+	default:
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if err != nil {
+		var weak *usecase.PasswordError
+		switch {
+		case errors.As(err, &weak):
+			w.WriteHeader(http.StatusBadRequest)
+			info.FormError = weakPassword(err)
+		case errors.Is(err, usecase.ErrInvalidPassword):
+			log.Printf("error: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			info.FormError = "Your current password is not correct"
+		case errors.Is(err, usecase.ErrTooManyAttempts):
+			log.Printf("error: %v", err)
+			info.FormError = tooManyAttempts(w, err)
+		case errors.Is(err, usecase.ErrConfirmPassword):
+			w.WriteHeader(http.StatusBadRequest)
+			info.FormError = "The new passwords are not the same"
+		case errors.Is(err, usecase.ErrInvalidEmail):
+			w.WriteHeader(http.StatusBadRequest)
+			info.FormError = "Enter a valid email address"
+		case errors.Is(err, usecase.ErrEmailExist):
+			w.WriteHeader(http.StatusBadRequest)
+			info.FormError = "User with this email already exists"
+		case errors.Is(err, usecase.ErrAvatarTooLarge):
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			info.FormError = "The avatar is too large"
+		case errors.Is(err, usecase.ErrSameEmail),
+			errors.Is(err, usecase.ErrInvalidDisplayName),
+			errors.Is(err, usecase.ErrInvalidBio),
+			errors.Is(err, usecase.ErrInvalidWebsite),
+			errors.Is(err, usecase.ErrInvalidAvatar),
+			errors.Is(err, usecase.ErrInvalidDeleteMode),
+			errors.Is(err, usecase.ErrConfirmDelete):
+			w.WriteHeader(http.StatusBadRequest)
+			info.FormError = capitalize(errors.Unwrap(err).Error())
+		default:
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	h.renderSettings(w, r, info)
+}
+
+// confirmEmail switches the account to the new address of the link sent from
+// the settings page
+func (h *handler) confirmEmail(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/auth/email/confirm" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+
+	info := entity.Profile{
+		User:   r.Context().Value(ctxKeyUser).(entity.UserModel),
+		Notice: "Your new email address is confirmed.",
+	}
+	if err := h.usecase.AuthorizationUsecase.ConfirmEmailChange(r.URL.Query().Get("token")); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidToken):
+			info.Notice = "This link is invalid, already used or expired. You can change the address again in your settings."
+		case errors.Is(err, usecase.ErrEmailExist):
+			info.Notice = "Another account uses this address now, your address stays the same."
+		default:
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	if err := h.execute(w, r, "ui/template/notice.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h *handler) renderSettings(w http.ResponseWriter, r *http.Request, info entity.Profile) {
+	if err := h.execute(w, r, "ui/template/settings.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// capitalize turns a usecase error message into a sentence for a form
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
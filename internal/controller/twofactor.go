@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// twoFactorCookie carries the challenge of a sign-in from the password to the code
+const twoFactorCookie = "two_factor"
+
+// startTwoFactor keeps the challenge of a sign-in that needs a code and sends
+// the browser to the code form
+func (h *handler) startTwoFactor(w http.ResponseWriter, r *http.Request, err error) {
+	var required *usecase.TwoFactorError
+	if !errors.As(err, &required) {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cookie := h.cookie(twoFactorCookie, required.Challenge)
+	cookie.Path = "/auth/sign-in/2fa"
+	cookie.MaxAge = 300
+	// the sign-in with a provider ends in a redirect from the provider's site
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(w, cookie)
+
+	http.Redirect(w, r, "/auth/sign-in/2fa", http.StatusSeeOther)
+}
+
+func (h *handler) clearTwoFactorCookie(w http.ResponseWriter) {
+	cookie := h.cookie(twoFactorCookie, "")
+	cookie.Path = "/auth/sign-in/2fa"
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// signInTwoFactor asks for the code of the authenticator app, or a recovery
+// code, after the password was right
+func (h *handler) signInTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/auth/sign-in/2fa" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if user != (entity.UserModel{}) {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	cookie, err := r.Cookie(twoFactorCookie)
+	if err != nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if err := h.execute(w, r, "ui/template/twoFactorSignIn.html", entity.Profile{}); err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			h.errorHandler(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		session, err := h.usecase.AuthorizationUsecase.CompleteTwoFactor(cookie.Value, r.Form.Get("code"), clientOf(r))
+		if err != nil {
+			info := entity.Profile{}
+			switch {
+			case errors.Is(err, usecase.ErrInvalidCode):
+				log.Printf("error: %v", err)
+				info.FormError = "The code is not correct"
+			case errors.Is(err, usecase.ErrTooManyAttempts):
+				log.Printf("error: %v", err)
+				info.FormError = tooManyAttempts(w, err)
+			case errors.Is(err, usecase.ErrInvalidToken):
+				h.clearTwoFactorCookie(w)
+				info := entity.Profile{
+					User:           entity.UserModel{Password: "The sign-in has expired, please log in again"},
+					OAuthProviders: h.usecase.AuthorizationUsecase.OAuthProviders(),
+				}
+				if err := h.execute(w, r, "ui/template/login.html", info); err != nil {
+					h.errorHandler(w, http.StatusInternalServerError, err.Error())
+				}
+				return
+			case errors.Is(err, usecase.ErrUserSuspended):
+				h.clearTwoFactorCookie(w)
+				h.errorHandler(w, http.StatusForbidden, "Your account is suspended")
+				return
+			default:
+				h.errorHandler(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			if err := h.execute(w, r, "ui/template/twoFactorSignIn.html", info); err != nil {
+				h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+
+		h.clearTwoFactorCookie(w)
+		h.setSessionCookie(w, session)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	default:
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+	}
+}
+
+// twoFactor shows whether two-factor authentication is on and the forms to
+// set it up, turn it off and replace the recovery codes
+func (h *handler) twoFactor(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.URL.Path != "/auth/2fa" {
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+	if r.Method != http.MethodGet {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+	if user == (entity.UserModel{}) {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusFound)
+		return
+	}
+
+	h.renderTwoFactor(w, r, user, nil, "")
+}
+
+// twoFactorAction handles the forms of the two-factor page:
+// /auth/2fa/setup, /auth/2fa/enable, /auth/2fa/disable and /auth/2fa/recovery
+func (h *handler) twoFactorAction(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if r.Method != http.MethodPost {
+		h.errorHandler(w, http.StatusMethodNotAllowed, "incorrect method")
+		return
+	}
+	if user == (entity.UserModel{}) {
+		h.errorHandler(w, http.StatusUnauthorized, "user unauthorized")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.errorHandler(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	code := r.Form.Get("code")
+
+	var (
+		codes []string
+		err   error
+	)
+	switch strings.TrimPrefix(r.URL.Path, "/auth/2fa/") {
+	case "setup":
+		_, err = h.usecase.AuthorizationUsecase.BeginTwoFactor(user)
+	case "enable":
+		codes, err = h.usecase.AuthorizationUsecase.EnableTwoFactor(user, code)
+	case "disable":
+		err = h.usecase.AuthorizationUsecase.DisableTwoFactor(user, code, clientOf(r))
+	case "recovery":
+		codes, err = h.usecase.AuthorizationUsecase.RegenerateRecoveryCodes(user, code, clientOf(r))
+	default:
+		h.errorHandler(w, http.StatusNotFound, "incorrect path")
+		return
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidCode):
+			h.renderTwoFactor(w, r, user, nil, "The code is not correct")
+		case errors.Is(err, usecase.ErrTooManyAttempts):
+			h.renderTwoFactor(w, r, user, nil, tooManyAttempts(w, err))
+		case errors.Is(err, usecase.ErrTwoFactorEnabled),
+			errors.Is(err, usecase.ErrTwoFactorDisabled):
+			h.renderTwoFactor(w, r, user, nil, err.Error())
+		default:
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if codes != nil {
+		h.renderTwoFactor(w, r, user, codes, "")
+		return
+	}
+	http.Redirect(w, r, "/auth/2fa", http.StatusSeeOther)
+}
+
+func (h *handler) renderTwoFactor(w http.ResponseWriter, r *http.Request, user entity.UserModel, codes []string, formError string) {
+	status, err := h.usecase.AuthorizationUsecase.TwoFactorStatus(user)
+	if err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	info := entity.Profile{
+		User:          user,
+		TwoFactor:     status,
+		RecoveryCodes: codes,
+		FormError:     formError,
+	}
+	if err := h.execute(w, r, "ui/template/twoFactor.html", info); err != nil {
+		h.errorHandler(w, http.StatusInternalServerError, err.Error())
+	}
+}
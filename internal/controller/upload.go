@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+)
+
+// uploads serves stored attachments, directory listings are not shown
+func (h *handler) uploads() http.Handler {
+	files := http.FileServer(http.Dir(h.cnf.UploadDir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			h.errorHandler(w, http.StatusNotFound, "incorrect path")
+			return
+		}
+
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -32,7 +32,7 @@ func (h *handler) userProfile(w http.ResponseWriter, r *http.Request) {
 			ProfileUser: userP,
 		}
 	} else {
-		posts, err := h.usecase.UsersUsecase.GetPostsByName(userP.Username, r.URL.Query())
+		page, err := h.usecase.UsersUsecase.GetPostsByName(userP.Username, r.URL.Query())
 		if err != nil {
 			if errors.Is(err, usecase.ErrInvalidQuery) {
 				h.errorHandler(w, http.StatusBadRequest, err.Error())
@@ -41,14 +41,26 @@ func (h *handler) userProfile(w http.ResponseWriter, r *http.Request) {
 			h.errorHandler(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		first, next := pageLinks(r, page.Next)
 		info = entity.Profile{
 			User:        user,
 			ProfileUser: userP,
-			Posts:       posts,
+			Posts:       page.Posts,
+			FirstPage:   first,
+			NextPage:    next,
 		}
 	}
 
-	if err := h.execute(w, "ui/template/profile.html", info); err != nil {
+	if user.Username == userP.Username {
+		info.Identities, err = h.usecase.AuthorizationUsecase.GetIdentities(user.UserId)
+		if err != nil {
+			h.errorHandler(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		info.OAuthProviders = h.usecase.AuthorizationUsecase.OAuthProviders()
+	}
+
+	if err := h.execute(w, r, "ui/template/profile.html", info); err != nil {
 		h.errorHandler(w, http.StatusInternalServerError, err.Error())
 	}
 }
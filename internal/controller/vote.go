@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/usecase"
+)
+
+// apiVote is the answer to a vote, in the api and to scripts on the post page
+type apiVote struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+	Vote     int `json:"vote"`
+}
+
+// vote handles POST /post/vote/{id} and /comment/vote/{id} with value 1, 0
+// or -1 in the form. Browsers are sent back to the post, clients that accept
+// JSON get the new counts or the json error body.
+func (h *handler) vote(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(ctxKeyUser).(entity.UserModel)
+
+	if user == (entity.UserModel{}) {
+		h.reject(w, r, http.StatusUnauthorized, "unauthorized", "user unauthorized")
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.reject(w, r, http.StatusMethodNotAllowed, "method_not_allowed", "incorrect method")
+		return
+	}
+
+	target, rawId := "post", strings.TrimPrefix(r.URL.Path, "/post/vote/")
+	if strings.HasPrefix(r.URL.Path, "/comment/vote/") {
+		target, rawId = "comment", strings.TrimPrefix(r.URL.Path, "/comment/vote/")
+	}
+	id, err := strconv.Atoi(rawId)
+	if err != nil {
+		h.reject(w, r, http.StatusNotFound, "not_found", "incorrect path")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.reject(w, r, http.StatusBadRequest, "invalid_body", err.Error())
+		return
+	}
+	value, err := strconv.Atoi(r.PostForm.Get("value"))
+	if err != nil {
+		h.reject(w, r, http.StatusBadRequest, "invalid_vote", usecase.ErrInvalidVote.Error())
+		return
+	}
+
+	postId := id
+	var count entity.VoteCount
+	if target == "comment" {
+		var comment entity.Comments
+		comment, err = h.usecase.CommentsUsecase.GetCommentById(id)
+		if err != nil {
+			h.voteError(w, r, err)
+			return
+		}
+		postId = comment.PostId
+		count, err = h.usecase.CommentsUsecase.Vote(id, user.Username, value)
+	} else {
+		count, err = h.usecase.PostsVoterUsecase.Vote(id, user.Username, value)
+	}
+	if err != nil {
+		h.voteError(w, r, err)
+		return
+	}
+
+	if wantsJSON(r) {
+		h.writeJSON(w, http.StatusOK, toAPIVote(count))
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/post/%d", postId), http.StatusSeeOther)
+}
+
+// voteError answers a failed vote, with the json error body when the client
+// asked for json
+func (h *handler) voteError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		h.reject(w, r, http.StatusNotFound, "not_found", "incorrect path")
+	case errors.Is(err, usecase.ErrInvalidVote):
+		h.reject(w, r, http.StatusBadRequest, "invalid_vote", usecase.ErrInvalidVote.Error())
+	default:
+		h.reject(w, r, http.StatusInternalServerError, "internal_error", err.Error())
+	}
+}
+
+func toAPIVote(count entity.VoteCount) apiVote {
+	return apiVote{
+		Likes:    count.Likes,
+		Dislikes: count.Dislikes,
+		Vote:     count.Vote,
+	}
+}
+
+// voteOf is the vote of username according to the users who liked and disliked
+func voteOf(username string, likes, dislikes []string) int {
+	for _, u := range likes {
+		if u == username {
+			return entity.VoteUp
+		}
+	}
+	for _, u := range dislikes {
+		if u == username {
+			return entity.VoteDown
+		}
+	}
+	return entity.VoteNone
+}
+
+// markCommentVotes fills in the votes of username on a comment thread
+func markCommentVotes(comments []entity.Comments, username string, likes, dislikes map[int][]string) {
+	for i := range comments {
+		comments[i].Vote = voteOf(username, likes[comments[i].CommentId], dislikes[comments[i].CommentId])
+		markCommentVotes(comments[i].Replies, username, likes, dislikes)
+		markCommentVotes(comments[i].CollapsedReplies, username, likes, dislikes)
+	}
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// DeletedUser is the author of the posts and comments a deleted account left behind
+const DeletedUser = "[deleted user]"
+
+// what happens to the posts and comments of a deleted account: DeleteAnonymize
+// keeps them under DeletedUser, DeleteRemove removes the posts and blanks the
+// comments like their author deleting them
+const (
+	DeleteAnonymize = "anonymize"
+	DeleteRemove    = "remove"
+)
+
+// Vote is one vote of a user on a post or a comment
+type Vote struct {
+	TargetType string
+	TargetId   int
+	Value      int
+	CreatedAt  time.Time
+}
+
+// AccountData is everything the forum keeps about a user, for the export
+// of their personal data
+type AccountData struct {
+	User       UserModel
+	Posts      []Post
+	Comments   []Comments
+	Votes      []Vote
+	Reports    []Report
+	Sessions   []Session
+	Identities []Identity
+	TwoFactor  bool
+}
@@ -0,0 +1,37 @@
+package entity
+
+import (
+	"io"
+	"time"
+)
+
+// UploadsPath is the url prefix uploaded files are served under
+const UploadsPath = "/uploads/"
+
+// Attachment is an image uploaded with a post
+type Attachment struct {
+	AttachmentId int
+	PostId       int
+	Filename     string
+	Thumbnail    string
+	OriginalName string
+	ContentType  string
+	Size         int64
+	Width        int
+	Height       int
+	CreatedAt    time.Time
+}
+
+func (a Attachment) URL() string {
+	return UploadsPath + a.Filename
+}
+
+func (a Attachment) ThumbnailURL() string {
+	return UploadsPath + a.Thumbnail
+}
+
+// Upload is a file a user sends along with a post
+type Upload struct {
+	Filename string
+	Content  io.Reader
+}
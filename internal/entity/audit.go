@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+const (
+	AuditDeletePost     = "delete_post"
+	AuditDeleteComment  = "delete_comment"
+	AuditLockPost       = "lock_post"
+	AuditUnlockPost     = "unlock_post"
+	AuditSuspendUser    = "suspend_user"
+	AuditUnsuspendUser  = "unsuspend_user"
+	AuditSetRole        = "set_role"
+	AuditResolveReport  = "resolve_report"
+	AuditDismissReport  = "dismiss_report"
+	AuditCreateCategory = "create_category"
+	AuditUpdateCategory = "update_category"
+	AuditDeleteCategory = "delete_category"
+	AuditReconcileVotes = "reconcile_votes"
+	AuditResetTwoFactor = "reset_two_factor"
+)
+
+// AuditEntry records an action done by a moderator or an administrator
+type AuditEntry struct {
+	AuditId    int
+	Actor      string
+	Action     string
+	TargetType string
+	TargetId   string
+	Details    string
+	CreatedAt  time.Time
+}
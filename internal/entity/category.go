@@ -0,0 +1,12 @@
+package entity
+
+// Category groups posts, posts_category refers to it by Name
+type Category struct {
+	CategoryId  int
+	Slug        string
+	Name        string
+	Description string
+	// Position orders categories in lists, lower first
+	Position  int
+	PostCount int
+}
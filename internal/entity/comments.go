@@ -1,10 +1,25 @@
 package entity
 
+import "time"
+
+// DeletedComment replaces the content of a comment removed by its author
+const DeletedComment = "[deleted]"
+
 type Comments struct {
-	CommentId int
-	PostId    int
-	Content   string
-	Author    string
-	Likes     int
-	Dislikes  int
+	CommentId   int
+	PostId      int
+	Content     string
+	ContentHTML string // Content rendered from Markdown when the comment was saved
+	Author      string
+	Likes       int
+	Dislikes    int
+	Vote        int // vote of the user viewing the comment, see VoteUp
+	Deleted     bool
+	UpdatedTime time.Time
+	ParentId    int
+
+	// filled when comments are arranged into a thread
+	Depth            int
+	Replies          []Comments
+	CollapsedReplies []Comments
 }
@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// PostFilter selects and orders the posts of a feed, zero fields do not filter
+type PostFilter struct {
+	// Categories keeps posts tagged with any of them
+	Categories []string
+	Author     string
+	// From is inclusive, To is exclusive
+	From time.Time
+	To   time.Time
+	// MinScore keeps posts whose likes minus dislikes reach it, when HasMinScore is set
+	MinScore    int
+	HasMinScore bool
+	Order       PostOrder
+}
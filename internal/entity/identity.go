@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// Identity links a user to their account at an OAuth provider
+type Identity struct {
+	IdentityId int
+	UserId     int
+	Provider   string
+	Subject    string
+	Email      string
+	CreatedAt  time.Time
+}
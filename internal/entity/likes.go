@@ -6,3 +6,35 @@ type Likes struct {
 	Liked       int // bool?
 	Disliked    int
 }
+
+// values of a vote on a post or a comment, VoteNone takes a vote back
+const (
+	VoteDown = -1
+	VoteNone = 0
+	VoteUp   = 1
+)
+
+// VoteCount is what a post or comment looks like after a vote, Vote is the
+// value of the user who voted
+type VoteCount struct {
+	Likes    int
+	Dislikes int
+	Vote     int
+}
+
+// what a row of the votes table points at
+const (
+	VoteTargetPost    = "post"
+	VoteTargetComment = "comment"
+)
+
+// VoteDrift is a post or comment whose stored counters differ from its vote
+// rows, the Stored values are what the counters said before reconciling
+type VoteDrift struct {
+	TargetType     string
+	TargetId       int
+	StoredLikes    int
+	StoredDislikes int
+	Likes          int
+	Dislikes       int
+}
@@ -0,0 +1,113 @@
+package entity
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxPageSize caps the number of posts a client may ask for at once
+const MaxPageSize = 100
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PostOrder is the order a post feed is listed in
+type PostOrder string
+
+const (
+	OrderNewest       PostOrder = "new"
+	OrderOldest       PostOrder = "old"
+	OrderMostLiked    PostOrder = "like"
+	OrderMostDisliked PostOrder = "dislike"
+	OrderTopScore     PostOrder = "score"
+)
+
+// ValidPostOrder reports whether order is one of the known feed orders
+func ValidPostOrder(order PostOrder) bool {
+	switch order {
+	case OrderNewest, OrderOldest, OrderMostLiked, OrderMostDisliked, OrderTopScore:
+		return true
+	}
+	return false
+}
+
+// Cursor points at the last post of a page, the next page starts right after it.
+// Only the field the feed is ordered by is compared, PostId breaks ties.
+type Cursor struct {
+	Time   time.Time
+	Votes  int
+	PostId int
+}
+
+// PageRequest asks for at most Limit posts after Cursor, a zero cursor is the first page
+type PageRequest struct {
+	Limit  int
+	Cursor Cursor
+}
+
+// PostPage is one page of a feed, Next is empty on the last page
+type PostPage struct {
+	Posts []Post
+	Next  string
+}
+
+func (c Cursor) IsZero() bool {
+	return c == Cursor{}
+}
+
+// String encodes the cursor as an opaque url safe token
+func (c Cursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	raw := fmt.Sprintf("%d|%d|%d", c.Time.Unix(), c.Votes, c.PostId)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a token made by Cursor.String, an empty token is the zero cursor
+func ParseCursor(token string) (Cursor, error) {
+	if token == "" {
+		return Cursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var values [3]int64
+	for i, part := range parts {
+		values[i], err = strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+	}
+
+	return Cursor{
+		Time:   time.Unix(values[0], 0).UTC(),
+		Votes:  int(values[1]),
+		PostId: int(values[2]),
+	}, nil
+}
+
+// CursorAfter returns the cursor that continues a feed in order after post
+func CursorAfter(post Post, order PostOrder) Cursor {
+	cursor := Cursor{Time: post.CreationTime.UTC(), PostId: post.PostId}
+	switch order {
+	case OrderMostLiked:
+		cursor.Votes = post.Likes
+	case OrderMostDisliked:
+		cursor.Votes = post.Dislikes
+	case OrderTopScore:
+		cursor.Votes = post.Likes - post.Dislikes
+	}
+	return cursor
+}
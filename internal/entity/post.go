@@ -7,8 +7,40 @@ type Post struct {
 	PostAuthor   string
 	Title        string
 	Content      string
+	ContentHTML  string // Content rendered from Markdown when the post was saved
 	CreationTime time.Time
+	UpdatedTime  time.Time
 	Category     []string
 	Likes        int
 	Dislikes     int
+	Vote         int // vote of the user viewing the post, see VoteUp
+	Locked       bool
+	Attachments  []Attachment
+}
+
+// HasCategory reports whether the post is tagged with category
+func (p Post) HasCategory(category string) bool {
+	for _, c := range p.Category {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// PostRevision is a version of a post as it was before an edit
+type PostRevision struct {
+	RevisionId int
+	PostId     int
+	Title      string
+	Content    string
+	Category   []string
+	EditedAt   time.Time
+	Diff       []DiffLine
+}
+
+// DiffLine is one line of a line based diff, Op is "+", "-" or " "
+type DiffLine struct {
+	Op   string
+	Text string
 }
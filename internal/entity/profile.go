@@ -4,6 +4,7 @@ type Profile struct {
 	User             UserModel
 	ProfileUser      UserModel
 	Post             Post
+	Revisions        []PostRevision
 	PostsCount       int
 	Posts            []Post
 	PostLikes        []string
@@ -11,4 +12,27 @@ type Profile struct {
 	Comments         []Comments
 	CommentsLikes    map[int][]string
 	CommentsDislikes map[int][]string
+	AuditLog         []AuditEntry
+	Reports          []ReportGroup
+	ReportReasons    []string
+	Categories       []Category
+	Category         Category
+	FirstPage        string
+	NextPage         string
+	Search           string
+	SearchResults    []SearchResult
+	// Notice is a one line message for notice.html, Token and FormError
+	// belong to the password reset form
+	Notice    string
+	Token     string
+	FormError string
+	// OAuthProviders are the enabled sign-in providers, Identities the ones
+	// linked to the user
+	OAuthProviders []string
+	Identities     []Identity
+	Sessions       []Session
+	// TwoFactor is the state of two-factor authentication, RecoveryCodes the
+	// codes shown once after they were made
+	TwoFactor     TwoFactorStatus
+	RecoveryCodes []string
 }
@@ -0,0 +1,57 @@
+package entity
+
+import "time"
+
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// ReportReasons are the categories a reader can choose when flagging content
+var ReportReasons = []string{"spam", "harassment", "off-topic", "inappropriate", "other"}
+
+// ValidReportReason reports whether reason is one of ReportReasons
+func ValidReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Report is a flag on a post or a comment submitted by a reader
+type Report struct {
+	ReportId   int
+	Reporter   string
+	TargetType string
+	TargetId   int
+	Reason     string
+	Details    string
+	Status     string
+	CreatedAt  time.Time
+
+	// PostId and Preview describe the reported content for the queue
+	PostId  int
+	Preview string
+}
+
+// ReportGroup collects the open reports of one post or comment
+type ReportGroup struct {
+	TargetType string
+	TargetId   int
+	PostId     int
+	Preview    string
+	Count      int
+	Reasons    []ReasonCount
+	Reports    []Report
+}
+
+type ReasonCount struct {
+	Reason string
+	Count  int
+}
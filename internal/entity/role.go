@@ -0,0 +1,81 @@
+package entity
+
+const (
+	RoleUser          = "user"
+	RoleModerator     = "moderator"
+	RoleAdministrator = "administrator"
+)
+
+type Permission string
+
+const (
+	PermDeleteAnyPost    Permission = "delete_any_post"
+	PermDeleteAnyComment Permission = "delete_any_comment"
+	PermLockThread       Permission = "lock_thread"
+	PermSuspendUser      Permission = "suspend_user"
+	PermViewAuditLog     Permission = "view_audit_log"
+	PermManageRoles      Permission = "manage_roles"
+	PermHandleReports    Permission = "handle_reports"
+	PermManageCategories Permission = "manage_categories"
+	PermResetTwoFactor   Permission = "reset_two_factor"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser: {},
+	RoleModerator: {
+		PermDeleteAnyPost,
+		PermDeleteAnyComment,
+		PermLockThread,
+		PermSuspendUser,
+		PermViewAuditLog,
+		PermHandleReports,
+	},
+	RoleAdministrator: {
+		PermDeleteAnyPost,
+		PermDeleteAnyComment,
+		PermLockThread,
+		PermSuspendUser,
+		PermViewAuditLog,
+		PermHandleReports,
+		PermManageRoles,
+		PermManageCategories,
+		PermResetTwoFactor,
+	},
+}
+
+var roleRank = map[string]int{
+	RoleUser:          0,
+	RoleModerator:     1,
+	RoleAdministrator: 2,
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can reports whether the user's role grants permission p
+func (u UserModel) Can(p Permission) bool {
+	for _, perm := range rolePermissions[u.Role] {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks reports whether the user's role is higher than other's role
+func (u UserModel) Outranks(other UserModel) bool {
+	return roleRank[u.Role] > roleRank[other.Role]
+}
+
+// IsModerator is used by templates to show moderation controls
+func (u UserModel) IsModerator() bool {
+	return roleRank[u.Role] >= roleRank[RoleModerator]
+}
+
+// IsAdministrator is used by templates to show administration controls
+func (u UserModel) IsAdministrator() bool {
+	return u.Role == RoleAdministrator
+}
@@ -0,0 +1,37 @@
+package entity
+
+// search snippets wrap matched words with these markers, templates turn them into <mark>
+const (
+	SearchMatchStart = "\x02"
+	SearchMatchEnd   = "\x03"
+)
+
+const (
+	SearchResultPost    = "post"
+	SearchResultComment = "comment"
+)
+
+// SearchQuery is a parsed search string: plain words, "quoted phrases",
+// author:name and category:name filters
+type SearchQuery struct {
+	Raw      string
+	Terms    []string
+	Phrases  []string
+	Author   string
+	Category string
+}
+
+// IsEmpty reports whether the query has nothing to search or filter by
+func (q SearchQuery) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 && q.Author == "" && q.Category == ""
+}
+
+// SearchResult is a post or a comment matching a search query
+type SearchResult struct {
+	Kind      string
+	PostId    int
+	CommentId int
+	Title     string
+	Author    string
+	Snippet   string
+}
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// Session is one signed in browser or api client of a user. Only the hash of
+// the session token is stored, Current marks the session of the request.
+type Session struct {
+	SessionId  int
+	UserId     int
+	TokenHash  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	UserAgent  string
+	IP         string
+	Current    bool
+}
+
+// Client describes where a sign-in comes from
+type Client struct {
+	UserAgent string
+	IP        string
+}
@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// purposes of the single-use tokens sent to users by email, TokenTwoFactor
+// is kept in a cookie between the password and the code of a sign-in.
+// The Email of a TokenChangeEmail is the new address.
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	TokenTwoFactor     = "two_factor"
+	TokenChangeEmail   = "change_email"
+)
+
+// UserToken is a single-use, time-limited token sent to a user by email.
+// Only the hash of the token is stored, Email is the address it was sent to.
+type UserToken struct {
+	TokenHash string
+	UserId    int
+	Purpose   string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
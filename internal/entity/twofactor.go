@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// TwoFactor is the TOTP secret of a user, it is in use once ConfirmedAt is
+// set. LastStep is the time step of the last accepted code.
+type TwoFactor struct {
+	UserId      int
+	Secret      string
+	ConfirmedAt time.Time
+	LastStep    int64
+}
+
+func (t TwoFactor) Enabled() bool {
+	return !t.ConfirmedAt.IsZero()
+}
+
+// TwoFactorStatus is what the two-factor page shows: whether it is on, how
+// many recovery codes are left and, while setting it up, the secret and the
+// otpauth URI for the authenticator app
+type TwoFactorStatus struct {
+	Enabled       bool
+	RecoveryCodes int
+	Secret        string
+	URI           string
+}
@@ -5,16 +5,53 @@ import "time"
 type UserModel struct {
 	UserId          int
 	Email           string
+	EmailVerified   bool
 	Username        string
 	Password        string
 	ConfirmPassword string
 	Posts           int
 	CreatedAt       time.Time
+	Role            string
+	SuspendedUntil  time.Time
+	// DisplayName, Bio, Avatar and Website are the public profile, Avatar is
+	// the name of the image in the uploads
+	DisplayName string
+	Bio         string
+	Avatar      string
+	Website     string
 
+	// Token is the session token of the request, SessionId its session
 	Token          string
+	SessionId      int
 	ExpirationTime time.Time
 	Emailcheck     string
 	Usernamecheck  string
 	// Passwordcheck        string
 	// ConfirmPasswordcheck string
 }
+
+// Name is the display name of the user, the username when there is none
+func (u UserModel) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
+}
+
+// AvatarURL is where the avatar is served, empty without one
+func (u UserModel) AvatarURL() string {
+	if u.Avatar == "" {
+		return ""
+	}
+	return UploadsPath + u.Avatar
+}
+
+// ProfileSettings are the parts of the profile a user edits on the settings
+// page. Avatar is a new image, RemoveAvatar drops the current one.
+type ProfileSettings struct {
+	DisplayName  string
+	Bio          string
+	Website      string
+	Avatar       *Upload
+	RemoveAvatar bool
+}
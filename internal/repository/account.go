@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/internal/entity"
+)
+
+// GetAccountContent returns the posts, with their categories and attachments,
+// the comments, the votes and the reports of a user as one snapshot
+func (u *AuthRepository) GetAccountContent(username string) (entity.AccountData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return entity.AccountData{}, fmt.Errorf("repository: get account content: transaction %w", err)
+	}
+	defer tx.Rollback()
+
+	var data entity.AccountData
+	if data.Posts, err = accountPosts(ctx, tx, username); err != nil {
+		return entity.AccountData{}, fmt.Errorf("repository: get account content: %w", err)
+	}
+	if data.Comments, err = accountComments(ctx, tx, username); err != nil {
+		return entity.AccountData{}, fmt.Errorf("repository: get account content: %w", err)
+	}
+	if data.Votes, err = accountVotes(ctx, tx, username); err != nil {
+		return entity.AccountData{}, fmt.Errorf("repository: get account content: %w", err)
+	}
+	if data.Reports, err = accountReports(ctx, tx, username); err != nil {
+		return entity.AccountData{}, fmt.Errorf("repository: get account content: %w", err)
+	}
+
+	return data, nil
+}
+
+// DeleteUser deletes an account in one transaction. The votes of the user go
+// away and the triggers correct the counters. With entity.DeleteAnonymize
+// the posts and comments stay under entity.DeletedUser, with
+// entity.DeleteRemove the posts are deleted like DeletePostById does and the
+// comments on other posts are blanked like DeleteComment does, so the replies
+// to them keep their place. Reports the user filed stay under
+// entity.DeletedUser for the moderators. It returns the upload files the
+// account used, the avatar and with entity.DeleteRemove the attachments.
+func (u *AuthRepository) DeleteUser(userId int, mode string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("repository: delete user: transaction %w", err)
+	}
+
+	var username, avatar string
+	query := `SELECT username, IFNULL(avatar, '') FROM user WHERE userId = $1;`
+	if err := tx.QueryRowContext(ctx, query, userId).Scan(&username, &avatar); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("repository: delete user: %w", err)
+	}
+	files := []string{avatar}
+
+	const posts = `SELECT postId FROM posts WHERE author = $1`
+	type statement struct {
+		query string
+		args  []interface{}
+	}
+	var statements []statement
+	switch mode {
+	case entity.DeleteAnonymize:
+		statements = []statement{
+			{`DELETE FROM votes WHERE username = $1;`, []interface{}{username}},
+			{`UPDATE posts SET author = $1 WHERE author = $2;`, []interface{}{entity.DeletedUser, username}},
+			{`UPDATE comments SET author = $1 WHERE author = $2;`, []interface{}{entity.DeletedUser, username}},
+		}
+	case entity.DeleteRemove:
+		attachments, err := attachmentFiles(ctx, tx, `SELECT filename, thumbnail FROM attachments WHERE postId IN (`+posts+`);`, username)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("repository: delete user: %w", err)
+		}
+		files = append(files, attachments...)
+
+		statements = []statement{
+			{`DELETE FROM votes WHERE username = $1;`, []interface{}{username}},
+			{`DELETE FROM votes WHERE (targetType = 'post' AND targetId IN (` + posts + `))
+				OR (targetType = 'comment' AND targetId IN (SELECT commentsId FROM comments WHERE postId IN (` + posts + `) OR author = $1));`, []interface{}{username}},
+			{`UPDATE reports SET status = 'resolved', resolvedAt = datetime('now') WHERE status = 'open'
+				AND ((targetType = 'post' AND targetId IN (` + posts + `))
+				OR (targetType = 'comment' AND targetId IN (SELECT commentsId FROM comments WHERE postId IN (` + posts + `) OR author = $1)));`, []interface{}{username}},
+			{`DELETE FROM comments WHERE postId IN (` + posts + `);`, []interface{}{username}},
+			{`DELETE FROM posts_category WHERE postCategoryId IN (` + posts + `);`, []interface{}{username}},
+			{`DELETE FROM post_revisions WHERE postId IN (` + posts + `);`, []interface{}{username}},
+			{`DELETE FROM attachments WHERE postId IN (` + posts + `);`, []interface{}{username}},
+			{`DELETE FROM posts WHERE author = $1;`, []interface{}{username}},
+			{`UPDATE comments SET author = $1, content = $2, contentHtml = '', deleted = 1, updatedAt = datetime('now') WHERE author = $3;`,
+				[]interface{}{entity.DeletedUser, entity.DeletedComment, username}},
+		}
+	default:
+		tx.Rollback()
+		return nil, fmt.Errorf("repository: delete user: unknown mode %q", mode)
+	}
+	statements = append(statements, statement{`UPDATE reports SET reporter = $1 WHERE reporter = $2;`, []interface{}{entity.DeletedUser, username}})
+
+	for _, st := range statements {
+		if _, err := tx.ExecContext(ctx, st.query, st.args...); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("repository: delete user: query %w", err)
+		}
+	}
+
+	for _, query := range []string{
+		`DELETE FROM sessions WHERE userId = $1;`,
+		`DELETE FROM user_tokens WHERE userId = $1;`,
+		`DELETE FROM user_identities WHERE userId = $1;`,
+		`DELETE FROM recovery_codes WHERE userId = $1;`,
+		`DELETE FROM two_factor WHERE userId = $1;`,
+		`DELETE FROM user WHERE userId = $1;`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("repository: delete user: query %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("repository: delete user: commit transaction %w", err)
+	}
+
+	return files, nil
+}
+
+func accountPosts(ctx context.Context, tx *sql.Tx, username string) ([]entity.Post, error) {
+	query := `SELECT postId, author, title, content, IFNULL(contentHtml, ''), creationDate, updatedAt, likes, dislikes, locked
+		FROM posts WHERE author = $1 ORDER BY postId;`
+	rows, err := tx.QueryContext(ctx, query, username)
+	if err != nil {
+		return nil, fmt.Errorf("posts: query %w", err)
+	}
+	defer rows.Close()
+
+	var posts []entity.Post
+	index := map[int]int{}
+	for rows.Next() {
+		var post entity.Post
+		var updated sql.NullTime
+		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.ContentHTML, &post.CreationTime, &updated, &post.Likes, &post.Dislikes, &post.Locked); err != nil {
+			return nil, fmt.Errorf("posts: scan %w", err)
+		}
+		post.UpdatedTime = updated.Time
+
+		index[post.PostId] = len(posts)
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("posts: rows error %w", err)
+	}
+
+	query = `SELECT postCategoryId, category FROM posts_category
+		WHERE postCategoryId IN (SELECT postId FROM posts WHERE author = $1);`
+	categories, err := tx.QueryContext(ctx, query, username)
+	if err != nil {
+		return nil, fmt.Errorf("categories: query %w", err)
+	}
+	defer categories.Close()
+
+	for categories.Next() {
+		var postId int
+		var category string
+		if err := categories.Scan(&postId, &category); err != nil {
+			return nil, fmt.Errorf("categories: scan %w", err)
+		}
+		if i, ok := index[postId]; ok {
+			posts[i].Category = append(posts[i].Category, category)
+		}
+	}
+	if err := categories.Err(); err != nil {
+		return nil, fmt.Errorf("categories: rows error %w", err)
+	}
+
+	query = `SELECT attachmentId, postId, filename, thumbnail, IFNULL(originalName, ''), contentType, size, width, height, createdAt
+		FROM attachments WHERE postId IN (SELECT postId FROM posts WHERE author = $1) ORDER BY attachmentId;`
+	attachments, err := tx.QueryContext(ctx, query, username)
+	if err != nil {
+		return nil, fmt.Errorf("attachments: query %w", err)
+	}
+	defer attachments.Close()
+
+	for attachments.Next() {
+		var a entity.Attachment
+		if err := attachments.Scan(&a.AttachmentId, &a.PostId, &a.Filename, &a.Thumbnail, &a.OriginalName, &a.ContentType, &a.Size, &a.Width, &a.Height, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("attachments: scan %w", err)
+		}
+		if i, ok := index[a.PostId]; ok {
+			posts[i].Attachments = append(posts[i].Attachments, a)
+		}
+	}
+	if err := attachments.Err(); err != nil {
+		return nil, fmt.Errorf("attachments: rows error %w", err)
+	}
+
+	return posts, nil
+}
+
+func accountComments(ctx context.Context, tx *sql.Tx, username string) ([]entity.Comments, error) {
+	query := `SELECT commentsId, postId, author, content, IFNULL(contentHtml, ''), likes, dislikes, deleted, updatedAt, IFNULL(parentId, 0)
+		FROM comments WHERE author = $1 ORDER BY commentsId;`
+	rows, err := tx.QueryContext(ctx, query, username)
+	if err != nil {
+		return nil, fmt.Errorf("comments: query %w", err)
+	}
+	defer rows.Close()
+
+	var comments []entity.Comments
+	for rows.Next() {
+		var comment entity.Comments
+		var updated sql.NullTime
+		if err := rows.Scan(&comment.CommentId, &comment.PostId, &comment.Author, &comment.Content, &comment.ContentHTML, &comment.Likes, &comment.Dislikes, &comment.Deleted, &updated, &comment.ParentId); err != nil {
+			return nil, fmt.Errorf("comments: scan %w", err)
+		}
+		comment.UpdatedTime = updated.Time
+
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("comments: rows error %w", err)
+	}
+
+	return comments, nil
+}
+
+func accountVotes(ctx context.Context, tx *sql.Tx, username string) ([]entity.Vote, error) {
+	query := `SELECT targetType, targetId, value, createdAt FROM votes WHERE username = $1 ORDER BY voteId;`
+	rows, err := tx.QueryContext(ctx, query, username)
+	if err != nil {
+		return nil, fmt.Errorf("votes: query %w", err)
+	}
+	defer rows.Close()
+
+	var votes []entity.Vote
+	for rows.Next() {
+		var vote entity.Vote
+		if err := rows.Scan(&vote.TargetType, &vote.TargetId, &vote.Value, &vote.CreatedAt); err != nil {
+			return nil, fmt.Errorf("votes: scan %w", err)
+		}
+
+		votes = append(votes, vote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("votes: rows error %w", err)
+	}
+
+	return votes, nil
+}
+
+func accountReports(ctx context.Context, tx *sql.Tx, username string) ([]entity.Report, error) {
+	query := `SELECT reportId, reporter, targetType, targetId, reason, IFNULL(details, ''), status, createdAt
+		FROM reports WHERE reporter = $1 ORDER BY reportId;`
+	rows, err := tx.QueryContext(ctx, query, username)
+	if err != nil {
+		return nil, fmt.Errorf("reports: query %w", err)
+	}
+	defer rows.Close()
+
+	var reports []entity.Report
+	for rows.Next() {
+		var report entity.Report
+		if err := rows.Scan(&report.ReportId, &report.Reporter, &report.TargetType, &report.TargetId, &report.Reason, &report.Details, &report.Status, &report.CreatedAt); err != nil {
+			return nil, fmt.Errorf("reports: scan %w", err)
+		}
+
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reports: rows error %w", err)
+	}
+
+	return reports, nil
+}
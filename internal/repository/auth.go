@@ -14,9 +14,36 @@ type Authorization interface {
 	CreateUser(user entity.UserModel) error
 	GetUserByUsername(username string) (entity.UserModel, error)
 	GetUserByEmail(email string) (entity.UserModel, error)
-	SaveToken(user entity.UserModel) error
-	GetUserByToken(token string) (entity.UserModel, error)
-	DeleteToken(token string) error
+	GetUserById(userId int) (entity.UserModel, error)
+	CreateSession(session entity.Session) error
+	GetUserBySession(tokenHash string) (entity.UserModel, entity.Session, error)
+	TouchSession(sessionId int, now, expiresAt time.Time) error
+	GetSessions(userId int, now time.Time) ([]entity.Session, error)
+	DeleteSession(tokenHash string) error
+	DeleteUserSession(userId, sessionId int) error
+	DeleteUserSessions(userId int) error
+	SaveUserToken(token entity.UserToken) error
+	GetUserToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error)
+	ConsumeUserToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error)
+	SetEmailVerified(userId int, email string) error
+	UpdatePassword(userId int, passwordHash string) error
+	RehashPassword(userId int, oldHash, newHash string) error
+	UpdateUser(user entity.UserModel) error
+	ChangeEmail(userId int, email string) error
+	GetAccountContent(username string) (entity.AccountData, error)
+	DeleteUser(userId int, mode string) ([]string, error)
+	GetUserByIdentity(provider, subject string) (entity.UserModel, error)
+	LinkIdentity(identity entity.Identity) error
+	CreateUserWithIdentity(user entity.UserModel, identity entity.Identity) (int, error)
+	GetIdentities(userId int) ([]entity.Identity, error)
+	GetTwoFactor(userId int) (entity.TwoFactor, error)
+	SaveTwoFactorSecret(userId int, secret string) error
+	ConfirmTwoFactor(userId int, step int64, now time.Time, codeHashes []string) error
+	UseTwoFactorStep(userId int, step int64) error
+	UseRecoveryCode(userId int, codeHash string, now time.Time) error
+	CountRecoveryCodes(userId int) (int, error)
+	ReplaceRecoveryCodes(userId int, codeHashes []string) error
+	DeleteTwoFactor(userId int) error
 }
 
 type AuthRepository struct {
@@ -54,10 +81,32 @@ func (u *AuthRepository) GetUserByUsername(username string) (entity.UserModel, e
 	defer cancel()
 
 	var user entity.UserModel
-	selectQuery := `SELECT email, userId, username, password, creationDate FROM user WHERE username = $1;`
-	if err := u.db.QueryRowContext(ctx, selectQuery, username).Scan(&user.Email, &user.UserId, &user.Username, &user.Password, &user.CreatedAt); err != nil {
+	var suspended sql.NullTime
+	selectQuery := `SELECT email, IFNULL(emailVerified, 0), userId, username, password, creationDate, IFNULL(role, 'user'), suspendedUntil,
+		IFNULL(displayName, ''), IFNULL(bio, ''), IFNULL(avatar, ''), IFNULL(website, '') FROM user WHERE username = $1;`
+	if err := u.db.QueryRowContext(ctx, selectQuery, username).Scan(&user.Email, &user.EmailVerified, &user.UserId, &user.Username, &user.Password, &user.CreatedAt, &user.Role, &suspended,
+		&user.DisplayName, &user.Bio, &user.Avatar, &user.Website); err != nil {
 		return entity.UserModel{}, fmt.Errorf("repository: get user by username: %w", err)
 	}
+	user.SuspendedUntil = suspended.Time
+
+	return user, nil
+}
+
+// GetUserById is GetUserByUsername for the id of the user
+func (u *AuthRepository) GetUserById(userId int) (entity.UserModel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var user entity.UserModel
+	var suspended sql.NullTime
+	selectQuery := `SELECT email, IFNULL(emailVerified, 0), userId, username, password, creationDate, IFNULL(role, 'user'), suspendedUntil,
+		IFNULL(displayName, ''), IFNULL(bio, ''), IFNULL(avatar, ''), IFNULL(website, '') FROM user WHERE userId = $1;`
+	if err := u.db.QueryRowContext(ctx, selectQuery, userId).Scan(&user.Email, &user.EmailVerified, &user.UserId, &user.Username, &user.Password, &user.CreatedAt, &user.Role, &suspended,
+		&user.DisplayName, &user.Bio, &user.Avatar, &user.Website); err != nil {
+		return entity.UserModel{}, fmt.Errorf("repository: get user by id: %w", err)
+	}
+	user.SuspendedUntil = suspended.Time
 
 	return user, nil
 }
@@ -67,81 +116,177 @@ func (u *AuthRepository) GetUserByEmail(email string) (entity.UserModel, error)
 	defer cancel()
 
 	var user entity.UserModel
-	selectQuery := `SELECT email, userId, username, password, creationDate FROM user WHERE email = $1;`
-	if err := u.db.QueryRowContext(ctx, selectQuery, email).Scan(&user.Email, &user.UserId, &user.Username, &user.Password, &user.CreatedAt); err != nil {
+	selectQuery := `SELECT email, IFNULL(emailVerified, 0), userId, username, password, creationDate FROM user WHERE email = $1;`
+	if err := u.db.QueryRowContext(ctx, selectQuery, email).Scan(&user.Email, &user.EmailVerified, &user.UserId, &user.Username, &user.Password, &user.CreatedAt); err != nil {
 		return entity.UserModel{}, fmt.Errorf("repository: get user :%w", err)
 	}
 
 	return user, nil
 }
 
-func (u *AuthRepository) SaveToken(user entity.UserModel) error {
+// SaveUserToken stores an emailed token, unused tokens of the same user and
+// purpose are removed so only the latest link works
+func (u *AuthRepository) SaveUserToken(token entity.UserToken) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `UPDATE user SET token = $1, expiresAt = $2 WHERE username = $3;`
-	_, err := u.db.ExecContext(ctx, query, user.Token, user.ExpirationTime, user.Username)
+	tx, err := u.db.Begin()
 	if err != nil {
-		fmt.Printf("error SaveToken in repo -%v", err)
+		return fmt.Errorf("repository: save user token: transaction %w", err)
+	}
+
+	query := `DELETE FROM user_tokens WHERE userId = $1 AND purpose = $2 AND usedAt IS NULL;`
+	if _, err := tx.ExecContext(ctx, query, token.UserId, token.Purpose); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: save user token: delete old %w", err)
+	}
+
+	query = `INSERT INTO user_tokens (tokenHash, userId, purpose, email, createdAt, expiresAt) VALUES ($1, $2, $3, $4, $5, $6);`
+	if _, err := tx.ExecContext(ctx, query, token.TokenHash, token.UserId, token.Purpose, token.Email,
+		token.CreatedAt.UTC().Format(sqliteTimeLayout), token.ExpiresAt.UTC().Format(sqliteTimeLayout)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: save user token: insert %w", err)
+	}
 
-		return fmt.Errorf("repository: user: save token :%w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: save user token: commit transaction %w", err)
 	}
 
 	return nil
 }
 
-// reeives user info by token
-func (u *AuthRepository) GetUserByToken(token string) (entity.UserModel, error) {
+// GetUserToken returns an unused, unexpired token without using it up,
+// sql.ErrNoRows means the token is unknown, used or expired
+func (u *AuthRepository) GetUserToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
 	defer cancel()
 
-	var user entity.UserModel
-	query := `SELECT userId, username, email, password, creationDate, token, expiresAt FROM user WHERE token = $1;`
-	if err := u.db.QueryRowContext(ctx, query, token).Scan(&user.UserId, &user.Username, &user.Email, &user.Password, &user.CreatedAt, &user.Token, &user.ExpirationTime); err != nil {
-		return entity.UserModel{}, fmt.Errorf("repository: get user by token: %w", err)
+	query := `SELECT tokenHash, userId, purpose, email FROM user_tokens
+		WHERE tokenHash = $1 AND purpose = $2 AND usedAt IS NULL AND expiresAt > $3;`
+	var token entity.UserToken
+	if err := u.db.QueryRowContext(ctx, query, tokenHash, purpose, now.UTC().Format(sqliteTimeLayout)).Scan(&token.TokenHash, &token.UserId, &token.Purpose, &token.Email); err != nil {
+		return entity.UserToken{}, fmt.Errorf("repository: get user token: %w", err)
 	}
 
-	return user, nil
+	return token, nil
+}
+
+// ConsumeUserToken marks an unused, unexpired token as used and returns it,
+// sql.ErrNoRows means the token is unknown, used or expired
+func (u *AuthRepository) ConsumeUserToken(tokenHash, purpose string, now time.Time) (entity.UserToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	stamp := now.UTC().Format(sqliteTimeLayout)
+	query := `UPDATE user_tokens SET usedAt = $1
+		WHERE tokenHash = $2 AND purpose = $3 AND usedAt IS NULL AND expiresAt > $1
+		RETURNING tokenHash, userId, purpose, email;`
+	var token entity.UserToken
+	if err := u.db.QueryRowContext(ctx, query, stamp, tokenHash, purpose).Scan(&token.TokenHash, &token.UserId, &token.Purpose, &token.Email); err != nil {
+		return entity.UserToken{}, fmt.Errorf("repository: consume user token: %w", err)
+	}
+
+	return token, nil
+}
+
+// SetEmailVerified confirms the email of a user, unless it changed since the
+// verification link was sent
+func (u *AuthRepository) SetEmailVerified(userId int, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `UPDATE user SET emailVerified = 1 WHERE userId = $1 AND email = $2;`
+	res, err := u.db.ExecContext(ctx, query, userId, email)
+	if err != nil {
+		return fmt.Errorf("repository: set email verified: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: set email verified: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// RehashPassword replaces the password hash of a user with a new hash of
+// the same password, the sessions stay. sql.ErrNoRows means the password
+// was changed in the meantime.
+func (u *AuthRepository) RehashPassword(userId int, oldHash, newHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `UPDATE user SET password = $1 WHERE userId = $2 AND password = $3;`
+	res, err := u.db.ExecContext(ctx, query, newHash, userId, oldHash)
+	if err != nil {
+		return fmt.Errorf("repository: rehash password: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: rehash password: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the password hash of a user and ends all sessions
+func (u *AuthRepository) UpdatePassword(userId int, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return fmt.Errorf("repository: update password: transaction %w", err)
+	}
+
+	query := `UPDATE user SET password = $1 WHERE userId = $2;`
+	if _, err := tx.ExecContext(ctx, query, passwordHash, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: update password: %w", err)
+	}
+
+	query = `DELETE FROM sessions WHERE userId = $1;`
+	if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: update password: delete sessions %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: update password: commit transaction %w", err)
+	}
+
+	return nil
 }
 
-// deletes token
-func (u *AuthRepository) DeleteToken(token string) error {
+// UpdateUser saves the profile of a user: display name, bio, avatar and website
+func (u *AuthRepository) UpdateUser(user entity.UserModel) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `UPDATE user SET token = NULL, expiresAt = NULL WHERE token = $1;`
-	_, err := u.db.ExecContext(ctx, query, token)
+	query := `UPDATE user SET displayName = $1, bio = $2, avatar = $3, website = $4 WHERE userId = $5;`
+	res, err := u.db.ExecContext(ctx, query, user.DisplayName, user.Bio, user.Avatar, user.Website, user.UserId)
 	if err != nil {
-		return fmt.Errorf("repository: delete token: %w", err)
+		return fmt.Errorf("repository: update user: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: update user: %w", sql.ErrNoRows)
 	}
 
 	return nil
 }
 
-// updates user password
-// func (u *AuthRepository) UpdateUser(username, password string) error {
-// 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
-// 	defer cancel()
-
-// 	updateQuery := `UPDATE user SET password = $1 WHERE username = $2;`
-// 	_, err := u.db.ExecContext(ctx, updateQuery, password, username)
-// 	if err != nil {
-// 		return fmt.Errorf("repository: update user:%w", err)
-// 	}
-
-// 	return nil
-// }
-
-// // deletes user
-// func (u *AuthRepository) DeleteUser(username string) error {
-// 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
-// 	defer cancel()
-
-// 	deleteQuery := `DELETE FROM user WHERE username = $1;`
-// 	_, err := u.db.ExecContext(ctx, deleteQuery, username)
-// 	if err != nil {
-// 		return fmt.Errorf("repository: delete user :%w", err)
-// 	}
-
-// 	return nil
-// }
+// ChangeEmail sets the confirmed new email of a user, sql.ErrNoRows means
+// another account took the address in the meantime
+func (u *AuthRepository) ChangeEmail(userId int, email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `UPDATE user SET email = $1, emailVerified = 1
+		WHERE userId = $2 AND NOT EXISTS (SELECT 1 FROM user WHERE email = $1 AND userId != $2);`
+	res, err := u.db.ExecContext(ctx, query, email, userId)
+	if err != nil {
+		return fmt.Errorf("repository: change email: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: change email: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
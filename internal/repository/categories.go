@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"forum/config"
+	"forum/internal/entity"
+)
+
+type Categories interface {
+	GetCategories() ([]entity.Category, error)
+	GetCategoryBySlug(slug string) (entity.Category, error)
+	GetCategoryById(id int) (entity.Category, error)
+	CreateCategory(category entity.Category, entry entity.AuditEntry) (int, error)
+	UpdateCategory(category entity.Category, entry entity.AuditEntry) error
+	DeleteCategory(id int, entry entity.AuditEntry) error
+}
+
+type CategoriesRepository struct {
+	db  *sql.DB
+	cnf *config.Config
+}
+
+func NewCategoriesRepository(db *sql.DB, cnf *config.Config) *CategoriesRepository {
+	return &CategoriesRepository{
+		db:  db,
+		cnf: cnf,
+	}
+}
+
+const categoryColumns = `c.categoryId, c.slug, c.name, IFNULL(c.description, ''), IFNULL(c.position, 0),
+	(SELECT count(*) FROM posts_category pc WHERE pc.category = c.name)`
+
+// GetCategories lists all categories in display order with their post counts
+func (c *CategoriesRepository) GetCategories() ([]entity.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + categoryColumns + ` FROM categories c ORDER BY c.position, c.name;`
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("repository: get categories: query %w", err)
+	}
+
+	defer rows.Close()
+
+	var categories []entity.Category
+	for rows.Next() {
+		var category entity.Category
+		if err := rows.Scan(&category.CategoryId, &category.Slug, &category.Name, &category.Description, &category.Position, &category.PostCount); err != nil {
+			return nil, fmt.Errorf("repository: get categories: scan %w", err)
+		}
+
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository: get categories: rows error %w", err)
+	}
+
+	return categories, nil
+}
+
+func (c *CategoriesRepository) GetCategoryBySlug(slug string) (entity.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + categoryColumns + ` FROM categories c WHERE c.slug = $1;`
+	var category entity.Category
+	if err := c.db.QueryRowContext(ctx, query, slug).Scan(&category.CategoryId, &category.Slug, &category.Name, &category.Description, &category.Position, &category.PostCount); err != nil {
+		return entity.Category{}, fmt.Errorf("repository: get category by slug: %w", err)
+	}
+
+	return category, nil
+}
+
+func (c *CategoriesRepository) GetCategoryById(id int) (entity.Category, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + categoryColumns + ` FROM categories c WHERE c.categoryId = $1;`
+	var category entity.Category
+	if err := c.db.QueryRowContext(ctx, query, id).Scan(&category.CategoryId, &category.Slug, &category.Name, &category.Description, &category.Position, &category.PostCount); err != nil {
+		return entity.Category{}, fmt.Errorf("repository: get category by id: %w", err)
+	}
+
+	return category, nil
+}
+
+// CreateCategory adds a category and writes entry, with the id of the new
+// category as its target, to the audit log in the same transaction
+func (c *CategoriesRepository) CreateCategory(category entity.Category, entry entity.AuditEntry) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var id int
+	err := withAudit(ctx, c.db, &entry, func(tx *sql.Tx) error {
+		query := `INSERT INTO categories (slug, name, description, position) VALUES ($1, $2, $3, $4) RETURNING categoryId;`
+		if err := tx.QueryRowContext(ctx, query, category.Slug, category.Name, category.Description, category.Position).Scan(&id); err != nil {
+			return err
+		}
+		entry.TargetId = strconv.Itoa(id)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repository: create category: %w", err)
+	}
+
+	return id, nil
+}
+
+// UpdateCategory changes a category, a new name is carried over to the posts
+// tagged with it. entry goes to the audit log in the same transaction.
+func (c *CategoriesRepository) UpdateCategory(category entity.Category, entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	err := withAudit(ctx, c.db, &entry, func(tx *sql.Tx) error {
+		query := `UPDATE posts_category SET category = $1 WHERE category = (SELECT name FROM categories WHERE categoryId = $2);`
+		if _, err := tx.ExecContext(ctx, query, category.Name, category.CategoryId); err != nil {
+			return fmt.Errorf("rename posts category %w", err)
+		}
+
+		query = `UPDATE categories SET slug = $1, name = $2, description = $3, position = $4 WHERE categoryId = $5;`
+		if _, err := tx.ExecContext(ctx, query, category.Slug, category.Name, category.Description, category.Position, category.CategoryId); err != nil {
+			return fmt.Errorf("query %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("repository: update category: %w", err)
+	}
+
+	return nil
+}
+
+func (c *CategoriesRepository) DeleteCategory(id int, entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	err := withAudit(ctx, c.db, &entry, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE categoryId = $1;`, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("repository: delete category: %w", err)
+	}
+
+	return nil
+}
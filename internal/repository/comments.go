@@ -11,15 +11,13 @@ import (
 )
 
 type Commenter interface {
-	CreateComment(comment entity.Comments) error
+	CreateComment(comment entity.Comments) (int, error)
+	UpdateComment(comment entity.Comments) error
+	DeleteComment(id int) error
+	IsPostLocked(postId int) (bool, error)
 	GetCommentById(id int) (entity.Comments, error)
 	GetCommentsByPostId(id int) ([]entity.Comments, error)
-	LikeComment(commentId int, username string) error
-	DislikeComment(commentId int, username string) error
-	RemoveLikeFromComment(commentId int, username string) error
-	RemoveDislikeFromComment(commentId int, username string) error
-	CommentLiked(commentId int, username string) error
-	CommentDisliked(commentId int, username string) error
+	SetCommentVote(commentId int, username string, value int) (entity.VoteCount, error)
 	GetCommentDislikes(postId int) (map[int][]string, error)
 	GetCommentLikes(postId int) (map[int][]string, error)
 }
@@ -36,214 +34,161 @@ func NewCommentsRepostiry(db *sql.DB, config *config.Config) *CommentsRepository
 	}
 }
 
-func (c *CommentsRepository) CreateComment(comment entity.Comments) error {
+// CreateComment stores a comment and returns its id
+func (c *CommentsRepository) CreateComment(comment entity.Comments) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `INSERT INTO comments (postId, author, content) VALUES ($1, $2, $3);`
-	_, err := c.db.ExecContext(ctx, query, comment.PostId, comment.Author, comment.Content)
+	var parentId sql.NullInt64
+	if comment.ParentId != 0 {
+		parentId = sql.NullInt64{Int64: int64(comment.ParentId), Valid: true}
+	}
+
+	query := `INSERT INTO comments (postId, author, content, contentHtml, parentId) VALUES ($1, $2, $3, $4, $5);`
+	res, err := c.db.ExecContext(ctx, query, comment.PostId, comment.Author, comment.Content, comment.ContentHTML, parentId)
+	if err != nil {
+		return 0, fmt.Errorf("repository: create comment: %w", err)
+	}
+	id, err := res.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("repository: create comment: %w", err)
+		return 0, fmt.Errorf("repository: create comment: %w", err)
 	}
 
-	return nil
+	return int(id), nil
 }
 
-func (c *CommentsRepository) GetCommentById(id int) (entity.Comments, error) {
+// UpdateComment replaces the content of a comment and its rendered HTML
+func (c *CommentsRepository) UpdateComment(comment entity.Comments) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT commentsId, postId, author, content FROM comments WHERE commentsId = $1;`
-	var comment entity.Comments
-	if err := c.db.QueryRowContext(ctx, query, id).Scan(&comment.CommentId, &comment.PostId, &comment.Author, &comment.Content); err != nil {
-		return entity.Comments{}, fmt.Errorf("repository: get comment by id: %w", err)
+	query := `UPDATE comments SET content = $1, contentHtml = $2, updatedAt = datetime('now') WHERE commentsId = $3 AND deleted = 0;`
+	_, err := c.db.ExecContext(ctx, query, comment.Content, comment.ContentHTML, comment.CommentId)
+	if err != nil {
+		return fmt.Errorf("repository: update comment: %w", err)
 	}
 
-	return comment, nil
+	return nil
 }
 
-func (c *CommentsRepository) GetCommentsByPostId(id int) ([]entity.Comments, error) {
+// DeleteComment turns the comment into a tombstone, the row and its votes
+// stay so replies and likes/dislikes keep pointing to it
+func (c *CommentsRepository) DeleteComment(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT commentsId, postId, author, content, likes, dislikes FROM comments WHERE postId = $1;`
-	rows, err := c.db.QueryContext(ctx, query, id)
+	tx, err := c.db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("repository:comments:createcomment %w", err)
+		return fmt.Errorf("repository: delete comment: transaction %w", err)
 	}
 
-	defer rows.Close()
-
-	var comments []entity.Comments
-	for rows.Next() {
-		var comment entity.Comments
-		if err := rows.Scan(&comment.CommentId, &comment.PostId, &comment.Author, &comment.Content, &comment.Likes, &comment.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository:comments:createcomment:scan %w", err)
-		}
-		comments = append(comments, comment)
+	if err := deleteComment(ctx, tx, id); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: delete comment: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository:comments:getcommentbyPostId: rows error %w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: delete comment: commit transaction %w", err)
 	}
 
-	return comments, nil
+	return nil
 }
 
-func (c *CommentsRepository) LikeComment(commentId int, username string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
-	defer cancel()
-
-	query := `INSERT INTO likes (commentsId, username) VALUES ($1,$2);`
-	_, err := c.db.ExecContext(ctx, query, commentId, username)
-	if err != nil {
-		return fmt.Errorf("repository:comments:likecomment:query1 %w", err)
+// deleteComment leaves the tombstone of a comment and closes its open reports
+func deleteComment(ctx context.Context, tx *sql.Tx, id int) error {
+	query := `UPDATE comments SET content = $1, contentHtml = '', deleted = 1, updatedAt = datetime('now') WHERE commentsId = $2;`
+	if _, err := tx.ExecContext(ctx, query, entity.DeletedComment, id); err != nil {
+		return fmt.Errorf("query %w", err)
 	}
 
-	query = `UPDATE comments SET likes = likes +1 WHERE commentsId = $1;`
-	_, err = c.db.ExecContext(ctx, query, commentId)
-	if err != nil {
-		return fmt.Errorf("repository:comments:likecomment:query2 %w", err)
+	if _, err := tx.ExecContext(ctx, resolveReportsQuery, entity.ReportTargetComment, id); err != nil {
+		return fmt.Errorf("reports %w", err)
 	}
 
 	return nil
 }
 
-func (c *CommentsRepository) DislikeComment(commentId int, username string) error {
+// IsPostLocked reports whether a moderator locked the post for new comments
+func (c *CommentsRepository) IsPostLocked(postId int) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `INSERT INTO dislikes (commentsId, username) VALUES ($1,$2);`
-	_, err := c.db.ExecContext(ctx, query, commentId, username)
-	if err != nil {
-		return fmt.Errorf("repository:comments:dislikecomment:query1 %w", err)
+	var locked bool
+	query := `SELECT locked FROM posts WHERE postId = $1;`
+	if err := c.db.QueryRowContext(ctx, query, postId).Scan(&locked); err != nil {
+		return false, fmt.Errorf("repository: is post locked: %w", err)
 	}
 
-	query = `UPDATE comments SET dislikes = dislikes +1 WHERE commentsId = $1;`
-	_, err = c.db.ExecContext(ctx, query, commentId)
-	if err != nil {
-		return fmt.Errorf("repository:comments:dislikecomment:query2 %w", err)
-	}
-
-	return nil
+	return locked, nil
 }
 
-func (c *CommentsRepository) RemoveLikeFromComment(commentId int, username string) error {
+func (c *CommentsRepository) GetCommentById(id int) (entity.Comments, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM likes WHERE commentsId = $1 AND username =$2;`
-	_, err := c.db.ExecContext(ctx, query, commentId, username)
-	if err != nil {
-		return fmt.Errorf("repository:comments:removelikefromcomment:query1 %w", err)
-	}
-
-	query = `UPDATE comments SET likes = likes -1 WHERE commentsId = $1;`
-	_, err = c.db.ExecContext(ctx, query, commentId)
-	if err != nil {
-		return fmt.Errorf("repository:comments:removelikefromcomment:query2 %w", err)
+	query := `SELECT commentsId, postId, author, content, IFNULL(contentHtml, ''), likes, dislikes, deleted, updatedAt, IFNULL(parentId, 0) FROM comments WHERE commentsId = $1;`
+	var comment entity.Comments
+	var updated sql.NullTime
+	if err := c.db.QueryRowContext(ctx, query, id).Scan(&comment.CommentId, &comment.PostId, &comment.Author, &comment.Content, &comment.ContentHTML, &comment.Likes, &comment.Dislikes, &comment.Deleted, &updated, &comment.ParentId); err != nil {
+		return entity.Comments{}, fmt.Errorf("repository: get comment by id: %w", err)
 	}
+	comment.UpdatedTime = updated.Time
 
-	return nil
+	return comment, nil
 }
 
-func (c *CommentsRepository) RemoveDislikeFromComment(commentId int, username string) error {
+func (c *CommentsRepository) GetCommentsByPostId(id int) ([]entity.Comments, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM dislikes WHERE commentsId =$1 AND username =$2;`
-	_, err := c.db.ExecContext(ctx, query, commentId, username)
-	if err != nil {
-		return fmt.Errorf("repository:comments:removedislikefromcomment:query1 %w", err)
-	}
-
-	query = `UPDATE comments SET dislikes = dislikes -1 WHERE commentsId = $1;`
-	_, err = c.db.ExecContext(ctx, query, commentId)
+	query := `SELECT commentsId, postId, author, content, IFNULL(contentHtml, ''), likes, dislikes, deleted, updatedAt, IFNULL(parentId, 0) FROM comments WHERE postId = $1 ORDER BY commentsId;`
+	rows, err := c.db.QueryContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("repository:comments:removedislikefromcomment:query2 %w", err)
+		return nil, fmt.Errorf("repository:comments:createcomment %w", err)
 	}
 
-	return nil
-}
+	defer rows.Close()
 
-func (c *CommentsRepository) CommentLiked(commentId int, username string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
-	defer cancel()
+	var comments []entity.Comments
+	for rows.Next() {
+		var comment entity.Comments
+		var updated sql.NullTime
+		if err := rows.Scan(&comment.CommentId, &comment.PostId, &comment.Author, &comment.Content, &comment.ContentHTML, &comment.Likes, &comment.Dislikes, &comment.Deleted, &updated, &comment.ParentId); err != nil {
+			return nil, fmt.Errorf("repository:comments:createcomment:scan %w", err)
+		}
+		comment.UpdatedTime = updated.Time
+		comments = append(comments, comment)
+	}
 
-	query := `SELECT username FROM likes WHERE commentsId = $1 AND username =$2;`
-	var user string
-	if err := c.db.QueryRowContext(ctx, query, commentId, username).Scan(&user); err != nil {
-		return fmt.Errorf("repository:comments:commentliked:query1 %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository:comments:getcommentbyPostId: rows error %w", err)
 	}
 
-	return nil
+	return comments, nil
 }
 
-func (c *CommentsRepository) CommentDisliked(commentId int, username string) error {
+// SetCommentVote replaces the vote of the user on a comment and returns the
+// new counts, sql.ErrNoRows means there is no such comment or it is deleted
+// and value is not entity.VoteNone
+func (c *CommentsRepository) SetCommentVote(commentId int, username string, value int) (entity.VoteCount, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT username FROM dislikes WHERE commentsId = $1 AND username = $2;`
-	var user string
-	if err := c.db.QueryRowContext(ctx, query, commentId, username).Scan(&user); err != nil {
-		return fmt.Errorf("repository:comments:commentdisliked:query1 %w", err)
+	count, err := setVote(ctx, c.db, entity.VoteTargetComment, "comments", "commentsId", commentId, username, value)
+	if err != nil {
+		return entity.VoteCount{}, fmt.Errorf("repository: comments: set vote: %w", err)
 	}
 
-	return nil
+	return count, nil
 }
 
 func (c *CommentsRepository) GetCommentLikes(postId int) (map[int][]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	users := make(map[int][]string)
-	queryCommentId := `SELECT commentsId  FROM comments WHERE postId = $1;`
-	queryUsers := `SELECT username FROM likes WHERE commentsId = $1;`
-
-	rowsComments, err := c.db.QueryContext(ctx, queryCommentId, postId)
+	users, err := commentVoters(ctx, c.db, postId, entity.VoteUp)
 	if err != nil {
-		return nil, fmt.Errorf("repository:comments:commentlikes:query1 %w", err)
-	}
-	defer rowsComments.Close()
-
-	for rowsComments.Next() {
-		var id int
-
-		if err := rowsComments.Scan(&id); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, nil
-			}
-			return nil, fmt.Errorf("repository:comments:commentlikes:scancomment %w", err)
-		}
-
-		var usernames []string
-
-		rowsUsers, err := c.db.QueryContext(ctx, queryUsers, postId)
-		if err != nil {
-			return nil, fmt.Errorf("repository:comments:commentlikes:query2 %w", err)
-		}
-
-		defer rowsUsers.Close()
-
-		for rowsUsers.Next() {
-			var user string
-
-			if err := rowsUsers.Scan(&user); err != nil {
-				return nil, fmt.Errorf("repository:comments:commentlikes:scanuser %w", err)
-			}
-
-			usernames = append(usernames, user)
-		}
-
-		if err := rowsUsers.Err(); err != nil {
-			return nil, fmt.Errorf("repository:comments:commentlikes: rows error %w", err)
-		}
-
-		users[id] = usernames
-	}
-
-	if err := rowsComments.Err(); err != nil {
-		return nil, fmt.Errorf("repository:comments:commentlikes: rows error %w", err)
+		return nil, fmt.Errorf("repository:comments:commentlikes: %w", err)
 	}
 
 	return users, nil
@@ -252,46 +197,46 @@ func (c *CommentsRepository) GetCommentLikes(postId int) (map[int][]string, erro
 func (c *CommentsRepository) GetCommentDislikes(postId int) (map[int][]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.cnf.CtxTimeout)*time.Second)
 	defer cancel()
-	users := make(map[int][]string)
-
-	queryCommentId := `SELECT commentsId  FROM comments WHERE postId = $1;`
-	queryUsers := `SELECT username FROM dislikes WHERE commentsId = $1;`
 
-	rowsComments, err := c.db.QueryContext(ctx, queryCommentId, postId)
+	users, err := commentVoters(ctx, c.db, postId, entity.VoteDown)
 	if err != nil {
-		return nil, fmt.Errorf("repository:comments:commentdislikes:query1 %w", err)
+		return nil, fmt.Errorf("repository:comments:commentdislikes: %w", err)
 	}
-	defer rowsComments.Close()
-
-	for rowsComments.Next() {
-		var id int
 
-		if err := rowsComments.Scan(&id); err != nil {
-			if err == sql.ErrNoRows {
-				return nil, nil
-			}
-			return nil, fmt.Errorf("repository:comments:commentdislikes:scancomment %w", err)
-		}
+	return users, nil
+}
 
-		var usernames []string
-		rowsUsers, err := c.db.QueryContext(ctx, queryUsers, postId)
-		if err != nil {
-			return nil, fmt.Errorf("repository:comments:commentdislikes:query2 %w", err)
-		}
+// commentVoters maps every comment of the post to the users who voted value on it
+func commentVoters(ctx context.Context, db *sql.DB, postId, value int) (map[int][]string, error) {
+	query := `SELECT c.commentsId, v.username FROM comments c
+		LEFT JOIN votes v ON v.targetType = 'comment' AND v.targetId = c.commentsId AND v.value = $2
+		WHERE c.postId = $1 ORDER BY c.commentsId, v.voteId;`
+	rows, err := db.QueryContext(ctx, query, postId, value)
+	if err != nil {
+		return nil, fmt.Errorf("query %w", err)
+	}
+	defer rows.Close()
 
-		for rowsUsers.Next() {
-			var user string
-			if err := rowsUsers.Scan(&user); err != nil {
-				return nil, fmt.Errorf("repository:comments:commentdislikes:scanuser %w", err)
-			}
+	users := make(map[int][]string)
+	for rows.Next() {
+		var (
+			id       int
+			username sql.NullString
+		)
 
-			usernames = append(usernames, user)
+		if err := rows.Scan(&id, &username); err != nil {
+			return nil, fmt.Errorf("scan %w", err)
 		}
 
-		users[id] = usernames
+		if !username.Valid {
+			users[id] = nil
+			continue
+		}
+		users[id] = append(users[id], username.String)
 	}
-	if err := rowsComments.Err(); err != nil {
-		return nil, fmt.Errorf("repository:comments:commentdislikes: rows error %w", err)
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error %w", err)
 	}
 
 	return users, nil
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/internal/entity"
+)
+
+// GetUserByIdentity returns the user linked to the account subject at provider
+func (u *AuthRepository) GetUserByIdentity(provider, subject string) (entity.UserModel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var user entity.UserModel
+	var suspended sql.NullTime
+	query := `SELECT u.email, IFNULL(u.emailVerified, 0), u.userId, u.username, u.password, u.creationDate, IFNULL(u.role, 'user'), u.suspendedUntil
+		FROM user_identities i JOIN user u ON u.userId = i.userId
+		WHERE i.provider = $1 AND i.subject = $2;`
+	if err := u.db.QueryRowContext(ctx, query, provider, subject).Scan(&user.Email, &user.EmailVerified, &user.UserId, &user.Username, &user.Password, &user.CreatedAt, &user.Role, &suspended); err != nil {
+		return entity.UserModel{}, fmt.Errorf("repository: get user by identity: %w", err)
+	}
+	user.SuspendedUntil = suspended.Time
+
+	return user, nil
+}
+
+// LinkIdentity links an account at a provider to an existing user
+func (u *AuthRepository) LinkIdentity(identity entity.Identity) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO user_identities (userId, provider, subject, email) VALUES ($1, $2, $3, $4);`
+	if _, err := u.db.ExecContext(ctx, query, identity.UserId, identity.Provider, identity.Subject, identity.Email); err != nil {
+		return fmt.Errorf("repository: link identity: %w", err)
+	}
+
+	return nil
+}
+
+// CreateUserWithIdentity creates a user without a password for the first
+// sign-in through a provider and links the identity to it in one transaction
+func (u *AuthRepository) CreateUserWithIdentity(user entity.UserModel, identity entity.Identity) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("repository: create user with identity: transaction %w", err)
+	}
+
+	query := `INSERT INTO user (username, password, email, emailVerified) VALUES ($1, '', $2, $3) RETURNING userId;`
+	var id int
+	if err := tx.QueryRowContext(ctx, query, user.Username, user.Email, user.EmailVerified).Scan(&id); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("repository: create user with identity: insert user %w", err)
+	}
+
+	query = `INSERT INTO user_identities (userId, provider, subject, email) VALUES ($1, $2, $3, $4);`
+	if _, err := tx.ExecContext(ctx, query, id, identity.Provider, identity.Subject, identity.Email); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("repository: create user with identity: insert identity %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("repository: create user with identity: commit transaction %w", err)
+	}
+
+	return id, nil
+}
+
+// GetIdentities lists the provider accounts linked to a user
+func (u *AuthRepository) GetIdentities(userId int) ([]entity.Identity, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT identityId, userId, provider, subject, IFNULL(email, ''), createdAt FROM user_identities WHERE userId = $1 ORDER BY identityId;`
+	rows, err := u.db.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("repository: get identities: query %w", err)
+	}
+	defer rows.Close()
+
+	var identities []entity.Identity
+	for rows.Next() {
+		var identity entity.Identity
+		if err := rows.Scan(&identity.IdentityId, &identity.UserId, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository: get identities: scan %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository: get identities: rows error %w", err)
+	}
+
+	return identities, nil
+}
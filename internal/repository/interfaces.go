@@ -11,6 +11,10 @@ type Repository struct {
 	PostVoter
 	Commenter
 	User
+	Moderation
+	Reports
+	Categories
+	Uploads
 }
 
 func NewRepository(db *sql.DB, cnf *config.Config) *Repository {
@@ -20,5 +24,9 @@ func NewRepository(db *sql.DB, cnf *config.Config) *Repository {
 		PostVoter:     NewPostVotingRepostiry(db, cnf),
 		Commenter:     NewCommentsRepostiry(db, cnf),
 		User:          NewUserRepository(db, cnf),
+		Moderation:    NewModerationRepository(db, cnf),
+		Reports:       NewReportsRepository(db, cnf),
+		Categories:    NewCategoriesRepository(db, cnf),
+		Uploads:       NewUploadsRepository(db, cnf),
 	}
 }
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/config"
+	"forum/internal/entity"
+)
+
+const insertAuditQuery = `INSERT INTO audit_log (actor, action, targetType, targetId, details) VALUES ($1, $2, $3, $4, $5);`
+
+// Moderation changes content and users on behalf of moderators, every change
+// is written to the audit log together with entry in one transaction
+type Moderation interface {
+	DeletePost(postId int, entry entity.AuditEntry) ([]string, error)
+	DeleteComment(commentId int, entry entity.AuditEntry) error
+	SetPostLocked(postId int, locked bool, entry entity.AuditEntry) error
+	SetUserSuspended(username string, until time.Time, entry entity.AuditEntry) error
+	SetUserRole(username, role string, entry entity.AuditEntry) error
+	ResetTwoFactor(userId int, entry entity.AuditEntry) error
+	CreateAuditEntry(entry entity.AuditEntry) error
+	GetAuditLog(limit int) ([]entity.AuditEntry, error)
+}
+
+type ModerationRepository struct {
+	db  *sql.DB
+	cnf *config.Config
+}
+
+func NewModerationRepository(db *sql.DB, cnf *config.Config) *ModerationRepository {
+	return &ModerationRepository{
+		db:  db,
+		cnf: cnf,
+	}
+}
+
+// DeletePost removes the post like DeletePostById and returns the upload
+// files of its attachments
+func (m *ModerationRepository) DeletePost(postId int, entry entity.AuditEntry) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var files []string
+	err := withAudit(ctx, m.db, &entry, func(tx *sql.Tx) error {
+		var err error
+		files, err = deletePost(ctx, tx, postId)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repository: moderation delete post: %w", err)
+	}
+
+	return files, nil
+}
+
+// DeleteComment leaves a tombstone like CommentsRepository.DeleteComment
+func (m *ModerationRepository) DeleteComment(commentId int, entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	err := withAudit(ctx, m.db, &entry, func(tx *sql.Tx) error {
+		return deleteComment(ctx, tx, commentId)
+	})
+	if err != nil {
+		return fmt.Errorf("repository: moderation delete comment: %w", err)
+	}
+
+	return nil
+}
+
+func (m *ModerationRepository) SetPostLocked(postId int, locked bool, entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	err := withAudit(ctx, m.db, &entry, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE posts SET locked = $1 WHERE postId = $2;`, locked, postId)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("repository: set post locked: %w", err)
+	}
+
+	return nil
+}
+
+// SetUserSuspended suspends the user until the given time and signs them out,
+// zero time lifts the suspension
+func (m *ModerationRepository) SetUserSuspended(username string, until time.Time, entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var suspended sql.NullTime
+	if !until.IsZero() {
+		suspended = sql.NullTime{Time: until, Valid: true}
+	}
+
+	err := withAudit(ctx, m.db, &entry, func(tx *sql.Tx) error {
+		query := `UPDATE user SET suspendedUntil = $1 WHERE username = $2;`
+		if _, err := tx.ExecContext(ctx, query, suspended, username); err != nil {
+			return err
+		}
+
+		// a suspension must not leave live sessions behind
+		if suspended.Valid {
+			query = `DELETE FROM sessions WHERE userId = (SELECT userId FROM user WHERE username = $1);`
+			if _, err := tx.ExecContext(ctx, query, username); err != nil {
+				return fmt.Errorf("delete sessions: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("repository: set user suspended: %w", err)
+	}
+
+	return nil
+}
+
+func (m *ModerationRepository) SetUserRole(username, role string, entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	err := withAudit(ctx, m.db, &entry, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE user SET role = $1 WHERE username = $2;`, role, username)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("repository: set user role: %w", err)
+	}
+
+	return nil
+}
+
+// ResetTwoFactor turns two-factor authentication off like
+// AuthRepository.DeleteTwoFactor
+func (m *ModerationRepository) ResetTwoFactor(userId int, entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	err := withAudit(ctx, m.db, &entry, func(tx *sql.Tx) error {
+		return deleteTwoFactor(ctx, tx, userId)
+	})
+	if err != nil {
+		return fmt.Errorf("repository: reset two factor: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAuditEntry logs an action that is not a change of the database
+// itself, changes log their entry with withAudit
+func (m *ModerationRepository) CreateAuditEntry(entry entity.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	if _, err := m.db.ExecContext(ctx, insertAuditQuery, entry.Actor, entry.Action, entry.TargetType, entry.TargetId, entry.Details); err != nil {
+		return fmt.Errorf("repository: create audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// withAudit runs change and writes entry to the audit log in one
+// transaction, so no action is done without its entry. change may fill in
+// entry, e.g. with the id of a new row.
+func withAudit(ctx context.Context, db *sql.DB, entry *entity.AuditEntry, change func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("transaction %w", err)
+	}
+
+	if err := change(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, insertAuditQuery, entry.Actor, entry.Action, entry.TargetType, entry.TargetId, entry.Details); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("audit entry: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditLog returns the latest limit entries, newest first
+func (m *ModerationRepository) GetAuditLog(limit int) ([]entity.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT auditId, actor, action, targetType, targetId, details, createdAt FROM audit_log ORDER BY auditId DESC LIMIT $1;`
+	rows, err := m.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("repository: get audit log: query %w", err)
+	}
+
+	defer rows.Close()
+
+	var entries []entity.AuditEntry
+	for rows.Next() {
+		var entry entity.AuditEntry
+
+		if err := rows.Scan(&entry.AuditId, &entry.Actor, &entry.Action, &entry.TargetType, &entry.TargetId, &entry.Details, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository: get audit log: scan %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository: get audit log: rows error %w", err)
+	}
+
+	return entries, nil
+}
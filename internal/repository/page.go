@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"forum/internal/entity"
+)
+
+// sqlite stores creationDate as text in this layout
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// keyset returns the ORDER BY of a feed and the condition that skips
+// everything up to and including the cursor
+func keyset(order entity.PostOrder, cursor entity.Cursor) (orderBy, after string, args []interface{}) {
+	var key string
+	var value interface{}
+	desc := true
+
+	switch order {
+	case entity.OrderMostLiked:
+		key, value = "likes", cursor.Votes
+	case entity.OrderMostDisliked:
+		key, value = "dislikes", cursor.Votes
+	case entity.OrderTopScore:
+		key, value = "(likes - dislikes)", cursor.Votes
+	case entity.OrderOldest:
+		key, value = "creationDate", cursor.Time.UTC().Format(sqliteTimeLayout)
+		desc = false
+	default:
+		key, value = "creationDate", cursor.Time.UTC().Format(sqliteTimeLayout)
+	}
+
+	if desc {
+		orderBy = key + " DESC, postId DESC"
+		after = "(" + key + ", postId) < (?, ?)"
+	} else {
+		orderBy = key + ", postId"
+		after = "(" + key + ", postId) > (?, ?)"
+	}
+
+	if cursor.IsZero() {
+		return orderBy, "", nil
+	}
+	return orderBy, after, []interface{}{value, cursor.PostId}
+}
+
+// filterClause turns filter into a WHERE condition with ? placeholders
+func filterClause(filter entity.PostFilter) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	if len(filter.Categories) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.Categories)), ", ")
+		conditions = append(conditions, "postId IN (SELECT postCategoryId FROM posts_category WHERE category IN ("+placeholders+"))")
+		for _, category := range filter.Categories {
+			args = append(args, category)
+		}
+	}
+	if filter.Author != "" {
+		conditions = append(conditions, "author = ?")
+		args = append(args, filter.Author)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "creationDate >= ?")
+		args = append(args, filter.From.UTC().Format(sqliteTimeLayout))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "creationDate < ?")
+		args = append(args, filter.To.UTC().Format(sqliteTimeLayout))
+	}
+	if filter.HasMinScore {
+		conditions = append(conditions, "likes - dislikes >= ?")
+		args = append(args, filter.MinScore)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// queryPostsPage lists at most page.Limit posts matching where in order,
+// starting after page.Cursor. where uses ? placeholders bound to args.
+func queryPostsPage(ctx context.Context, db *sql.DB, op, where string, args []interface{}, order entity.PostOrder, page entity.PageRequest) ([]entity.Post, error) {
+	orderBy, after, afterArgs := keyset(order, page.Cursor)
+
+	query := `SELECT postId, author, title, content, IFNULL(contentHtml, ''), creationDate, likes, dislikes FROM posts WHERE (` + where + `)`
+	if after != "" {
+		query += ` AND ` + after
+		args = append(args, afterArgs...)
+	}
+	query += ` ORDER BY ` + orderBy + ` LIMIT ?;`
+	args = append(args, page.Limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository: %s: query %w", op, err)
+	}
+
+	defer rows.Close()
+
+	var posts []entity.Post
+	for rows.Next() {
+		var post entity.Post
+
+		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.ContentHTML, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
+			return nil, fmt.Errorf("repository: %s: scan %w", op, err)
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository: %s: rows error %w", op, err)
+	}
+
+	return posts, nil
+}
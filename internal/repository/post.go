@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"forum/config"
@@ -12,16 +13,14 @@ import (
 
 type Posts interface {
 	CreatePost(post entity.Post) (int, error)
-	GetAllPosts() ([]entity.Post, error)
+	GetPosts(filter entity.PostFilter, page entity.PageRequest) ([]entity.Post, error)
 	GetPostbyId(id int) (entity.Post, error)
-	GetPostsByCategory(category string) ([]entity.Post, error)
 	CategoriesByPostId(id int) ([]string, error)
-	GetCreatedPosts(author string) ([]entity.Post, error)
+	GetAttachmentsByPostId(postId int) ([]entity.Attachment, error)
 	UpdatePostById(post entity.Post) error
-	GetNewstPosts() ([]entity.Post, error)
-	GetOldesPosts() ([]entity.Post, error)
-	GetMostLikedPosts() ([]entity.Post, error)
-	GetMostDisikedPosts() ([]entity.Post, error)
+	DeletePostById(id int) ([]string, error)
+	GetPostRevisions(postId int) ([]entity.PostRevision, error)
+	Search(query entity.SearchQuery, limit int) ([]entity.SearchResult, error)
 }
 
 type PostRepository struct {
@@ -44,9 +43,9 @@ func (p *PostRepository) CreatePost(post entity.Post) (int, error) {
 	if err != nil {
 		return 0, fmt.Errorf("repository: create post: transaction %w", err)
 	}
-	query := `INSERT INTO posts (author, title, content) VALUES ($1, $2, $3) RETURNING postId;`
+	query := `INSERT INTO posts (author, title, content, contentHtml) VALUES ($1, $2, $3, $4) RETURNING postId;`
 	var id int
-	if err := tx.QueryRowContext(ctx, query, post.PostAuthor, post.Title, post.Content).Scan(&id); err != nil {
+	if err := tx.QueryRowContext(ctx, query, post.PostAuthor, post.Title, post.Content, post.ContentHTML).Scan(&id); err != nil {
 		tx.Rollback()
 		return 0, fmt.Errorf("repository: create post: insert query %w", err)
 	}
@@ -67,6 +66,16 @@ func (p *PostRepository) CreatePost(post entity.Post) (int, error) {
 		}
 	}
 
+	query = `INSERT INTO attachments (postId, filename, thumbnail, originalName, contentType, size, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	for _, a := range post.Attachments {
+		_, err = tx.ExecContext(ctx, query, id, a.Filename, a.Thumbnail, a.OriginalName, a.ContentType, a.Size, a.Width, a.Height)
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("repository: create post: insert attachment %w", err)
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return 0, fmt.Errorf("repository: create post: commit transaction %w", err)
@@ -75,78 +84,30 @@ func (p *PostRepository) CreatePost(post entity.Post) (int, error) {
 	return id, nil
 }
 
-func (p *PostRepository) GetAllPosts() ([]entity.Post, error) {
+// GetPosts lists one page of the posts matching filter in filter.Order
+func (p *PostRepository) GetPosts(filter entity.PostFilter, page entity.PageRequest) ([]entity.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT postId, author, title, content, creationDate, likes, dislikes FROM posts;`
-	rows, err := p.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, fmt.Errorf("repository: get all posts: %w", err)
-	}
-
-	defer rows.Close()
-
-	var posts []entity.Post
-	for rows.Next() {
-		var post entity.Post
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository: get all posts: scan: %w", err)
-		}
-
-		posts = append(posts, post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository: get all posts:  rows.err %w", err)
-	}
-
-	return posts, nil
+	where, args := filterClause(filter)
+	return queryPostsPage(ctx, p.db, "get posts", where, args, filter.Order, page)
 }
 
 func (p *PostRepository) GetPostbyId(id int) (entity.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT postId, author, title, content, creationDate, likes, dislikes FROM posts WHERE postId = $1;`
+	query := `SELECT postId, author, title, content, IFNULL(contentHtml, ''), creationDate, updatedAt, likes, dislikes, locked FROM posts WHERE postId = $1;`
 	var post entity.Post
-	if err := p.db.QueryRowContext(ctx, query, id).Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
+	var updated sql.NullTime
+	if err := p.db.QueryRowContext(ctx, query, id).Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.ContentHTML, &post.CreationTime, &updated, &post.Likes, &post.Dislikes, &post.Locked); err != nil {
 		return entity.Post{}, fmt.Errorf("repository: get postbyID: query %w", err)
 	}
+	post.UpdatedTime = updated.Time
 
 	return post, nil
 }
 
-func (p *PostRepository) GetPostsByCategory(category string) ([]entity.Post, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
-
-	query := `SELECT postId, author, title, content, creationDate, likes, dislikes FROM posts WHERE postId IN (SELECT postCategoryId FROM posts_category WHERE category = $1);`
-	rows, err := p.db.QueryContext(ctx, query, category)
-	if err != nil {
-		return nil, fmt.Errorf("repository: get postbyCategory: query %w", err)
-	}
-
-	defer rows.Close()
-
-	var posts []entity.Post
-	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository: get postbyCategory: scan %w", err)
-		}
-
-		posts = append(posts, post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository: get postbyCategory: rows error %w", err)
-	}
-
-	return posts, nil
-}
-
 func (p *PostRepository) CategoriesByPostId(id int) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
@@ -177,166 +138,191 @@ func (p *PostRepository) CategoriesByPostId(id int) ([]string, error) {
 	return categories, nil
 }
 
-func (p *PostRepository) GetCreatedPosts(author string) ([]entity.Post, error) {
+// UpdatePostById stores the current version of the post as a revision
+// and replaces title, content, its rendered HTML and categories in one transaction
+func (p *PostRepository) UpdatePostById(post entity.Post) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT postId, author, title, context, creationDate, likes, dislikes FROM posts WHERE author = $1;`
-
-	rows, err := p.db.QueryContext(ctx, query, author)
+	tx, err := p.db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("repository: get createdtposts: query %w", err)
+		return fmt.Errorf("repository: update by id: transaction %w", err)
 	}
 
-	defer rows.Close()
-
-	var posts []entity.Post
-	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository: get createdtposts: scan %w", err)
-		}
-
-		posts = append(posts, post)
+	query := `INSERT INTO post_revisions (postId, title, content, categories)
+		SELECT postId, title, content, (SELECT group_concat(category, ',') FROM posts_category WHERE postCategoryId = $1)
+		FROM posts WHERE postId = $1;`
+	if _, err := tx.ExecContext(ctx, query, post.PostId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: update by id: save revision %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository: get createdtposts: rows error %w", err)
+	query = `UPDATE posts SET title = $1, content = $2, contentHtml = $3, updatedAt = datetime('now') WHERE postId = $4;`
+	if _, err := tx.ExecContext(ctx, query, post.Title, post.Content, post.ContentHTML, post.PostId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: update by id: query %w", err)
 	}
 
-	return posts, nil
-}
+	query = `DELETE FROM posts_category WHERE postCategoryId = $1;`
+	if _, err := tx.ExecContext(ctx, query, post.PostId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: update by id: delete categories %w", err)
+	}
 
-func (p *PostRepository) UpdatePostById(post entity.Post) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
+	query = `INSERT INTO posts_category (postCategoryId, category) VALUES ($1,$2);`
+	for _, category := range post.Category {
+		if _, err := tx.ExecContext(ctx, query, post.PostId, category); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("repository: update by id: insert category %w", err)
+		}
+	}
 
-	query := `UPDATE posts SET title = $1, content = $2 WHERE postId = $3;`
-	_, err := p.db.ExecContext(ctx, query, post.Title, post.Content, post.PostId)
-	if err != nil {
-		return fmt.Errorf("repository: update by id: query %w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: update by id: commit transaction %w", err)
 	}
 
 	return nil
 }
 
-func (p *PostRepository) GetNewstPosts() ([]entity.Post, error) {
+// DeletePostById removes the post together with its comments, votes,
+// categories, revisions and attachment records and decrements the author's
+// post count. It returns the upload files of the attachments, for the caller
+// to remove the ones nothing else uses.
+func (p *PostRepository) DeletePostById(id int) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT * FROM posts ORDER BY creationDate DESC;`
-	rows, err := p.db.QueryContext(ctx, query)
+	tx, err := p.db.Begin()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("repository: delete post: transaction %w", err)
 	}
 
-	defer rows.Close()
+	files, err := deletePost(ctx, tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("repository: delete post: %w", err)
+	}
 
-	var posts []entity.Post
-	for rows.Next() {
-		var post entity.Post
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("repository: delete post: commit transaction %w", err)
+	}
 
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository: get Newsttposts: scan %w", err)
-		}
+	return files, nil
+}
 
-		posts = append(posts, post)
+func deletePost(ctx context.Context, tx *sql.Tx, id int) ([]string, error) {
+	files, err := attachmentFiles(ctx, tx, `SELECT filename, thumbnail FROM attachments WHERE postId = $1;`, id)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository: get Newsttposts: rows error %w", err)
+	queries := []string{
+		`UPDATE user SET posts = posts - 1 WHERE username = (SELECT author FROM posts WHERE postId = $1);`,
+		`UPDATE reports SET status = 'resolved', resolvedAt = datetime('now') WHERE status = 'open'
+			AND ((targetType = 'post' AND targetId = $1)
+			OR (targetType = 'comment' AND targetId IN (SELECT commentsId FROM comments WHERE postId = $1)));`,
+		`DELETE FROM votes WHERE (targetType = 'post' AND targetId = $1)
+			OR (targetType = 'comment' AND targetId IN (SELECT commentsId FROM comments WHERE postId = $1));`,
+		`DELETE FROM comments WHERE postId = $1;`,
+		`DELETE FROM posts_category WHERE postCategoryId = $1;`,
+		`DELETE FROM post_revisions WHERE postId = $1;`,
+		`DELETE FROM attachments WHERE postId = $1;`,
+		`DELETE FROM posts WHERE postId = $1;`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return nil, fmt.Errorf("query %w", err)
+		}
 	}
 
-	return posts, nil
+	return files, nil
 }
 
-func (p *PostRepository) GetOldesPosts() ([]entity.Post, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
-
-	query := `SELECT postId, author, title, content, creationDate, likes, dislikes FROM posts ORDER BY creationDate;`
-	rows, err := p.db.QueryContext(ctx, query)
+// attachmentFiles returns the files and thumbnails of the attachments query selects
+func attachmentFiles(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("repository: get newstpost: query %w", err)
+		return nil, fmt.Errorf("attachment files: query %w", err)
 	}
-
 	defer rows.Close()
 
-	var posts []entity.Post
+	var files []string
 	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository: get newstpost: scan %w", err)
+		var filename, thumbnail string
+		if err := rows.Scan(&filename, &thumbnail); err != nil {
+			return nil, fmt.Errorf("attachment files: scan %w", err)
 		}
-
-		posts = append(posts, post)
+		files = append(files, filename, thumbnail)
 	}
-
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository: get newstpost: rows error %w", err)
+		return nil, fmt.Errorf("attachment files: rows error %w", err)
 	}
 
-	return posts, nil
+	return files, nil
 }
 
-func (p *PostRepository) GetMostLikedPosts() ([]entity.Post, error) {
+// GetPostRevisions returns prior versions of the post, oldest first
+func (p *PostRepository) GetPostRevisions(postId int) ([]entity.PostRevision, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT postId, author, title, context, creationDate, likes, dislikes FROM posts ORDER BY likes DESC; `
-	rows, err := p.db.QueryContext(ctx, query)
+	query := `SELECT revisionId, postId, title, content, IFNULL(categories, ''), editedAt FROM post_revisions WHERE postId = $1 ORDER BY revisionId;`
+	rows, err := p.db.QueryContext(ctx, query, postId)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("repository: get revisions: query %w", err)
 	}
 
 	defer rows.Close()
 
-	var posts []entity.Post
+	var revisions []entity.PostRevision
 	for rows.Next() {
-		var post entity.Post
+		var revision entity.PostRevision
+		var categories string
 
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository: get newstpost: scan %w", err)
+		if err := rows.Scan(&revision.RevisionId, &revision.PostId, &revision.Title, &revision.Content, &categories, &revision.EditedAt); err != nil {
+			return nil, fmt.Errorf("repository: get revisions: scan %w", err)
+		}
+		if categories != "" {
+			revision.Category = strings.Split(categories, ",")
 		}
 
-		posts = append(posts, post)
+		revisions = append(revisions, revision)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository: get newstpost: rows error %w", err)
+		return nil, fmt.Errorf("repository: get revisions: rows error %w", err)
 	}
 
-	return posts, nil
+	return revisions, nil
 }
 
-func (p *PostRepository) GetMostDisikedPosts() ([]entity.Post, error) {
+// GetAttachmentsByPostId returns the images of a post in upload order
+func (p *PostRepository) GetAttachmentsByPostId(postId int) ([]entity.Attachment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT postId, author, title, context, creationDate, likes, dislikes FROM posts ORDER BY dislikes DESC; `
-	rows, err := p.db.QueryContext(ctx, query)
+	query := `SELECT attachmentId, postId, filename, thumbnail, IFNULL(originalName, ''), contentType, size, width, height, createdAt
+		FROM attachments WHERE postId = $1 ORDER BY attachmentId;`
+	rows, err := p.db.QueryContext(ctx, query, postId)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("repository: get attachments: query %w", err)
 	}
 
 	defer rows.Close()
 
-	var posts []entity.Post
+	var attachments []entity.Attachment
 	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository: get newstpost: scan %w", err)
+		var a entity.Attachment
+		if err := rows.Scan(&a.AttachmentId, &a.PostId, &a.Filename, &a.Thumbnail, &a.OriginalName, &a.ContentType, &a.Size, &a.Width, &a.Height, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("repository: get attachments: scan %w", err)
 		}
 
-		posts = append(posts, post)
+		attachments = append(attachments, a)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository: get newstpost: rows error %w", err)
+		return nil, fmt.Errorf("repository: get attachments: rows error %w", err)
 	}
 
-	return posts, nil
+	return attachments, nil
 }
@@ -11,14 +11,10 @@ import (
 )
 
 type PostVoter interface {
-	LikePost(postId int, username string) error
-	DislikePost(postId int, username string) error
-	RemoveDislikePost(postId int, username string) error
-	RemoveLikePost(postId int, username string) error
-	LikePostByUser(postId int, username string) error
-	DislikePostByUser(postId int, username string) error
+	SetPostVote(postId int, username string, value int) (entity.VoteCount, error)
 	GetPostLikes(postId int) ([]string, error)
 	GetPostDislikes(postId int) ([]string, error)
+	ReconcileVotes(ctx context.Context, fix bool) ([]entity.VoteDrift, error)
 }
 
 type PostVotingRepository struct {
@@ -33,158 +29,150 @@ func NewPostVotingRepostiry(db *sql.DB, config *config.Config) *PostVotingReposi
 	}
 }
 
-func (p *PostVotingRepository) LikePost(postId int, username string) error {
+// SetPostVote replaces the vote of the user on a post and returns the new
+// counts, sql.ErrNoRows means there is no such post
+func (p *PostVotingRepository) SetPostVote(postId int, username string, value int) (entity.VoteCount, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	tx, err := p.db.Begin()
+	count, err := setVote(ctx, p.db, entity.VoteTargetPost, "posts", "postId", postId, username, value)
 	if err != nil {
-		return fmt.Errorf("repository:postvoting:like: begin %w", err)
+		return entity.VoteCount{}, fmt.Errorf("repository: postvoting: set vote: %w", err)
 	}
 
-	query := `INSERT INTO likes (username, postId) VALUES ($1,$2);`
-	_, err = tx.ExecContext(ctx, query, username, postId)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("repository:postvoting:like: query1 %w", err)
-	}
+	return count, nil
+}
 
-	query = `UPDATE posts SET likes = likes + 1 WHERE postId = $1;`
-	_, err = tx.ExecContext(ctx, query, postId)
+// setVote stores the vote of username on the post or comment with id, or
+// removes it for entity.VoteNone, the triggers on votes keep the counters of
+// table in step so they are read back in the same transaction. column is
+// postId or commentsId, the key of table. deleted comments count as missing
+// for new votes, a vote on them can still be taken back.
+func setVote(ctx context.Context, db *sql.DB, targetType, table, column string, id int, username string, value int) (entity.VoteCount, error) {
+	tx, err := db.Begin()
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("repository:postvoting:like: update %w", err)
+		return entity.VoteCount{}, fmt.Errorf("begin %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("repository:postvoting:like: commit %w", err)
+	var exists int
+	query := `SELECT 1 FROM ` + table + ` WHERE ` + column + ` = $1`
+	if targetType == entity.VoteTargetComment && value != entity.VoteNone {
+		// tombstones keep their votes but take no new ones
+		query += ` AND deleted = 0`
+	}
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		tx.Rollback()
+		return entity.VoteCount{}, fmt.Errorf("target %w", err)
 	}
 
-	return nil
-}
-
-func (p *PostVotingRepository) DislikePost(postId int, username string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
-
-	tx, err := p.db.Begin()
-	if err != nil {
-		return fmt.Errorf("repository:postvoting:dislike: begin %w", err)
+	if value == entity.VoteNone {
+		query = `DELETE FROM votes WHERE username = $1 AND targetType = $2 AND targetId = $3;`
+		_, err = tx.ExecContext(ctx, query, username, targetType, id)
+	} else {
+		query = `INSERT INTO votes (username, targetType, targetId, value) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (username, targetType, targetId) DO UPDATE SET value = excluded.value
+			WHERE value != excluded.value;`
+		_, err = tx.ExecContext(ctx, query, username, targetType, id, value)
 	}
-	query := `INSERT INTO dislikes (username, postId) VALUES ($1, $2);`
-	_, err = tx.ExecContext(ctx, query, username, postId)
 	if err != nil {
 		tx.Rollback()
-		return fmt.Errorf("repository:postvoting:dislike:query1 %w", err)
+		return entity.VoteCount{}, fmt.Errorf("vote %w", err)
 	}
 
-	query = `UPDATE posts SET dislikes = dislikes + 1 WHERE postId = $1;`
-	_, err = tx.ExecContext(ctx, query, postId)
-	if err != nil {
+	query = `SELECT likes, dislikes FROM ` + table + ` WHERE ` + column + ` = $1;`
+	count := entity.VoteCount{Vote: value}
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&count.Likes, &count.Dislikes); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("repository:postvoting:dislike: update %w", err)
+		return entity.VoteCount{}, fmt.Errorf("count %w", err)
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return fmt.Errorf("repository:postvoting:dislike: commit %w", err)
+	if err := tx.Commit(); err != nil {
+		return entity.VoteCount{}, fmt.Errorf("commit %w", err)
 	}
 
-	return nil
+	return count, nil
 }
 
-func (p *PostVotingRepository) RemoveDislikePost(postId int, username string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
-
-	tx, err := p.db.Begin()
+// ReconcileVotes compares the likes and dislikes counters of every post and
+// comment with their vote rows and returns the ones that differ, with fix
+// the counters are rewritten from the rows in the same transaction. It goes
+// through every row, so ctx is up to the caller and not the request timeout.
+func (p *PostVotingRepository) ReconcileVotes(ctx context.Context, fix bool) ([]entity.VoteDrift, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("repository: postvoting:removedislike: begin %w", err)
+		return nil, fmt.Errorf("repository: postvoting: reconcile: begin %w", err)
 	}
 
-	query := `DELETE FROM dislikes WHERE username = $1 AND postId = $2;`
-	_, err = tx.ExecContext(ctx, query, username, postId)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("repository: postvoting:removedislike: delete %w", err)
+	var drifts []entity.VoteDrift
+	targets := []struct{ targetType, table, column string }{
+		{entity.VoteTargetPost, "posts", "postId"},
+		{entity.VoteTargetComment, "comments", "commentsId"},
 	}
+	for _, t := range targets {
+		found, err := voteDrift(ctx, tx, t.targetType, t.table, t.column)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("repository: postvoting: reconcile %s: %w", t.table, err)
+		}
 
-	query = `UPDATE posts SET dislikes = dislikes - 1 WHERE postId = $1;`
-	_, err = tx.ExecContext(ctx, query, postId)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("repository: postvoting:removedislike: update %w", err)
-	}
+		if fix {
+			query := `UPDATE ` + t.table + ` SET likes = $1, dislikes = $2 WHERE ` + t.column + ` = $3;`
+			for _, d := range found {
+				if _, err := tx.ExecContext(ctx, query, d.Likes, d.Dislikes, d.TargetId); err != nil {
+					tx.Rollback()
+					return nil, fmt.Errorf("repository: postvoting: reconcile %s: update %w", t.table, err)
+				}
+			}
+		}
 
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("repository: postvoting:removedislike: commit %w", err)
+		drifts = append(drifts, found...)
 	}
 
-	return nil
-}
-
-func (p *PostVotingRepository) RemoveLikePost(postId int, username string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
-
-	tx, err := p.db.Begin()
-	if err != nil {
-		return fmt.Errorf("repository: postvoting:removelike: begin %w", err)
-	}
-	query := `DELETE FROM likes WHERE username = $1 AND postId = $2;`
-	_, err = tx.ExecContext(ctx, query, username, postId)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("repository: postvoting:removelike: delete %w", err)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("repository: postvoting: reconcile: commit %w", err)
 	}
 
-	query = `UPDATE posts SET likes = likes - 1 WHERE postId = $1;`
-	_, err = tx.ExecContext(ctx, query, postId)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("repository: postvoting:removelike: update%w", err)
-	}
+	return drifts, nil
+}
 
-	err = tx.Commit()
+// voteDrift lists the rows of table whose counters do not match the votes on them
+func voteDrift(ctx context.Context, tx *sql.Tx, targetType, table, column string) ([]entity.VoteDrift, error) {
+	query := `SELECT id, likes, dislikes, counted_likes, counted_dislikes FROM (
+		SELECT t.` + column + ` AS id, t.likes, t.dislikes,
+			(SELECT COUNT(*) FROM votes v WHERE v.targetType = $1 AND v.targetId = t.` + column + ` AND v.value = 1) AS counted_likes,
+			(SELECT COUNT(*) FROM votes v WHERE v.targetType = $1 AND v.targetId = t.` + column + ` AND v.value = -1) AS counted_dislikes
+		FROM ` + table + ` t)
+		WHERE likes IS NOT counted_likes OR dislikes IS NOT counted_dislikes ORDER BY id;`
+	rows, err := tx.QueryContext(ctx, query, targetType)
 	if err != nil {
-		return fmt.Errorf("repository: postvoting:removelike: commit %w", err)
+		return nil, fmt.Errorf("query %w", err)
 	}
+	defer rows.Close()
 
-	return nil
-}
-
-// postliked
-func (p *PostVotingRepository) LikePostByUser(postId int, username string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
+	var drifts []entity.VoteDrift
+	for rows.Next() {
+		d := entity.VoteDrift{TargetType: targetType}
+		var likes, dislikes sql.NullInt64
+		if err := rows.Scan(&d.TargetId, &likes, &dislikes, &d.Likes, &d.Dislikes); err != nil {
+			return nil, fmt.Errorf("scan %w", err)
+		}
+		d.StoredLikes, d.StoredDislikes = int(likes.Int64), int(dislikes.Int64)
 
-	var user entity.UserModel
-	query := `SELECT username FROM likes WHERE postId = $1 AND username = $2;`
-	if err := p.db.QueryRowContext(ctx, query, postId, username).Scan(&user.Username); err != nil {
-		return fmt.Errorf("repository: postvoting:likepostbyusert %w", err)
+		drifts = append(drifts, d)
 	}
 
-	return nil
-}
-
-func (p *PostVotingRepository) DislikePostByUser(postId int, username string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
-	defer cancel()
-
-	var user string
-	query := `SELECT username FROM dislikes WHERE postId = $1 AND username = $2;`
-	if err := p.db.QueryRowContext(ctx, query, postId, username).Scan(&user); err != nil {
-		return fmt.Errorf("repository: postvoting:likepostbyusert %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error %w", err)
 	}
 
-	return nil
+	return drifts, nil
 }
 
 func (p *PostVotingRepository) GetPostLikes(postId int) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT username FROM likes WHERE postId = $1;`
+	query := `SELECT username FROM votes WHERE targetType = 'post' AND targetId = $1 AND value = 1;`
 	rows, err := p.db.QueryContext(ctx, query, postId)
 	if err != nil {
 		return nil, fmt.Errorf("repository: postvoting:getpostlikes:query %w", err)
@@ -214,7 +202,7 @@ func (p *PostVotingRepository) GetPostDislikes(postId int) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	query := `SELECT username FROM dislikes WHERE postId = $1;`
+	query := `SELECT username FROM votes WHERE targetType = 'post' AND targetId = $1 AND value = -1;`
 	rows, err := p.db.QueryContext(ctx, query, postId)
 	if err != nil {
 		return nil, fmt.Errorf("repository: postvoting:getpostlikes:query %w", err)
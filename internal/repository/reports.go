@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/config"
+	"forum/internal/entity"
+)
+
+type Reports interface {
+	CreateReport(report entity.Report) error
+	HasOpenReport(reporter, targetType string, targetId int) (bool, error)
+	GetOpenReports() ([]entity.Report, error)
+	CloseReports(targetType string, targetId int, status string, entry entity.AuditEntry) (int, error)
+}
+
+type ReportsRepository struct {
+	db  *sql.DB
+	cnf *config.Config
+}
+
+func NewReportsRepository(db *sql.DB, cnf *config.Config) *ReportsRepository {
+	return &ReportsRepository{
+		db:  db,
+		cnf: cnf,
+	}
+}
+
+func (r *ReportsRepository) CreateReport(report entity.Report) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO reports (reporter, targetType, targetId, reason, details) VALUES ($1, $2, $3, $4, $5);`
+	if _, err := r.db.ExecContext(ctx, query, report.Reporter, report.TargetType, report.TargetId, report.Reason, report.Details); err != nil {
+		return fmt.Errorf("repository: create report: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ReportsRepository) HasOpenReport(reporter, targetType string, targetId int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM reports WHERE reporter = $1 AND targetType = $2 AND targetId = $3 AND status = 'open';`
+	if err := r.db.QueryRowContext(ctx, query, reporter, targetType, targetId).Scan(&count); err != nil {
+		return false, fmt.Errorf("repository: has open report: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// GetOpenReports returns open reports with the post id and a preview of the reported content
+func (r *ReportsRepository) GetOpenReports() ([]entity.Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT r.reportId, r.reporter, r.targetType, r.targetId, r.reason, r.details, r.status, r.createdAt,
+			IFNULL(CASE r.targetType WHEN 'post' THEN p.postId ELSE c.postId END, 0),
+			IFNULL(CASE r.targetType WHEN 'post' THEN p.title ELSE c.content END, '')
+		FROM reports r
+		LEFT JOIN posts p ON r.targetType = 'post' AND p.postId = r.targetId
+		LEFT JOIN comments c ON r.targetType = 'comment' AND c.commentsId = r.targetId
+		WHERE r.status = 'open'
+		ORDER BY r.reportId;`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("repository: get open reports: query %w", err)
+	}
+
+	defer rows.Close()
+
+	var reports []entity.Report
+	for rows.Next() {
+		var report entity.Report
+
+		if err := rows.Scan(&report.ReportId, &report.Reporter, &report.TargetType, &report.TargetId, &report.Reason, &report.Details,
+			&report.Status, &report.CreatedAt, &report.PostId, &report.Preview); err != nil {
+			return nil, fmt.Errorf("repository: get open reports: scan %w", err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository: get open reports: rows error %w", err)
+	}
+
+	return reports, nil
+}
+
+// resolveReportsQuery closes the open reports of a post or comment that is
+// deleted, there is nothing left for a moderator to look at
+const resolveReportsQuery = `UPDATE reports SET status = 'resolved', resolvedAt = datetime('now') WHERE targetType = $1 AND targetId = $2 AND status = 'open';`
+
+// CloseReports marks all open reports of the target with status as the actor
+// of entry and returns how many were closed. entry goes to the audit log in
+// the same transaction with the count as details, sql.ErrNoRows means there
+// was no open report.
+func (r *ReportsRepository) CloseReports(targetType string, targetId int, status string, entry entity.AuditEntry) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var closed int64
+	err := withAudit(ctx, r.db, &entry, func(tx *sql.Tx) error {
+		query := `UPDATE reports SET status = $1, resolvedBy = $2, resolvedAt = datetime('now') WHERE targetType = $3 AND targetId = $4 AND status = 'open';`
+		res, err := tx.ExecContext(ctx, query, status, entry.Actor, targetType, targetId)
+		if err != nil {
+			return fmt.Errorf("query %w", err)
+		}
+
+		closed, err = res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("rows affected %w", err)
+		}
+		if closed == 0 {
+			return sql.ErrNoRows
+		}
+		entry.Details = fmt.Sprintf("%d reports", closed)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repository: close reports: %w", err)
+	}
+
+	return int(closed), nil
+}
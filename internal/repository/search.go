@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/internal/entity"
+)
+
+// Search looks the query up in posts and comments, best matches first.
+// Title matches weigh more than content matches. A query with filters only
+// lists the newest posts and comments of the author or category.
+func (p *PostRepository) Search(query entity.SearchQuery, limit int) ([]entity.SearchResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	match := ftsMatch(query)
+
+	args := []interface{}{sql.Named("limit", limit)}
+	var postsQuery, commentsQuery string
+	if match != "" {
+		postsQuery = `SELECT 'post', p.postId, 0, p.title, p.author,
+			snippet(posts_fts, -1, $start, $end, '...', 24), bm25(posts_fts, 10.0, 1.0)
+			FROM posts_fts JOIN posts p ON p.postId = posts_fts.rowid
+			WHERE posts_fts MATCH $match`
+		commentsQuery = `SELECT 'comment', c.postId, c.commentsId, p.title, c.author,
+			snippet(comments_fts, 0, $start, $end, '...', 24), bm25(comments_fts)
+			FROM comments_fts JOIN comments c ON c.commentsId = comments_fts.rowid
+			JOIN posts p ON p.postId = c.postId
+			WHERE comments_fts MATCH $match AND IFNULL(c.deleted, 0) = 0`
+		args = append(args,
+			sql.Named("start", entity.SearchMatchStart),
+			sql.Named("end", entity.SearchMatchEnd),
+			sql.Named("match", match),
+		)
+	} else {
+		postsQuery = `SELECT 'post', p.postId, 0, p.title, p.author, substr(p.content, 1, 200), 0
+			FROM posts p WHERE 1 = 1`
+		commentsQuery = `SELECT 'comment', c.postId, c.commentsId, p.title, c.author, substr(c.content, 1, 200), 0
+			FROM comments c JOIN posts p ON p.postId = c.postId
+			WHERE IFNULL(c.deleted, 0) = 0`
+	}
+
+	if query.Author != "" {
+		postsQuery += ` AND p.author = $author`
+		commentsQuery += ` AND c.author = $author`
+		args = append(args, sql.Named("author", query.Author))
+	}
+	if query.Category != "" {
+		category := ` AND EXISTS (SELECT 1 FROM posts_category pc WHERE pc.postCategoryId = p.postId AND pc.category = $category)`
+		postsQuery += category
+		commentsQuery += category
+		args = append(args, sql.Named("category", query.Category))
+	}
+
+	// newest first among equally ranked results
+	sqlQuery := `SELECT * FROM (` + postsQuery + ` UNION ALL ` + commentsQuery + `)
+		ORDER BY 7, 2 DESC, 3 DESC LIMIT $limit;`
+
+	rows, err := p.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("repository: search: query: %w", err)
+	}
+	defer rows.Close()
+
+	var results []entity.SearchResult
+	for rows.Next() {
+		var result entity.SearchResult
+		var rank float64
+		if err := rows.Scan(&result.Kind, &result.PostId, &result.CommentId, &result.Title, &result.Author, &result.Snippet, &rank); err != nil {
+			return nil, fmt.Errorf("repository: search: scan: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository: search: rows error: %w", err)
+	}
+
+	return results, nil
+}
+
+// ftsMatch builds an FTS5 MATCH expression, every word and phrase is quoted
+// so user input can not use FTS5 operators, a trailing * keeps prefix search
+func ftsMatch(query entity.SearchQuery) string {
+	var parts []string
+	for _, term := range query.Terms {
+		prefix := strings.HasSuffix(term, "*")
+		part := ftsQuote(strings.TrimRight(term, "*"))
+		if prefix {
+			part += "*"
+		}
+		parts = append(parts, part)
+	}
+	for _, phrase := range query.Phrases {
+		parts = append(parts, ftsQuote(phrase))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func ftsQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/internal/entity"
+)
+
+// CreateSession stores a new session, expired sessions of the same user are
+// removed on the way
+func (u *AuthRepository) CreateSession(session entity.Session) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return fmt.Errorf("repository: create session: transaction %w", err)
+	}
+
+	created := session.CreatedAt.UTC().Format(sqliteTimeLayout)
+	query := `DELETE FROM sessions WHERE userId = $1 AND expiresAt <= $2;`
+	if _, err := tx.ExecContext(ctx, query, session.UserId, created); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: create session: delete expired %w", err)
+	}
+
+	query = `INSERT INTO sessions (tokenHash, userId, createdAt, lastSeenAt, expiresAt, userAgent, ip) VALUES ($1, $2, $3, $3, $4, $5, $6);`
+	if _, err := tx.ExecContext(ctx, query, session.TokenHash, session.UserId, created,
+		session.ExpiresAt.UTC().Format(sqliteTimeLayout), session.UserAgent, session.IP); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: create session: insert %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: create session: commit transaction %w", err)
+	}
+
+	return nil
+}
+
+// GetUserBySession returns the session with the token hash and its user
+func (u *AuthRepository) GetUserBySession(tokenHash string) (entity.UserModel, entity.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var user entity.UserModel
+	var session entity.Session
+	var suspended sql.NullTime
+	query := `SELECT u.userId, u.username, u.email, IFNULL(u.emailVerified, 0), u.password, u.creationDate, IFNULL(u.role, 'user'), u.suspendedUntil,
+		IFNULL(u.displayName, ''), IFNULL(u.bio, ''), IFNULL(u.avatar, ''), IFNULL(u.website, ''),
+		s.sessionId, s.tokenHash, s.createdAt, s.lastSeenAt, s.expiresAt, IFNULL(s.userAgent, ''), IFNULL(s.ip, '')
+		FROM sessions s JOIN user u ON u.userId = s.userId
+		WHERE s.tokenHash = $1;`
+	if err := u.db.QueryRowContext(ctx, query, tokenHash).Scan(&user.UserId, &user.Username, &user.Email, &user.EmailVerified, &user.Password, &user.CreatedAt, &user.Role, &suspended,
+		&user.DisplayName, &user.Bio, &user.Avatar, &user.Website,
+		&session.SessionId, &session.TokenHash, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IP); err != nil {
+		return entity.UserModel{}, entity.Session{}, fmt.Errorf("repository: get user by session: %w", err)
+	}
+	user.SuspendedUntil = suspended.Time
+	session.UserId = user.UserId
+
+	return user, session, nil
+}
+
+// TouchSession records that the session was used at now and moves its expiration
+func (u *AuthRepository) TouchSession(sessionId int, now, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `UPDATE sessions SET lastSeenAt = $1, expiresAt = $2 WHERE sessionId = $3;`
+	if _, err := u.db.ExecContext(ctx, query, now.UTC().Format(sqliteTimeLayout), expiresAt.UTC().Format(sqliteTimeLayout), sessionId); err != nil {
+		return fmt.Errorf("repository: touch session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessions lists the sessions of a user that have not expired at now,
+// most recently used first
+func (u *AuthRepository) GetSessions(userId int, now time.Time) ([]entity.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT sessionId, userId, createdAt, lastSeenAt, expiresAt, IFNULL(userAgent, ''), IFNULL(ip, '')
+		FROM sessions WHERE userId = $1 AND expiresAt > $2 ORDER BY lastSeenAt DESC, sessionId DESC;`
+	rows, err := u.db.QueryContext(ctx, query, userId, now.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("repository: get sessions: query %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []entity.Session
+	for rows.Next() {
+		var session entity.Session
+		if err := rows.Scan(&session.SessionId, &session.UserId, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.UserAgent, &session.IP); err != nil {
+			return nil, fmt.Errorf("repository: get sessions: scan %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("repository: get sessions: rows error %w", err)
+	}
+
+	return sessions, nil
+}
+
+// DeleteSession ends the session with the token hash
+func (u *AuthRepository) DeleteSession(tokenHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE tokenHash = $1;`
+	if _, err := u.db.ExecContext(ctx, query, tokenHash); err != nil {
+		return fmt.Errorf("repository: delete session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUserSession ends one session of a user, sql.ErrNoRows means the user
+// has no such session
+func (u *AuthRepository) DeleteUserSession(userId, sessionId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE userId = $1 AND sessionId = $2;`
+	res, err := u.db.ExecContext(ctx, query, userId, sessionId)
+	if err != nil {
+		return fmt.Errorf("repository: delete user session: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: delete user session: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// DeleteUserSessions signs a user out everywhere
+func (u *AuthRepository) DeleteUserSessions(userId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE userId = $1;`
+	if _, err := u.db.ExecContext(ctx, query, userId); err != nil {
+		return fmt.Errorf("repository: delete user sessions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/internal/entity"
+)
+
+// GetTwoFactor returns the TOTP secret of a user, sql.ErrNoRows means the
+// user never started setting it up
+func (u *AuthRepository) GetTwoFactor(userId int) (entity.TwoFactor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tf := entity.TwoFactor{UserId: userId}
+	var confirmed sql.NullTime
+	query := `SELECT secret, confirmedAt, lastStep FROM two_factor WHERE userId = $1;`
+	if err := u.db.QueryRowContext(ctx, query, userId).Scan(&tf.Secret, &confirmed, &tf.LastStep); err != nil {
+		return entity.TwoFactor{}, fmt.Errorf("repository: get two factor: %w", err)
+	}
+	tf.ConfirmedAt = confirmed.Time
+
+	return tf, nil
+}
+
+// SaveTwoFactorSecret starts or restarts setting up two-factor authentication,
+// a confirmed secret is kept, sql.ErrNoRows then
+func (u *AuthRepository) SaveTwoFactorSecret(userId int, secret string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO two_factor (userId, secret) VALUES ($1, $2)
+		ON CONFLICT (userId) DO UPDATE SET secret = excluded.secret, createdAt = datetime('now'), lastStep = 0
+		WHERE confirmedAt IS NULL;`
+	res, err := u.db.ExecContext(ctx, query, userId, secret)
+	if err != nil {
+		return fmt.Errorf("repository: save two factor secret: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: save two factor secret: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ConfirmTwoFactor turns two-factor authentication on with the step of the
+// first code and replaces the recovery codes
+func (u *AuthRepository) ConfirmTwoFactor(userId int, step int64, now time.Time, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return fmt.Errorf("repository: confirm two factor: transaction %w", err)
+	}
+
+	query := `UPDATE two_factor SET confirmedAt = $1, lastStep = $2 WHERE userId = $3 AND confirmedAt IS NULL;`
+	res, err := tx.ExecContext(ctx, query, now.UTC().Format(sqliteTimeLayout), step, userId)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: confirm two factor: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		tx.Rollback()
+		return fmt.Errorf("repository: confirm two factor: %w", sql.ErrNoRows)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, codeHashes); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: confirm two factor: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: confirm two factor: commit transaction %w", err)
+	}
+
+	return nil
+}
+
+// UseTwoFactorStep records that the code of step was used, sql.ErrNoRows
+// means a code of this or a later step was used already
+func (u *AuthRepository) UseTwoFactorStep(userId int, step int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `UPDATE two_factor SET lastStep = $1 WHERE userId = $2 AND lastStep < $1;`
+	res, err := u.db.ExecContext(ctx, query, step, userId)
+	if err != nil {
+		return fmt.Errorf("repository: use two factor step: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: use two factor step: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode uses up an unused recovery code, sql.ErrNoRows means there
+// is no such code left
+func (u *AuthRepository) UseRecoveryCode(userId int, codeHash string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `UPDATE recovery_codes SET usedAt = $1 WHERE userId = $2 AND codeHash = $3 AND usedAt IS NULL;`
+	res, err := u.db.ExecContext(ctx, query, now.UTC().Format(sqliteTimeLayout), userId, codeHash)
+	if err != nil {
+		return fmt.Errorf("repository: use recovery code: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("repository: use recovery code: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// CountRecoveryCodes returns how many recovery codes of a user are unused
+func (u *AuthRepository) CountRecoveryCodes(userId int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE userId = $1 AND usedAt IS NULL;`
+	if err := u.db.QueryRowContext(ctx, query, userId).Scan(&count); err != nil {
+		return 0, fmt.Errorf("repository: count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
+
+// ReplaceRecoveryCodes throws the recovery codes of a user away, used or not,
+// and stores new ones
+func (u *AuthRepository) ReplaceRecoveryCodes(userId int, codeHashes []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return fmt.Errorf("repository: replace recovery codes: transaction %w", err)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, codeHashes); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: replace recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: replace recovery codes: commit transaction %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTwoFactor turns two-factor authentication off and removes the
+// secret and the recovery codes
+func (u *AuthRepository) DeleteTwoFactor(userId int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.config.CtxTimeout)*time.Second)
+	defer cancel()
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return fmt.Errorf("repository: delete two factor: transaction %w", err)
+	}
+
+	if err := deleteTwoFactor(ctx, tx, userId); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("repository: delete two factor: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("repository: delete two factor: commit transaction %w", err)
+	}
+
+	return nil
+}
+
+func deleteTwoFactor(ctx context.Context, tx *sql.Tx, userId int) error {
+	for _, query := range []string{
+		`DELETE FROM recovery_codes WHERE userId = $1;`,
+		`DELETE FROM two_factor WHERE userId = $1;`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE userId = $1;`, userId); err != nil {
+		return fmt.Errorf("delete recovery codes %w", err)
+	}
+
+	query := `INSERT INTO recovery_codes (userId, codeHash) VALUES ($1, $2);`
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, query, userId, hash); err != nil {
+			return fmt.Errorf("insert recovery code %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/migrations"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTwoFactorRepository opens an in-memory database with the tables of the
+// two-factor migration and a confirmed secret for user 1, its first code was
+// of step 100
+func newTwoFactorRepository(t *testing.T, codeHashes ...string) *AuthRepository {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := migrations.FS.ReadFile("0017_two_factor.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewAuthRepostiry(db, &config.Config{CtxTimeout: 5})
+	if err := r.SaveTwoFactorSecret(1, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ConfirmTwoFactor(1, 100, time.Unix(0, 0), codeHashes); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestUseTwoFactorStep(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []int64
+		want  []bool
+	}{
+		{"step of the first code", []int64{100}, []bool{false}},
+		{"later step", []int64{101}, []bool{true}},
+		{"same step twice", []int64{101, 101}, []bool{true, false}},
+		{"earlier step after a later one", []int64{102, 101}, []bool{true, false}},
+		{"steps in order", []int64{101, 102, 103}, []bool{true, true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTwoFactorRepository(t)
+			for i, step := range tt.steps {
+				err := r.UseTwoFactorStep(1, step)
+				if tt.want[i] && err != nil {
+					t.Fatalf("step %d: %v", step, err)
+				}
+				if !tt.want[i] && !errors.Is(err, sql.ErrNoRows) {
+					t.Fatalf("step %d: error = %v, want %v", step, err, sql.ErrNoRows)
+				}
+			}
+		})
+	}
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name      string
+		codes     []string
+		want      []bool
+		wantCount int
+	}{
+		{"unused code", []string{"a"}, []bool{true}, 1},
+		{"same code twice", []string{"a", "a"}, []bool{true, false}, 1},
+		{"both codes", []string{"a", "b"}, []bool{true, true}, 0},
+		{"unknown code", []string{"c"}, []bool{false}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTwoFactorRepository(t, "a", "b")
+			for i, code := range tt.codes {
+				err := r.UseRecoveryCode(1, code, now)
+				if tt.want[i] && err != nil {
+					t.Fatalf("code %q: %v", code, err)
+				}
+				if !tt.want[i] && !errors.Is(err, sql.ErrNoRows) {
+					t.Fatalf("code %q: error = %v, want %v", code, err, sql.ErrNoRows)
+				}
+			}
+
+			count, err := r.CountRecoveryCodes(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.wantCount {
+				t.Errorf("unused codes = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
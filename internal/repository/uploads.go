@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/config"
+)
+
+// Uploads tells which stored files are still in use. Files are named by
+// their content, so one file can belong to several attachments and avatars.
+type Uploads interface {
+	UnusedUploads(names []string) ([]string, error)
+}
+
+type UploadsRepository struct {
+	db  *sql.DB
+	cnf *config.Config
+}
+
+func NewUploadsRepository(db *sql.DB, cnf *config.Config) *UploadsRepository {
+	return &UploadsRepository{
+		db:  db,
+		cnf: cnf,
+	}
+}
+
+// UnusedUploads returns the names no attachment and no avatar refers to,
+// each name once
+func (u *UploadsRepository) UnusedUploads(names []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.cnf.CtxTimeout)*time.Second)
+	defer cancel()
+
+	query := `SELECT EXISTS (SELECT 1 FROM attachments WHERE filename = $1 OR thumbnail = $1)
+		OR EXISTS (SELECT 1 FROM user WHERE avatar = $1);`
+
+	var unused []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var used bool
+		if err := u.db.QueryRowContext(ctx, query, name).Scan(&used); err != nil {
+			return nil, fmt.Errorf("repository: unused uploads: %w", err)
+		}
+		if !used {
+			unused = append(unused, name)
+		}
+	}
+
+	return unused, nil
+}
@@ -11,10 +11,10 @@ import (
 )
 
 type User interface {
-	GetPostsByName(username string) ([]entity.Post, error)
-	GetLikedPostsByName(username string) ([]entity.Post, error)
-	GetDislikedPostsByName(username string) ([]entity.Post, error)
-	GetCommentedPostsByName(username string) ([]entity.Post, error)
+	GetPostsByName(username string, page entity.PageRequest) ([]entity.Post, error)
+	GetLikedPostsByName(username string, page entity.PageRequest) ([]entity.Post, error)
+	GetDislikedPostsByName(username string, page entity.PageRequest) ([]entity.Post, error)
+	GetCommentedPostsByName(username string, page entity.PageRequest) ([]entity.Post, error)
 	GetAllCategoriesByPostId(postId int) ([]string, error)
 	GetUser(username string) (entity.UserModel, error)
 }
@@ -31,123 +31,32 @@ func NewUserRepository(db *sql.DB, cnf *config.Config) *UserRepository {
 	}
 }
 
-func (u *UserRepository) GetPostsByName(username string) ([]entity.Post, error) {
+func (u *UserRepository) GetPostsByName(username string, page entity.PageRequest) ([]entity.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	var posts []entity.Post
-	query := `SELECT postId , author, title, content, creationDate, likes, dislikes FROM posts WHERE author = $1;`
-	rows, err := u.db.QueryContext(ctx, query, username)
-	if err != nil {
-		return nil, fmt.Errorf("repository:user:getpostbyname: query %w", err)
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository:user:getpostbyname: scan %w", err)
-		}
-		posts = append(posts, post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository:user:getpostbyname: rows error %w", err)
-	}
-
-	return posts, nil
+	return queryPostsPage(ctx, u.db, "user:getpostbyname", "author = ?", []interface{}{username}, entity.OrderOldest, page)
 }
 
-func (u *UserRepository) GetLikedPostsByName(username string) ([]entity.Post, error) {
+func (u *UserRepository) GetLikedPostsByName(username string, page entity.PageRequest) ([]entity.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	var posts []entity.Post
-	query := `SELECT postId , author, title, content, creationDate, likes, dislikes FROM posts WHERE postId IN (SELECT postID FROM likes WHERE username = $1);`
-	rows, err := u.db.QueryContext(ctx, query, username)
-	if err != nil {
-		return nil, fmt.Errorf("repository:user:getlikedpostbyname: query %w", err)
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository:user:getlikedpostbyname: scan %w", err)
-		}
-
-		posts = append(posts, post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository:user:getlikedpostbyname: rows error %w", err)
-	}
-
-	return posts, nil
+	return queryPostsPage(ctx, u.db, "user:getlikedpostbyname", "postId IN (SELECT targetId FROM votes WHERE targetType = 'post' AND username = ? AND value = 1)", []interface{}{username}, entity.OrderOldest, page)
 }
 
-func (u *UserRepository) GetDislikedPostsByName(username string) ([]entity.Post, error) {
+func (u *UserRepository) GetDislikedPostsByName(username string, page entity.PageRequest) ([]entity.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	var posts []entity.Post
-	query := `SELECT postId , author, title, content, creationDate, likes, dislikes FROM posts WHERE postId IN (SELECT postID FROM dislikes WHERE username = $1);`
-	rows, err := u.db.QueryContext(ctx, query, username)
-	if err != nil {
-		return nil, fmt.Errorf("repository:user:getdislikedpostbyname: query %w", err)
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository:user:getdislikedpostbyname: scan %w", err)
-		}
-
-		posts = append(posts, post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository:user:getdislikedpostbyname: rows error %w", err)
-	}
-
-	return posts, nil
+	return queryPostsPage(ctx, u.db, "user:getdislikedpostbyname", "postId IN (SELECT targetId FROM votes WHERE targetType = 'post' AND username = ? AND value = -1)", []interface{}{username}, entity.OrderOldest, page)
 }
 
-func (u *UserRepository) GetCommentedPostsByName(username string) ([]entity.Post, error) {
+func (u *UserRepository) GetCommentedPostsByName(username string, page entity.PageRequest) ([]entity.Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(u.cnf.CtxTimeout)*time.Second)
 	defer cancel()
 
-	var posts []entity.Post
-	query := `SELECT postId , author, title, content, creationDate, likes, dislikes FROM posts WHERE postId IN (SELECT postID FROM comments WHERE author  = $1);`
-	rows, err := u.db.QueryContext(ctx, query, username)
-	if err != nil {
-		return nil, fmt.Errorf("repository:user:getdislikedpostbyname: query %w", err)
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var post entity.Post
-
-		if err := rows.Scan(&post.PostId, &post.PostAuthor, &post.Title, &post.Content, &post.CreationTime, &post.Likes, &post.Dislikes); err != nil {
-			return nil, fmt.Errorf("repository:user:getdislikedpostbyname: scan %w", err)
-		}
-
-		posts = append(posts, post)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("repository:user:getdislikedpostbyname: rows error %w", err)
-	}
-
-	return posts, nil
+	return queryPostsPage(ctx, u.db, "user:getcommentedpostbyname", "postId IN (SELECT postId FROM comments WHERE author = ?)", []interface{}{username}, entity.OrderOldest, page)
 }
 
 func (u *UserRepository) GetAllCategoriesByPostId(postId int) ([]string, error) {
@@ -185,11 +94,15 @@ func (u *UserRepository) GetUser(username string) (entity.UserModel, error) {
 	defer cancel()
 
 	var user entity.UserModel
-	query := `SELECT userId, username, email, posts FROM user WHERE username =$1;`
+	var suspended sql.NullTime
+	query := `SELECT userId, username, email, posts, IFNULL(role, 'user'), suspendedUntil,
+		IFNULL(displayName, ''), IFNULL(bio, ''), IFNULL(avatar, ''), IFNULL(website, '') FROM user WHERE username =$1;`
 
-	if err := u.db.QueryRowContext(ctx, query, username).Scan(&user.UserId, &user.Username, &user.Email, &user.Posts); err != nil {
+	if err := u.db.QueryRowContext(ctx, query, username).Scan(&user.UserId, &user.Username, &user.Email, &user.Posts, &user.Role, &suspended,
+		&user.DisplayName, &user.Bio, &user.Avatar, &user.Website); err != nil {
 		return entity.UserModel{}, fmt.Errorf("repository:user:getUser: scan %w", err)
 	}
+	user.SuspendedUntil = suspended.Time
 
 	return user, nil
 }
@@ -0,0 +1,298 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/pkg/imagestore"
+	"forum/pkg/mailer"
+)
+
+var (
+	ErrInvalidDisplayName = errors.New("display name must be at most 30 characters")
+	ErrInvalidBio         = errors.New("bio must be at most 500 characters")
+	ErrInvalidWebsite     = errors.New("website must be an http or https link of at most 200 characters")
+	ErrInvalidAvatar      = errors.New("avatar must be a JPEG, PNG or GIF image")
+	ErrAvatarTooLarge     = errors.New("avatar is too large")
+	ErrSameEmail          = errors.New("this is your email address already")
+	ErrInvalidDeleteMode  = errors.New("choose whether to anonymize or remove your posts and comments")
+	ErrConfirmDelete      = errors.New("type your username to confirm")
+)
+
+const (
+	maxDisplayName = 30
+	maxBio         = 500
+	maxWebsite     = 200
+)
+
+const changeEmailBody = `Hello %s,
+
+please confirm that %s is the new email address of your account by opening this link:
+
+%s
+
+The link expires in 24 hours. Until then your account keeps its current address. If you did not ask for this, ignore this message.
+`
+
+const emailChangedBody = `Hello %s,
+
+the email address of your account was changed to %s. If it was not you, reset your password and contact the administrators.
+`
+
+// UpdateProfile saves the display name, bio, website and avatar of user and
+// returns the user with them. display name and bio are cleaned like posts, see
+// cleanText, and limited in user-perceived characters.
+func (u *AuthUserUse) UpdateProfile(user entity.UserModel, settings entity.ProfileSettings) (entity.UserModel, error) {
+	displayName, ok := cleanText(settings.DisplayName, false)
+	displayName = strings.TrimSpace(displayName)
+	if !ok || tooLong(displayName, maxDisplayName) {
+		return entity.UserModel{}, fmt.Errorf("usecase: update profile: %w", ErrInvalidDisplayName)
+	}
+
+	bio, ok := cleanText(settings.Bio, true)
+	bio = strings.TrimSpace(bio)
+	if !ok || tooLong(bio, maxBio) {
+		return entity.UserModel{}, fmt.Errorf("usecase: update profile: %w", ErrInvalidBio)
+	}
+
+	website := strings.TrimSpace(settings.Website)
+	if website != "" && !validWebsite(website) {
+		return entity.UserModel{}, fmt.Errorf("usecase: update profile: %w", ErrInvalidWebsite)
+	}
+
+	avatar := user.Avatar
+	if settings.RemoveAvatar {
+		avatar = ""
+	}
+	if settings.Avatar != nil {
+		// the thumbnail is large enough for an avatar and never the full upload
+		img, err := u.images.Load(settings.Avatar.Content)
+		switch {
+		case errors.Is(err, imagestore.ErrTooLarge):
+			return entity.UserModel{}, fmt.Errorf("usecase: update profile: %w", ErrAvatarTooLarge)
+		case errors.Is(err, imagestore.ErrUnsupported):
+			return entity.UserModel{}, fmt.Errorf("usecase: update profile: %w", ErrInvalidAvatar)
+		case err != nil:
+			return entity.UserModel{}, err
+		}
+		if err := u.images.WriteThumbnail(img); err != nil {
+			return entity.UserModel{}, err
+		}
+		avatar = img.Thumbnail
+	}
+
+	old := user.Avatar
+	user.DisplayName = displayName
+	user.Bio = bio
+	user.Website = website
+	user.Avatar = avatar
+	if err := u.Repository.UpdateUser(user); err != nil {
+		if avatar != old {
+			removeUnusedUploads(u.uploads, u.images, []string{avatar})
+		}
+		return entity.UserModel{}, err
+	}
+
+	// a replaced avatar goes unless another user or a post has the same image
+	if avatar != old {
+		removeUnusedUploads(u.uploads, u.images, []string{old})
+	}
+	return user, nil
+}
+
+// RequestEmailChange sends a confirmation link to the new address, the
+// account keeps the current one until the link is opened. The password is
+// needed when the account has one, wrong ones count like failed sign-ins.
+func (u *AuthUserUse) RequestEmailChange(user entity.UserModel, email, password string, client entity.Client) error {
+	email = strings.TrimSpace(email)
+	if !validEmail(email) {
+		return fmt.Errorf("usecase: request email change: %w", ErrInvalidEmail)
+	}
+
+	current, err := u.checkCurrentPassword(user, password, client)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(email, current.Email) {
+		return fmt.Errorf("usecase: request email change: %w", ErrSameEmail)
+	}
+	if _, err := u.Repository.GetUserByEmail(email); err == nil {
+		return fmt.Errorf("usecase: request email change: %w", ErrEmailExist)
+	}
+
+	// the token carries the new address, the link goes to it
+	current.Email = email
+	link, err := u.issueToken(current, entity.TokenChangeEmail, "/auth/email/confirm", verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body:    fmt.Sprintf(changeEmailBody, current.Username, email, link),
+	})
+}
+
+// ConfirmEmailChange switches the account to the address the token was sent
+// to and tells the old address about it
+func (u *AuthUserUse) ConfirmEmailChange(token string) error {
+	t, err := u.consumeToken(token, entity.TokenChangeEmail)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.Repository.GetUserById(t.UserId)
+	if err != nil {
+		return fmt.Errorf("usecase: confirm email change: %w", ErrInvalidToken)
+	}
+
+	if err := u.Repository.ChangeEmail(user.UserId, t.Email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("usecase: confirm email change: %w", ErrEmailExist)
+		}
+		return err
+	}
+
+	if user.Email != "" {
+		err := u.mailer.Send(mailer.Message{
+			To:      user.Email,
+			Subject: "Your email address was changed",
+			Body:    fmt.Sprintf(emailChangedBody, user.Username, t.Email),
+		})
+		if err != nil {
+			log.Printf("usecase: confirm email change: notify old address: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ChangePassword sets a new password after checking the current one, an
+// account without a password, made by a sign-in provider, sets its first.
+// All sessions end and the new session of the user is returned.
+func (u *AuthUserUse) ChangePassword(user entity.UserModel, current, password, confirmPassword string, client entity.Client) (entity.UserModel, error) {
+	stored, err := u.checkCurrentPassword(user, current, client)
+	if err != nil {
+		return entity.UserModel{}, err
+	}
+
+	if password != confirmPassword {
+		return entity.UserModel{}, fmt.Errorf("usecase: change password: %w", ErrConfirmPassword)
+	}
+	if err := u.checkPassword(password); err != nil {
+		return entity.UserModel{}, fmt.Errorf("usecase: change password: %w", err)
+	}
+
+	hash, err := u.generateHashPassword(password)
+	if err != nil {
+		return entity.UserModel{}, fmt.Errorf("usecase: change password: %w", ErrHashPassword)
+	}
+
+	if err := u.Repository.UpdatePassword(stored.UserId, hash); err != nil {
+		return entity.UserModel{}, err
+	}
+
+	return u.startSession(stored, client)
+}
+
+// ExportAccount collects everything the forum keeps about user: the profile,
+// posts, comments, votes, reports, sessions and linked providers
+func (u *AuthUserUse) ExportAccount(user entity.UserModel) (entity.AccountData, error) {
+	stored, err := u.Repository.GetUserById(user.UserId)
+	if err != nil {
+		return entity.AccountData{}, err
+	}
+	// the hash is not personal data and must not leave the server
+	stored.Password = ""
+
+	data, err := u.Repository.GetAccountContent(stored.Username)
+	if err != nil {
+		return entity.AccountData{}, err
+	}
+	data.User = stored
+
+	if data.Sessions, err = u.Repository.GetSessions(stored.UserId, u.now()); err != nil {
+		return entity.AccountData{}, err
+	}
+	if data.Identities, err = u.Repository.GetIdentities(stored.UserId); err != nil {
+		return entity.AccountData{}, err
+	}
+
+	tf, err := u.Repository.GetTwoFactor(stored.UserId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return entity.AccountData{}, err
+	}
+	data.TwoFactor = tf.Enabled()
+
+	return data, nil
+}
+
+// DeleteAccount deletes the account of user after they typed their username
+// and, when the account has one, the password. mode is entity.DeleteAnonymize
+// or entity.DeleteRemove and decides what happens to the posts and comments.
+// the avatar and attachments go with it unless other posts use them too.
+func (u *AuthUserUse) DeleteAccount(user entity.UserModel, mode, confirm, password string, client entity.Client) error {
+	if mode != entity.DeleteAnonymize && mode != entity.DeleteRemove {
+		return fmt.Errorf("usecase: delete account: %w", ErrInvalidDeleteMode)
+	}
+	if strings.TrimSpace(confirm) != user.Username {
+		return fmt.Errorf("usecase: delete account: %w", ErrConfirmDelete)
+	}
+
+	stored, err := u.checkCurrentPassword(user, password, client)
+	if err != nil {
+		return err
+	}
+
+	files, err := u.Repository.DeleteUser(stored.UserId, mode)
+	if err != nil {
+		return err
+	}
+
+	removeUnusedUploads(u.uploads, u.images, files)
+	return nil
+}
+
+// checkCurrentPassword returns the stored user when password is theirs or
+// the account has no password, attempts are limited like sign-ins
+func (u *AuthUserUse) checkCurrentPassword(user entity.UserModel, password string, client entity.Client) (entity.UserModel, error) {
+	stored, err := u.Repository.GetUserById(user.UserId)
+	if err != nil {
+		return entity.UserModel{}, err
+	}
+	if stored.Password == "" {
+		return stored, nil
+	}
+
+	keys := limitKeys("account", stored.Username, client)
+	if err := u.allow(keys); err != nil {
+		return entity.UserModel{}, err
+	}
+
+	if err := checkPasswordHash(password, stored.Password); err != nil {
+		u.failed(keys)
+		return entity.UserModel{}, fmt.Errorf("usecase: check current password: %w", ErrInvalidPassword)
+	}
+
+	return stored, nil
+}
+
+// validWebsite accepts absolute http and https links
+func validWebsite(website string) bool {
+	if len(website) > maxWebsite {
+		return false
+	}
+
+	link, err := url.Parse(website)
+	if err != nil {
+		return false
+	}
+	return (link.Scheme == "http" || link.Scheme == "https") && link.Host != ""
+}
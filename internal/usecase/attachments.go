@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"forum/internal/entity"
+	"forum/internal/repository"
+	"forum/pkg/imagestore"
+)
+
+var (
+	ErrTooManyAttachments    = errors.New("too many attachments")
+	ErrAttachmentTooLarge    = errors.New("attachment is too large")
+	ErrUnsupportedAttachment = errors.New("attachments must be JPEG, PNG or GIF images")
+)
+
+const maxOriginalName = 255
+
+// loadAttachments checks the uploaded images and describes them as
+// attachments, nothing is written until writeAttachments
+func (pu *PostUseCase) loadAttachments(uploads []entity.Upload) ([]imagestore.Image, []entity.Attachment, error) {
+	if len(uploads) > pu.maxAttachments {
+		return nil, nil, fmt.Errorf("usecase: attachments: %d of at most %d: %w", len(uploads), pu.maxAttachments, ErrTooManyAttachments)
+	}
+
+	images := make([]imagestore.Image, 0, len(uploads))
+	attachments := make([]entity.Attachment, 0, len(uploads))
+	for _, upload := range uploads {
+		img, err := pu.images.Load(upload.Content)
+		switch {
+		case errors.Is(err, imagestore.ErrTooLarge):
+			return nil, nil, fmt.Errorf("usecase: attachment %q: %w", upload.Filename, ErrAttachmentTooLarge)
+		case errors.Is(err, imagestore.ErrUnsupported):
+			return nil, nil, fmt.Errorf("usecase: attachment %q: %w", upload.Filename, ErrUnsupportedAttachment)
+		case err != nil:
+			return nil, nil, err
+		}
+
+		// the original name is only shown to readers, never used as a path
+		name := filepath.Base(upload.Filename)
+		if len(name) > maxOriginalName {
+			name = name[:maxOriginalName]
+		}
+
+		images = append(images, img)
+		attachments = append(attachments, entity.Attachment{
+			Filename:     img.Filename,
+			Thumbnail:    img.Thumbnail,
+			OriginalName: name,
+			ContentType:  img.ContentType,
+			Size:         img.Size,
+			Width:        img.Width,
+			Height:       img.Height,
+		})
+	}
+
+	return images, attachments, nil
+}
+
+// writeAttachments stores loaded images, on an error the ones written so far
+// are removed again
+func (pu *PostUseCase) writeAttachments(images []imagestore.Image, attachments []entity.Attachment) error {
+	for i, img := range images {
+		if err := pu.images.Write(img); err != nil {
+			removeUnusedUploads(pu.uploads, pu.images, attachmentFiles(attachments[:i+1]))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachmentFiles lists the stored files of attachments
+func attachmentFiles(attachments []entity.Attachment) []string {
+	files := make([]string, 0, 2*len(attachments))
+	for _, a := range attachments {
+		files = append(files, a.Filename, a.Thumbnail)
+	}
+	return files
+}
+
+// removeUnusedUploads deletes the files of names that no attachment or avatar
+// uses any more. The rows are gone already, so failures are only logged.
+func removeUnusedUploads(uploads repository.Uploads, images *imagestore.Store, names []string) {
+	if len(names) == 0 {
+		return
+	}
+
+	unused, err := uploads.UnusedUploads(names)
+	if err != nil {
+		log.Printf("usecase: remove uploads: %v", err)
+		return
+	}
+	if err := images.Remove(unused...); err != nil {
+		log.Printf("usecase: remove uploads: %v", err)
+	}
+}
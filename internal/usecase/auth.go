@@ -1,19 +1,23 @@
 package usecase
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"net/mail"
 	"regexp"
+	"sync"
 	"time"
-	"unicode"
 
+	"forum/config"
 	"forum/internal/entity"
 	"forum/internal/repository"
-
-	"github.com/gofrs/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"forum/pkg/imagestore"
+	"forum/pkg/mailer"
+	"forum/pkg/oauth"
+	pwd "forum/pkg/password"
+	"forum/pkg/ratelimit"
 )
 
 var (
@@ -29,29 +33,93 @@ var (
 )
 
 type AuthorizationUsecase interface {
-	CreateUserandValidate(user entity.UserModel) error
-	CreateToken(username, password string) (entity.UserModel, error)
-	ParseToken(token string) (entity.UserModel, error)
+	CreateUserandValidate(user entity.UserModel, client entity.Client) error
+	CreateToken(username, password string, client entity.Client) (entity.UserModel, error)
+	ParseToken(token string) (user entity.UserModel, renewed bool, err error)
 	DeleteToken(token string) error
+	GetSessions(user entity.UserModel) ([]entity.Session, error)
+	RevokeSession(user entity.UserModel, sessionId int) error
+	RevokeAllSessions(user entity.UserModel) error
+	SendVerification(user entity.UserModel) error
+	VerifyEmail(token string) error
+	RequestPasswordReset(email string, client entity.Client) error
+	ResetPassword(token, password, confirmPassword string) error
+	OAuthProviders() []string
+	OAuthLoginURL(provider, state, verifier string) (string, error)
+	OAuthLogin(provider, code, verifier string, current entity.UserModel, client entity.Client) (entity.UserModel, error)
+	GetIdentities(userId int) ([]entity.Identity, error)
+	TwoFactorStatus(user entity.UserModel) (entity.TwoFactorStatus, error)
+	BeginTwoFactor(user entity.UserModel) (entity.TwoFactorStatus, error)
+	EnableTwoFactor(user entity.UserModel, code string) ([]string, error)
+	DisableTwoFactor(user entity.UserModel, code string, client entity.Client) error
+	RegenerateRecoveryCodes(user entity.UserModel, code string, client entity.Client) ([]string, error)
+	CompleteTwoFactor(challenge, code string, client entity.Client) (entity.UserModel, error)
+	UpdateProfile(user entity.UserModel, settings entity.ProfileSettings) (entity.UserModel, error)
+	RequestEmailChange(user entity.UserModel, email, password string, client entity.Client) error
+	ConfirmEmailChange(token string) error
+	ChangePassword(user entity.UserModel, current, password, confirmPassword string, client entity.Client) (entity.UserModel, error)
+	ExportAccount(user entity.UserModel) (entity.AccountData, error)
+	DeleteAccount(user entity.UserModel, mode, confirm, password string, client entity.Client) error
 }
 
 type AuthUserUse struct {
-	Repository repository.Authorization
+	Repository    repository.Authorization
+	mailer        mailer.Mailer
+	limiter       *ratelimit.Limiter
+	baseURL       string
+	providers     map[string]*oauth.Provider
+	providerNames []string
+	sessionTTL    time.Duration
+	sessionMax    time.Duration
+	totpIssuer    string
+	policy        *pwd.Policy
+	hasher        pwd.Hasher
+	uploads       repository.Uploads
+	images        *imagestore.Store
+	// dummyHash is checked for unknown usernames, see checkDummyPassword
+	dummyOnce sync.Once
+	dummyHash string
+	// now is the clock of two-factor codes and challenges
+	now func() time.Time
 }
 
-func NewAuthUseCase(ar repository.Authorization) *AuthUserUse {
+func NewAuthUseCase(ar repository.Authorization, up repository.Uploads, m mailer.Mailer, l *ratelimit.Limiter, p *pwd.Policy, h pwd.Hasher, cnf *config.Config) *AuthUserUse {
+	providers, names := newProviders(cnf)
+
 	return &AuthUserUse{
-		Repository: ar,
+		Repository:    ar,
+		mailer:        m,
+		limiter:       l,
+		baseURL:       cnf.BaseURL,
+		providers:     providers,
+		providerNames: names,
+		sessionTTL:    time.Duration(cnf.SessionTTLHours) * time.Hour,
+		sessionMax:    time.Duration(cnf.SessionMaxHours) * time.Hour,
+		totpIssuer:    cnf.TOTPIssuer,
+		policy:        p,
+		hasher:        h,
+		uploads:       up,
+		images:        imagestore.New(cnf.UploadDir, cnf.MaxUploadSize),
+		now:           time.Now,
 	}
 }
 
 // CreateUserandValidate creates user if not exist in db, checks user's info
-// hashes password
-func (u *AuthUserUse) CreateUserandValidate(user entity.UserModel) error {
+// hashes password and sends the email verification link, attempts are
+// limited per address and username of the client
+func (u *AuthUserUse) CreateUserandValidate(user entity.UserModel, client entity.Client) error {
+	if err := u.allow(limitKeys("sign-up", user.Username, client)); err != nil {
+		return err
+	}
+
 	if err := checkUser(user); err != nil {
 		return err
 	}
 
+	if err := u.checkPassword(user.Password); err != nil {
+		return fmt.Errorf("usecase: create and validate: %w", err)
+	}
+
 	if _, err := u.Repository.GetUserByUsername(user.Username); err == nil {
 		return fmt.Errorf("usecase: create and validate: %w", ErrUserExist)
 	}
@@ -63,59 +131,64 @@ func (u *AuthUserUse) CreateUserandValidate(user entity.UserModel) error {
 	var err error
 	user.CreatedAt = time.Now()
 
-	user.Password, err = generateHashPassword(user.Password)
+	user.Password, err = u.generateHashPassword(user.Password)
 	if err != nil {
 		return fmt.Errorf("usecase: cannot generate hash: %w", ErrHashPassword)
 	}
 
-	return u.Repository.CreateUser(user)
+	if err := u.Repository.CreateUser(user); err != nil {
+		return err
+	}
+
+	// the account exists even when the mail cannot be sent, the link can be requested again
+	created, err := u.Repository.GetUserByUsername(user.Username)
+	if err == nil {
+		err = u.SendVerification(created)
+	}
+	if err != nil {
+		log.Printf("usecase: create and validate: send verification: %v", err)
+	}
+
+	return nil
 }
 
-func (u *AuthUserUse) CreateToken(username, password string) (entity.UserModel, error) {
+// CreateToken signs the user in. Attempts are limited per address and
+// username of the client and failures lock both out for a while, whether the
+// username exists or not. With two-factor authentication on it fails with a
+// *TwoFactorError and the sign-in goes on in CompleteTwoFactor.
+func (u *AuthUserUse) CreateToken(username, password string, client entity.Client) (entity.UserModel, error) {
+	keys := limitKeys("sign-in", username, client)
+	if err := u.allow(keys); err != nil {
+		return entity.UserModel{}, err
+	}
+
 	user, err := u.Repository.GetUserByUsername(username)
 	if err != nil {
+		u.checkDummyPassword(password)
+		u.failed(keys)
 		return entity.UserModel{}, fmt.Errorf("usecase: create token: %w", ErrUserNotFound)
 	}
 
 	if err := checkPasswordHash(password, user.Password); err != nil {
+		u.failed(keys)
 		return entity.UserModel{}, fmt.Errorf("usecase: check password hash: %w", ErrInvalidPassword)
 	}
+	u.rehashPassword(user, password)
 
-	token, err := uuid.NewV4()
-	if err != nil {
-		return entity.UserModel{}, fmt.Errorf("error create token uuid: %w", err)
+	if err := u.limiter.Reset(keys[1]); err != nil {
+		log.Printf("usecase: rate limit: reset %s: %v", keys[1], err)
 	}
-	user.Token = token.String()
-	user.ExpirationTime = time.Now().Add(12 * time.Hour)
 
-	if err := u.Repository.SaveToken(user); err != nil {
-		fmt.Printf("error create token - SaveToken - %v", err)
-		return entity.UserModel{}, fmt.Errorf("usercase: create token: %w", err)
-	}
-
-	return user, nil
-}
-
-func (u *AuthUserUse) ParseToken(token string) (entity.UserModel, error) {
-	user, err := u.Repository.GetUserByToken(token)
-	if err != nil {
-		return entity.UserModel{}, fmt.Errorf("usecase: parse token: %w", err)
+	if err := u.twoFactorChallenge(user); err != nil {
+		return entity.UserModel{}, err
 	}
 
-	return user, nil
-}
-
-func (u *AuthUserUse) DeleteToken(token string) error {
-	return u.Repository.DeleteToken(token)
+	return u.startSession(user, client)
 }
 
 // chicking user's given information
 func checkUser(user entity.UserModel) error {
-	if _, err := mail.ParseAddress(user.Emailcheck); err != nil {
-		return fmt.Errorf("usecase: check user :%w", ErrInvalidEmail)
-	}
-
-	if m, _ := regexp.MatchString(`^([\w\.\_]{2,10})@(\w{1,}).([a-z]{2,4})$`, user.Emailcheck); !m {
+	if !validEmail(user.Emailcheck) {
 		return fmt.Errorf("usecase: check user :%w", ErrInvalidEmail)
 	}
 
@@ -139,46 +212,69 @@ func checkUser(user entity.UserModel) error {
 		}
 	}
 
-	if vP := checkPassword(user.Password); !vP {
-		return fmt.Errorf("usecase: checkUser err: %w", ErrInvalidPassword)
+	return nil
+}
+
+// validEmail tells whether email is an address the forum accepts
+func validEmail(email string) bool {
+	if _, err := mail.ParseAddress(email); err != nil {
+		return false
 	}
 
+	m, _ := regexp.MatchString(`^([\w\.\_]{2,10})@(\w{1,}).([a-z]{2,4})$`, email)
+	return m
+}
+
+// PasswordError is returned for a new password the policy refuses, Reason
+// tells which rule it breaks
+type PasswordError struct {
+	Reason string
+}
+
+func (e *PasswordError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidPassword, e.Reason)
+}
+
+func (e *PasswordError) Unwrap() error {
+	return ErrInvalidPassword
+}
+
+// checkPassword checks a new password against the policy
+func (u *AuthUserUse) checkPassword(password string) error {
+	var weak *pwd.WeakError
+	if err := u.policy.Check(password); err != nil {
+		if errors.As(err, &weak) {
+			return &PasswordError{Reason: weak.Reason}
+		}
+		return err
+	}
 	return nil
 }
 
-// create new hashed password upon signup
-func generateHashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
+// generateHashPassword hashes a new password with the configured hasher
+func (u *AuthUserUse) generateHashPassword(password string) (string, error) {
+	return u.hasher.Hash(password)
 }
 
-// checks login in the process of login
+// checkPasswordHash checks a password against the stored hash, whatever
+// hasher made it
 func checkPasswordHash(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return pwd.Compare(password, hash)
 }
 
-func checkPassword(s string) bool {
-	count := 0
-	var passwordlen, number, upperCase, specialSym bool
-	for _, c := range s {
-		switch {
-		case unicode.IsNumber(c):
-			number = true
-			count++
-		case unicode.IsUpper(c):
-			upperCase = true
-			count++
-		case unicode.IsPunct(c) || unicode.IsSymbol(c):
-			specialSym = true
-		case unicode.IsLetter(c):
-			count++
-		default:
-			return false
-		}
+// rehashPassword replaces a hash made by another hasher or with other
+// settings than the configured ones once the password is known to be right,
+// the sign-in goes on when it fails
+func (u *AuthUserUse) rehashPassword(user entity.UserModel, password string) {
+	if u.hasher.Current(user.Password) {
+		return
+	}
+
+	hash, err := u.generateHashPassword(password)
+	if err == nil {
+		err = u.Repository.RehashPassword(user.UserId, user.Password, hash)
 	}
-	passwordlen = (count >= 6 && count <= 20)
-	if passwordlen && number && upperCase && specialSym {
-		return true
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("usecase: rehash password of %s: %v", user.Username, err)
 	}
-	return false
 }
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/repository"
+)
+
+var (
+	ErrInvalidCategory  = errors.New("invalid category")
+	ErrUnknownCategory  = errors.New("unknown category")
+	ErrCategoryExists   = errors.New("category already exists")
+	ErrCategoryNotEmpty = errors.New("category still has posts")
+)
+
+const (
+	maxCategoryName        = 50
+	maxCategoryDescription = 300
+)
+
+var categorySlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type CategoriesUsecase interface {
+	GetCategories() ([]entity.Category, error)
+	GetCategoryBySlug(slug string) (entity.Category, error)
+	CreateCategory(admin entity.UserModel, category entity.Category) error
+	UpdateCategory(admin entity.UserModel, category entity.Category) error
+	DeleteCategory(admin entity.UserModel, id int) error
+}
+
+type CategoryUsecase struct {
+	categories repository.Categories
+}
+
+func NewCategoryUsecase(r *repository.Repository) *CategoryUsecase {
+	return &CategoryUsecase{
+		categories: r.Categories,
+	}
+}
+
+func (c *CategoryUsecase) GetCategories() ([]entity.Category, error) {
+	return c.categories.GetCategories()
+}
+
+func (c *CategoryUsecase) GetCategoryBySlug(slug string) (entity.Category, error) {
+	return c.categories.GetCategoryBySlug(slug)
+}
+
+func (c *CategoryUsecase) CreateCategory(admin entity.UserModel, category entity.Category) error {
+	if !admin.Can(entity.PermManageCategories) {
+		return fmt.Errorf("usecase: create category: %w", ErrForbidden)
+	}
+
+	category, err := c.validate(category)
+	if err != nil {
+		return err
+	}
+
+	// the repository fills in the id of the new category
+	_, err = c.categories.CreateCategory(category, auditEntry(admin, entity.AuditCreateCategory, "category", "", category.Name))
+	return err
+}
+
+// UpdateCategory changes a category, renaming it keeps its posts
+func (c *CategoryUsecase) UpdateCategory(admin entity.UserModel, category entity.Category) error {
+	if !admin.Can(entity.PermManageCategories) {
+		return fmt.Errorf("usecase: update category: %w", ErrForbidden)
+	}
+
+	old, err := c.categories.GetCategoryById(category.CategoryId)
+	if err != nil {
+		return err
+	}
+
+	category, err = c.validate(category)
+	if err != nil {
+		return err
+	}
+
+	details := category.Name
+	if old.Name != category.Name {
+		details = fmt.Sprintf("%s -> %s", old.Name, category.Name)
+	}
+	return c.categories.UpdateCategory(category,
+		auditEntry(admin, entity.AuditUpdateCategory, "category", strconv.Itoa(category.CategoryId), details))
+}
+
+// DeleteCategory removes a category no post is tagged with
+func (c *CategoryUsecase) DeleteCategory(admin entity.UserModel, id int) error {
+	if !admin.Can(entity.PermManageCategories) {
+		return fmt.Errorf("usecase: delete category: %w", ErrForbidden)
+	}
+
+	category, err := c.categories.GetCategoryById(id)
+	if err != nil {
+		return err
+	}
+
+	if category.PostCount > 0 {
+		return fmt.Errorf("usecase: delete category: %s: %w", category.Name, ErrCategoryNotEmpty)
+	}
+
+	return c.categories.DeleteCategory(id, auditEntry(admin, entity.AuditDeleteCategory, "category", strconv.Itoa(id), category.Name))
+}
+
+// validate trims the category fields and checks that slug and name are
+// well formed and not taken by another category
+func (c *CategoryUsecase) validate(category entity.Category) (entity.Category, error) {
+	category.Slug = strings.TrimSpace(category.Slug)
+	category.Name = strings.TrimSpace(category.Name)
+	category.Description = strings.TrimSpace(category.Description)
+
+	if !categorySlug.MatchString(category.Slug) {
+		return entity.Category{}, fmt.Errorf("usecase: category: slug must be lowercase letters, digits and dashes: %w", ErrInvalidCategory)
+	}
+	if category.Name == "" || len(category.Name) > maxCategoryName || strings.Contains(category.Name, ",") {
+		return entity.Category{}, fmt.Errorf("usecase: category: name: %w", ErrInvalidCategory)
+	}
+	if len(category.Description) > maxCategoryDescription {
+		return entity.Category{}, fmt.Errorf("usecase: category: description: %w", ErrInvalidCategory)
+	}
+
+	categories, err := c.categories.GetCategories()
+	if err != nil {
+		return entity.Category{}, err
+	}
+	for _, other := range categories {
+		if other.CategoryId == category.CategoryId {
+			continue
+		}
+		if other.Slug == category.Slug || strings.EqualFold(other.Name, category.Name) {
+			return entity.Category{}, fmt.Errorf("usecase: category: %s: %w", category.Name, ErrCategoryExists)
+		}
+	}
+
+	return category, nil
+}
+
+// knownCategories checks that every name is an existing category and drops duplicates
+func knownCategories(categories repository.Categories, names []string) ([]string, error) {
+	known, err := categories.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(known))
+	for _, category := range known {
+		exists[category.Name] = true
+	}
+
+	seen := make(map[string]bool, len(names))
+	var result []string
+	for _, name := range names {
+		if !exists[name] {
+			return nil, fmt.Errorf("usecase: category %q: %w", name, ErrUnknownCategory)
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"strings"
+
+	"forum/internal/entity"
+)
+
+// diffLines returns a line based diff turning old into new
+// using the longest common subsequence of lines
+func diffLines(old, new string) []entity.DiffLine {
+	a := strings.Split(strings.ReplaceAll(old, "\r\n", "\n"), "\n")
+	b := strings.Split(strings.ReplaceAll(new, "\r\n", "\n"), "\n")
+
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []entity.DiffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, entity.DiffLine{Op: " ", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, entity.DiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			diff = append(diff, entity.DiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, entity.DiffLine{Op: "-", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, entity.DiffLine{Op: "+", Text: b[j]})
+	}
+
+	return diff
+}
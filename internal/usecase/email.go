@@ -0,0 +1,203 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"forum/internal/entity"
+	"forum/pkg/mailer"
+)
+
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid or expired link")
+	ErrEmailVerified = errors.New("email is already verified")
+)
+
+const verifyEmailBody = `Hello %s,
+
+please confirm your email address by opening this link:
+
+%s
+
+The link expires in 24 hours. If you did not sign up, ignore this message.
+`
+
+const resetPasswordBody = `Hello %s,
+
+someone asked to reset the password of your account. To choose a new password open this link:
+
+%s
+
+The link expires in 1 hour and works once. If it was not you, ignore this message, your password stays the same.
+`
+
+// SendVerification emails the user a link that confirms their address
+func (u *AuthUserUse) SendVerification(user entity.UserModel) error {
+	if user.EmailVerified {
+		return fmt.Errorf("usecase: send verification: %w", ErrEmailVerified)
+	}
+
+	link, err := u.issueToken(user, entity.TokenVerifyEmail, "/auth/verify", verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body:    fmt.Sprintf(verifyEmailBody, user.Username, link),
+	})
+}
+
+// VerifyEmail confirms the address the verification token was sent to
+func (u *AuthUserUse) VerifyEmail(token string) error {
+	t, err := u.consumeToken(token, entity.TokenVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	if err := u.Repository.SetEmailVerified(t.UserId, t.Email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the email was changed after the link was sent
+			return fmt.Errorf("usecase: verify email: %w", ErrInvalidToken)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RequestPasswordReset emails a reset link to the account using email.
+// Requests are limited per client address and per email like sign-ins. The
+// account is looked up and the mail sent in the background, so known and
+// unknown addresses get the same answer just as fast and the form does not
+// reveal who has an account.
+func (u *AuthUserUse) RequestPasswordReset(email string, client entity.Client) error {
+	email = strings.TrimSpace(email)
+	if err := u.allow(limitKeys("reset", email, client)); err != nil {
+		return err
+	}
+
+	go u.sendPasswordReset(email)
+	return nil
+}
+
+// sendPasswordReset mails the reset link for RequestPasswordReset, errors
+// only reach the log
+func (u *AuthUserUse) sendPasswordReset(email string) {
+	user, err := u.Repository.GetUserByEmail(email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("usecase: request password reset: %v", err)
+		}
+		return
+	}
+
+	link, err := u.issueToken(user, entity.TokenResetPassword, "/auth/reset", resetPasswordTTL)
+	if err != nil {
+		log.Printf("usecase: request password reset: %v", err)
+		return
+	}
+
+	err = u.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf(resetPasswordBody, user.Username, link),
+	})
+	if err != nil {
+		log.Printf("usecase: request password reset: send to user %d: %v", user.UserId, err)
+	}
+}
+
+// ResetPassword sets a new password for the owner of a reset token and ends
+// their session. The token is only used up once the new password is valid.
+func (u *AuthUserUse) ResetPassword(token, password, confirmPassword string) error {
+	if password != confirmPassword {
+		return fmt.Errorf("usecase: reset password: %w", ErrConfirmPassword)
+	}
+	if err := u.checkPassword(password); err != nil {
+		return fmt.Errorf("usecase: reset password: %w", err)
+	}
+
+	hash, err := u.generateHashPassword(password)
+	if err != nil {
+		return fmt.Errorf("usecase: reset password: %w", ErrHashPassword)
+	}
+
+	t, err := u.consumeToken(token, entity.TokenResetPassword)
+	if err != nil {
+		return err
+	}
+
+	return u.Repository.UpdatePassword(t.UserId, hash)
+}
+
+// issueToken stores a new token for user and returns the link to path that carries it
+func (u *AuthUserUse) issueToken(user entity.UserModel, purpose, path string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", fmt.Errorf("usecase: issue token: %w", err)
+	}
+
+	now := u.now()
+	err = u.Repository.SaveUserToken(entity.UserToken{
+		TokenHash: hashToken(token),
+		UserId:    user.UserId,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return u.baseURL + path + "?token=" + url.QueryEscape(token), nil
+}
+
+// consumeToken uses up a token, it fails with ErrInvalidToken when the token
+// is unknown, was used already or expired
+func (u *AuthUserUse) consumeToken(token, purpose string) (entity.UserToken, error) {
+	if token == "" {
+		return entity.UserToken{}, fmt.Errorf("usecase: consume token: %w", ErrInvalidToken)
+	}
+
+	t, err := u.Repository.ConsumeUserToken(hashToken(token), purpose, u.now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.UserToken{}, fmt.Errorf("usecase: consume token: %w", ErrInvalidToken)
+		}
+		return entity.UserToken{}, err
+	}
+
+	return t, nil
+}
+
+// randomToken returns 32 random bytes as URL safe text
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is what the database keeps instead of the token itself
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/entity"
+)
+
+// filterDateLayout is the format of the from and to query parameters
+const filterDateLayout = "2006-01-02"
+
+// ParsePostFilter builds a feed filter from the query string:
+//
+//	category=Sport&category=Travel  posts in any of the categories
+//	author=name                     posts of one author
+//	from=2023-01-01&to=2023-01-31   posts created in the date range, both days included
+//	min_score=5                     posts with at least that many likes more than dislikes
+//	sort=new|old|like|dislike|score feed order, oldest first by default
+//
+// time=new|old and vote=like|dislike are older spellings of sort, clean=true
+// is the unfiltered feed. Empty parameters are ignored, limit and cursor are
+// left to pagination.
+func ParsePostFilter(query map[string][]string) (entity.PostFilter, error) {
+	filter := entity.PostFilter{Order: entity.OrderOldest}
+	var sorts []string
+
+	for key, values := range query {
+		// empty fields of the filter form filter nothing
+		if isPageParam(key) || strings.TrimSpace(strings.Join(values, "")) == "" {
+			continue
+		}
+
+		switch key {
+		case "category":
+			for _, category := range values {
+				category = strings.TrimSpace(category)
+				if category == "" {
+					continue
+				}
+				filter.Categories = append(filter.Categories, category)
+			}
+		case "author":
+			author, err := singleValue(key, values)
+			if err != nil {
+				return entity.PostFilter{}, err
+			}
+			filter.Author = author
+		case "from", "to":
+			value, err := singleValue(key, values)
+			if err != nil {
+				return entity.PostFilter{}, err
+			}
+			day, err := time.Parse(filterDateLayout, value)
+			if err != nil {
+				return entity.PostFilter{}, fmt.Errorf("usecase: filter: %s is not a date: %w", key, ErrInvalidQuery)
+			}
+			if key == "from" {
+				filter.From = day
+			} else {
+				// to includes the whole day
+				filter.To = day.AddDate(0, 0, 1)
+			}
+		case "min_score":
+			value, err := singleValue(key, values)
+			if err != nil {
+				return entity.PostFilter{}, err
+			}
+			filter.MinScore, err = strconv.Atoi(value)
+			if err != nil {
+				return entity.PostFilter{}, fmt.Errorf("usecase: filter: min_score is not a number: %w", ErrInvalidQuery)
+			}
+			filter.HasMinScore = true
+		case "sort", "time", "vote":
+			value, err := singleValue(key, values)
+			if err != nil {
+				return entity.PostFilter{}, err
+			}
+			if (key == "time" && value != "new" && value != "old") || (key == "vote" && value != "like" && value != "dislike") {
+				return entity.PostFilter{}, fmt.Errorf("usecase: filter: %s=%s: %w", key, value, ErrInvalidQuery)
+			}
+			sorts = append(sorts, value)
+		case "clean":
+			if value, err := singleValue(key, values); err != nil || value != "true" {
+				return entity.PostFilter{}, fmt.Errorf("usecase: filter: clean must be true: %w", ErrInvalidQuery)
+			}
+		default:
+			return entity.PostFilter{}, fmt.Errorf("usecase: filter: unknown parameter %s: %w", key, ErrInvalidQuery)
+		}
+	}
+
+	if len(sorts) > 1 {
+		return entity.PostFilter{}, fmt.Errorf("usecase: filter: more than one sort order: %w", ErrInvalidQuery)
+	}
+	if len(sorts) == 1 {
+		filter.Order = entity.PostOrder(sorts[0])
+		if !entity.ValidPostOrder(filter.Order) {
+			return entity.PostFilter{}, fmt.Errorf("usecase: filter: unknown sort %s: %w", sorts[0], ErrInvalidQuery)
+		}
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return entity.PostFilter{}, fmt.Errorf("usecase: filter: from is after to: %w", ErrInvalidQuery)
+	}
+
+	return filter, nil
+}
+
+// singleValue returns the only non empty value of a query parameter
+func singleValue(key string, values []string) (string, error) {
+	if len(values) != 1 || strings.TrimSpace(values[0]) == "" {
+		return "", fmt.Errorf("usecase: filter: %s needs exactly one value: %w", key, ErrInvalidQuery)
+	}
+	return strings.TrimSpace(values[0]), nil
+}
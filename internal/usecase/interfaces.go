@@ -1,6 +1,12 @@
 package usecase
 
-import "forum/internal/repository"
+import (
+	"forum/config"
+	"forum/internal/repository"
+	"forum/pkg/mailer"
+	"forum/pkg/password"
+	"forum/pkg/ratelimit"
+)
 
 type UseCase struct {
 	AuthorizationUsecase `json:"authorization_usecase,omitempty"`
@@ -8,14 +14,22 @@ type UseCase struct {
 	PostsVoterUsecase    `json:"posts_voter_usecase,omitempty"`
 	CommentsUsecase      `json:"comments_usecase,omitempty"`
 	UsersUsecase         `json:"users_usecase,omitempty"`
+	ModerationUsecase    `json:"moderation_usecase,omitempty"`
+	ReportsUsecase       `json:"reports_usecase,omitempty"`
+	CategoriesUsecase    `json:"categories_usecase,omitempty"`
 }
 
-func NewUseCase(r *repository.Repository) *UseCase {
+func NewUseCase(r *repository.Repository, cnf *config.Config, m mailer.Mailer, l *ratelimit.Limiter, p *password.Policy, h password.Hasher) *UseCase {
+	moderation := NewModerationUsecase(r, cnf)
+
 	return &UseCase{
-		AuthorizationUsecase: NewAuthUseCase(r.Authorization),
-		PostsUsecase:         NewPostUseCase(r.Posts),
+		AuthorizationUsecase: NewAuthUseCase(r.Authorization, r.Uploads, m, l, p, h, cnf),
+		PostsUsecase:         NewPostUseCase(r.Posts, r.Categories, r.Uploads, cnf),
 		PostsVoterUsecase:    NewPostVotesUsecase(r.PostVoter),
-		CommentsUsecase:      NewCommentUsecase(r.Commenter),
-		UsersUsecase:         NewUserUsecase(r.User),
+		CommentsUsecase:      NewCommentUsecase(r.Commenter, cnf),
+		UsersUsecase:         NewUserUsecase(r.User, cnf),
+		ModerationUsecase:    moderation,
+		ReportsUsecase:       NewReportUsecase(r, moderation),
+		CategoriesUsecase:    NewCategoryUsecase(r),
 	}
 }
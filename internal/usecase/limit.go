@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"forum/internal/entity"
+)
+
+var ErrTooManyAttempts = errors.New("too many attempts")
+
+// LimitError is returned when a client is throttled or locked out, Wait is
+// how long until it may try again
+type LimitError struct {
+	Wait time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v, retry in %v", ErrTooManyAttempts, e.Wait.Round(time.Second))
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// limitKeys are the rate limit keys of an attempt at action, one for the
+// address of the client and one for the username it tries
+func limitKeys(action, username string, client entity.Client) []string {
+	return []string{
+		action + ":ip:" + client.IP,
+		action + ":user:" + strings.ToLower(username),
+	}
+}
+
+// allow takes an attempt from every key, when one of them is throttled or
+// locked it fails with a *LimitError holding the longest wait
+func (u *AuthUserUse) allow(keys []string) error {
+	var wait time.Duration
+	for _, key := range keys {
+		w, err := u.limiter.Allow(key)
+		if err != nil {
+			return fmt.Errorf("usecase: rate limit: %w", err)
+		}
+		if w > wait {
+			wait = w
+		}
+	}
+
+	if wait > 0 {
+		return &LimitError{Wait: wait}
+	}
+	return nil
+}
+
+// failed counts a failed sign-in against every key
+func (u *AuthUserUse) failed(keys []string) {
+	for _, key := range keys {
+		if err := u.limiter.Fail(key); err != nil {
+			log.Printf("usecase: rate limit: fail %s: %v", key, err)
+		}
+	}
+}
+
+// checkDummyPassword costs as much as checking a real password, so an
+// unknown username answers as slowly as a wrong password
+func (u *AuthUserUse) checkDummyPassword(password string) {
+	u.dummyOnce.Do(func() {
+		u.dummyHash, _ = u.generateHashPassword("not the password of anyone")
+	})
+	checkPasswordHash(password, u.dummyHash)
+}
@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"fmt"
+
+	"forum/internal/entity"
+	"forum/pkg/markdown"
+)
+
+// PreviewPost renders post content the way it would be shown once saved
+func (pu *PostUseCase) PreviewPost(content string) (string, error) {
+	content, err := verificateContent(content)
+	if err != nil {
+		return "", fmt.Errorf("usecase: preview post: %w", err)
+	}
+
+	return markdown.Render(content), nil
+}
+
+// renderedHTML returns the HTML cached when a body was saved, posts and
+// comments stored before Markdown support have none and are rendered now
+func renderedHTML(cached, source string) string {
+	if cached != "" || source == "" {
+		return cached
+	}
+	return markdown.Render(source)
+}
+
+func renderPosts(posts []entity.Post) {
+	for i := range posts {
+		posts[i].ContentHTML = renderedHTML(posts[i].ContentHTML, posts[i].Content)
+	}
+}
+
+func renderComment(comment *entity.Comments) {
+	if !comment.Deleted {
+		comment.ContentHTML = renderedHTML(comment.ContentHTML, comment.Content)
+	}
+}
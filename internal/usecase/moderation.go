@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"forum/config"
+	"forum/internal/entity"
+	"forum/internal/repository"
+	"forum/pkg/imagestore"
+)
+
+var (
+	ErrForbidden     = errors.New("permission denied")
+	ErrInvalidRole   = errors.New("invalid role")
+	ErrPostLocked    = errors.New("post is locked")
+	ErrUserSuspended = errors.New("user is suspended")
+)
+
+const auditLogLimit = 200
+
+type ModerationUsecase interface {
+	DeletePost(moderator entity.UserModel, postId int) error
+	DeleteComment(moderator entity.UserModel, commentId int) error
+	LockPost(moderator entity.UserModel, postId int, locked bool) error
+	SuspendUser(moderator entity.UserModel, username string, until time.Time) error
+	SetRole(admin entity.UserModel, username, role string) error
+	ResetTwoFactor(admin entity.UserModel, username string) error
+	GetAuditLog(moderator entity.UserModel) ([]entity.AuditEntry, error)
+}
+
+type ModeratorUsecase struct {
+	posts      repository.Posts
+	comments   repository.Commenter
+	users      repository.Authorization
+	moderation repository.Moderation
+	uploads    repository.Uploads
+	images     *imagestore.Store
+}
+
+func NewModerationUsecase(r *repository.Repository, cnf *config.Config) *ModeratorUsecase {
+	return &ModeratorUsecase{
+		posts:      r.Posts,
+		comments:   r.Commenter,
+		users:      r.Authorization,
+		moderation: r.Moderation,
+		uploads:    r.Uploads,
+		images:     imagestore.New(cnf.UploadDir, cnf.MaxUploadSize),
+	}
+}
+
+// DeletePost removes any post, used by moderators on posts they do not own
+func (m *ModeratorUsecase) DeletePost(moderator entity.UserModel, postId int) error {
+	if !moderator.Can(entity.PermDeleteAnyPost) {
+		return fmt.Errorf("usecase: moderation delete post: %w", ErrForbidden)
+	}
+
+	post, err := m.posts.GetPostbyId(postId)
+	if err != nil {
+		return err
+	}
+
+	files, err := m.moderation.DeletePost(postId, auditEntry(moderator, entity.AuditDeletePost, "post", strconv.Itoa(postId),
+		fmt.Sprintf("author %s, title %q", post.PostAuthor, post.Title)))
+	if err != nil {
+		return err
+	}
+
+	removeUnusedUploads(m.uploads, m.images, files)
+	return nil
+}
+
+// DeleteComment leaves a tombstone in place of any comment
+func (m *ModeratorUsecase) DeleteComment(moderator entity.UserModel, commentId int) error {
+	if !moderator.Can(entity.PermDeleteAnyComment) {
+		return fmt.Errorf("usecase: moderation delete comment: %w", ErrForbidden)
+	}
+
+	comment, err := m.comments.GetCommentById(commentId)
+	if err != nil {
+		return err
+	}
+
+	return m.moderation.DeleteComment(commentId, auditEntry(moderator, entity.AuditDeleteComment, "comment", strconv.Itoa(commentId),
+		fmt.Sprintf("author %s, post %d", comment.Author, comment.PostId)))
+}
+
+// LockPost stops or allows new comments on a post
+func (m *ModeratorUsecase) LockPost(moderator entity.UserModel, postId int, locked bool) error {
+	if !moderator.Can(entity.PermLockThread) {
+		return fmt.Errorf("usecase: moderation lock post: %w", ErrForbidden)
+	}
+
+	if _, err := m.posts.GetPostbyId(postId); err != nil {
+		return err
+	}
+
+	action := entity.AuditLockPost
+	if !locked {
+		action = entity.AuditUnlockPost
+	}
+	return m.moderation.SetPostLocked(postId, locked, auditEntry(moderator, action, "post", strconv.Itoa(postId), ""))
+}
+
+// SuspendUser forbids signing in until the given time, zero time lifts the suspension.
+// Users with the same or a higher role cannot be suspended.
+func (m *ModeratorUsecase) SuspendUser(moderator entity.UserModel, username string, until time.Time) error {
+	if !moderator.Can(entity.PermSuspendUser) {
+		return fmt.Errorf("usecase: moderation suspend user: %w", ErrForbidden)
+	}
+
+	user, err := m.users.GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("usecase: moderation suspend user: %w", ErrUserNotFound)
+	}
+
+	if !moderator.Outranks(user) {
+		return fmt.Errorf("usecase: moderation suspend user: %w", ErrForbidden)
+	}
+
+	entry := auditEntry(moderator, entity.AuditSuspendUser, "user", user.Username, "until "+until.Format(time.RFC3339))
+	if until.IsZero() {
+		entry = auditEntry(moderator, entity.AuditUnsuspendUser, "user", user.Username, "")
+	}
+	return m.moderation.SetUserSuspended(user.Username, until, entry)
+}
+
+// SetRole changes the role of a user, administrators only
+func (m *ModeratorUsecase) SetRole(admin entity.UserModel, username, role string) error {
+	if !admin.Can(entity.PermManageRoles) {
+		return fmt.Errorf("usecase: moderation set role: %w", ErrForbidden)
+	}
+
+	if !entity.ValidRole(role) {
+		return fmt.Errorf("usecase: moderation set role: %w", ErrInvalidRole)
+	}
+
+	user, err := m.users.GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("usecase: moderation set role: %w", ErrUserNotFound)
+	}
+
+	if user.Username == admin.Username {
+		return fmt.Errorf("usecase: moderation set role: cannot change own role: %w", ErrForbidden)
+	}
+
+	return m.moderation.SetUserRole(user.Username, role,
+		auditEntry(admin, entity.AuditSetRole, "user", user.Username, fmt.Sprintf("%s -> %s", user.Role, role)))
+}
+
+// ResetTwoFactor turns two-factor authentication off for a user who lost
+// their authenticator app and recovery codes, administrators only
+func (m *ModeratorUsecase) ResetTwoFactor(admin entity.UserModel, username string) error {
+	if !admin.Can(entity.PermResetTwoFactor) {
+		return fmt.Errorf("usecase: moderation reset two factor: %w", ErrForbidden)
+	}
+
+	user, err := m.users.GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("usecase: moderation reset two factor: %w", ErrUserNotFound)
+	}
+
+	if user.Username == admin.Username {
+		return fmt.Errorf("usecase: moderation reset two factor: use the own settings: %w", ErrForbidden)
+	}
+
+	return m.moderation.ResetTwoFactor(user.UserId, auditEntry(admin, entity.AuditResetTwoFactor, "user", user.Username, ""))
+}
+
+func (m *ModeratorUsecase) GetAuditLog(moderator entity.UserModel) ([]entity.AuditEntry, error) {
+	if !moderator.Can(entity.PermViewAuditLog) {
+		return nil, fmt.Errorf("usecase: moderation audit log: %w", ErrForbidden)
+	}
+
+	return m.moderation.GetAuditLog(auditLogLimit)
+}
+
+// auditEntry describes an action of actor for the audit log, the repository
+// writes it in the transaction of the action
+func auditEntry(actor entity.UserModel, action, targetType, targetId, details string) entity.AuditEntry {
+	return entity.AuditEntry{
+		Actor:      actor.Username,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Details:    details,
+	}
+}
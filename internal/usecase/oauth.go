@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"forum/config"
+	"forum/internal/entity"
+	"forum/pkg/oauth"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown sign-in provider")
+	ErrOAuthFailed     = errors.New("sign-in with the provider failed")
+	ErrIdentityTaken   = errors.New("this account is linked to another user")
+)
+
+const oauthTimeout = 20 * time.Second
+
+// OAuthProviders names the enabled sign-in providers in configuration order
+func (u *AuthUserUse) OAuthProviders() []string {
+	return u.providerNames
+}
+
+// OAuthLoginURL is where a sign-in with provider starts, state and verifier
+// have to be kept by the caller and passed back to OAuthLogin
+func (u *AuthUserUse) OAuthLoginURL(provider, state, verifier string) (string, error) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return "", fmt.Errorf("usecase: oauth login url: %w", ErrUnknownProvider)
+	}
+
+	return p.AuthCodeURL(state, verifier), nil
+}
+
+// OAuthLogin finishes a sign-in with provider and starts a session. A known
+// identity signs in its user, a logged in current user gets the identity
+// linked, otherwise it is linked to the user with the same verified email
+// or a new account is created for it. A sign-in, not a link, of a user with
+// two-factor authentication on fails with a *TwoFactorError.
+func (u *AuthUserUse) OAuthLogin(provider, code, verifier string, current entity.UserModel, client entity.Client) (entity.UserModel, error) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return entity.UserModel{}, fmt.Errorf("usecase: oauth login: %w", ErrUnknownProvider)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oauthTimeout)
+	defer cancel()
+
+	accessToken, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		return entity.UserModel{}, fmt.Errorf("usecase: oauth login: %v: %w", err, ErrOAuthFailed)
+	}
+	id, err := p.Identity(ctx, accessToken)
+	if err != nil {
+		return entity.UserModel{}, fmt.Errorf("usecase: oauth login: %v: %w", err, ErrOAuthFailed)
+	}
+
+	loggedIn := current != (entity.UserModel{})
+	user, err := u.Repository.GetUserByIdentity(provider, id.Subject)
+	switch {
+	case err == nil:
+		if loggedIn && user.UserId != current.UserId {
+			return entity.UserModel{}, fmt.Errorf("usecase: oauth login: %w", ErrIdentityTaken)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return entity.UserModel{}, err
+	case loggedIn:
+		if err := u.Repository.LinkIdentity(identityOf(current.UserId, id)); err != nil {
+			return entity.UserModel{}, err
+		}
+		user = current
+	default:
+		user, err = u.userForIdentity(id)
+		if err != nil {
+			return entity.UserModel{}, err
+		}
+	}
+
+	if !loggedIn {
+		if err := u.twoFactorChallenge(user); err != nil {
+			return entity.UserModel{}, err
+		}
+	}
+
+	return u.startSession(user, client)
+}
+
+// GetIdentities lists the provider accounts linked to a user
+func (u *AuthUserUse) GetIdentities(userId int) ([]entity.Identity, error) {
+	return u.Repository.GetIdentities(userId)
+}
+
+// userForIdentity links a new identity to the account with the same email
+// when both sides verified it, or creates an account without a password
+func (u *AuthUserUse) userForIdentity(id oauth.Identity) (entity.UserModel, error) {
+	email := ""
+	if id.Email != "" {
+		existing, err := u.Repository.GetUserByEmail(id.Email)
+		switch {
+		case err == nil:
+			if id.EmailVerified && existing.EmailVerified {
+				if err := u.Repository.LinkIdentity(identityOf(existing.UserId, id)); err != nil {
+					return entity.UserModel{}, err
+				}
+				return u.Repository.GetUserByUsername(existing.Username)
+			}
+			// the address belongs to someone who has not proven it, or the
+			// provider did not, so the new account goes without an email
+		case errors.Is(err, sql.ErrNoRows):
+			email = id.Email
+		default:
+			return entity.UserModel{}, err
+		}
+	}
+
+	username, err := u.freeUsername(id)
+	if err != nil {
+		return entity.UserModel{}, err
+	}
+
+	user := entity.UserModel{
+		Username:      username,
+		Email:         email,
+		EmailVerified: email != "" && id.EmailVerified,
+	}
+	if _, err := u.Repository.CreateUserWithIdentity(user, identityOf(0, id)); err != nil {
+		return entity.UserModel{}, err
+	}
+
+	return u.Repository.GetUserByUsername(username)
+}
+
+// freeUsername turns the provider username into one that passes checkUser
+// and is not taken yet
+func (u *AuthUserUse) freeUsername(id oauth.Identity) (string, error) {
+	base := id.Username
+	if base == "" {
+		base = strings.Split(id.Email, "@")[0]
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_'):
+			return r
+		case r == '-' || r == ' ':
+			return '_'
+		}
+		return -1
+	}, base)
+	if len(base) > 12 {
+		base = base[:12]
+	}
+	if base == "" {
+		base = "user"
+	}
+	for len(base) < 4 {
+		base += "_"
+	}
+
+	for i := 0; i < 100; i++ {
+		name := base
+		if i > 0 {
+			name += strconv.Itoa(i)
+		}
+
+		_, err := u.Repository.GetUserByUsername(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return name, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("usecase: free username: %w", ErrUserExist)
+}
+
+func identityOf(userId int, id oauth.Identity) entity.Identity {
+	return entity.Identity{
+		UserId:   userId,
+		Provider: id.Provider,
+		Subject:  id.Subject,
+		Email:    id.Email,
+	}
+}
+
+// newProviders builds the enabled providers of the config, endpoints and
+// scopes that are not configured fall back to the defaults of the provider
+func newProviders(cnf *config.Config) (map[string]*oauth.Provider, []string) {
+	defaults := map[string]oauth.Endpoint{
+		"github": oauth.GitHub,
+		"google": oauth.Google,
+	}
+
+	providers := make(map[string]*oauth.Provider)
+	var names []string
+	for _, pc := range cnf.OAuthProviders {
+		if pc.Name == "" || pc.ClientID == "" {
+			continue
+		}
+
+		endpoint := defaults[pc.Name]
+		if pc.AuthURL != "" {
+			endpoint.AuthURL = pc.AuthURL
+		}
+		if pc.TokenURL != "" {
+			endpoint.TokenURL = pc.TokenURL
+		}
+		if pc.UserInfoURL != "" {
+			endpoint.UserInfoURL = pc.UserInfoURL
+		}
+		if pc.EmailsURL != "" {
+			endpoint.EmailsURL = pc.EmailsURL
+		}
+		scopes := pc.Scopes
+		if len(scopes) == 0 {
+			scopes = oauth.DefaultScopes[pc.Name]
+		}
+
+		redirect := cnf.BaseURL + "/auth/oauth/" + pc.Name + "/callback"
+		providers[pc.Name] = oauth.NewProvider(pc.Name, pc.ClientID, pc.ClientSecret, redirect, scopes, endpoint)
+		names = append(names, pc.Name)
+	}
+
+	return providers, names
+}
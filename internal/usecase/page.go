@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"fmt"
+	"strconv"
+
+	"forum/internal/entity"
+)
+
+// defaultPageSize is used when the config does not set pageSize
+const defaultPageSize = 10
+
+// pageFromQuery reads the limit and cursor query parameters, limit falls back to pageSize
+func pageFromQuery(query map[string][]string, pageSize int) (entity.PageRequest, error) {
+	page := entity.PageRequest{Limit: pageSize}
+	if page.Limit <= 0 {
+		page.Limit = defaultPageSize
+	}
+
+	if limit := firstValue(query, "limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > entity.MaxPageSize {
+			return entity.PageRequest{}, fmt.Errorf("usecase: page: limit: %w", ErrInvalidQuery)
+		}
+		page.Limit = n
+	}
+
+	cursor, err := entity.ParseCursor(firstValue(query, "cursor"))
+	if err != nil {
+		return entity.PageRequest{}, fmt.Errorf("usecase: page: %v: %w", err, ErrInvalidQuery)
+	}
+	page.Cursor = cursor
+
+	return page, nil
+}
+
+// isPageParam reports whether a query key belongs to pagination rather than to a filter
+func isPageParam(key string) bool {
+	return key == "limit" || key == "cursor"
+}
+
+// fetchPage asks list for one post more than the page holds to learn whether a next page exists
+func fetchPage(page entity.PageRequest, order entity.PostOrder, list func(entity.PageRequest) ([]entity.Post, error)) (entity.PostPage, error) {
+	posts, err := list(entity.PageRequest{Limit: page.Limit + 1, Cursor: page.Cursor})
+	if err != nil {
+		return entity.PostPage{}, err
+	}
+
+	result := entity.PostPage{Posts: posts}
+	if len(posts) > page.Limit {
+		result.Posts = posts[:page.Limit]
+		result.Next = entity.CursorAfter(result.Posts[page.Limit-1], order).String()
+	}
+	renderPosts(result.Posts)
+
+	return result, nil
+}
+
+func firstValue(query map[string][]string, key string) string {
+	if values := query[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -2,66 +2,167 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"unicode"
 
+	"forum/config"
 	"forum/internal/entity"
 	"forum/internal/repository"
+	"forum/pkg/imagestore"
+	"forum/pkg/markdown"
 )
 
 type PostsUsecase interface {
-	CreatePost(post entity.Post) error
-	GetAllPosts() ([]entity.Post, error)
-	GetPostsByCategory(category string) ([]entity.Post, error)
+	CreatePost(post entity.Post, uploads ...entity.Upload) (int, error)
+	GetAllPosts(page entity.PageRequest) (entity.PostPage, error)
+	GetPostsByCategory(category string, page entity.PageRequest) (entity.PostPage, error)
 	GetPostsById(id int) (entity.Post, error)
-	GetCreatedPosts(author string) ([]entity.Post, error)
-	GetAllPostsFromFilter(user entity.UserModel, query map[string][]string) ([]entity.Post, error)
+	GetCreatedPosts(author string, page entity.PageRequest) (entity.PostPage, error)
+	GetAllPostsFromFilter(user entity.UserModel, query map[string][]string) (entity.PostPage, error)
+	UpdatePost(post entity.Post, user entity.UserModel) error
+	DeletePost(postId int, user entity.UserModel) error
+	GetPostRevisions(postId int) ([]entity.PostRevision, error)
+	Search(raw string) ([]entity.SearchResult, error)
+	PreviewPost(content string) (string, error)
 }
 
 type PostUseCase struct {
 	PostRepository repository.Posts
+	categories     repository.Categories
+	uploads        repository.Uploads
+	images         *imagestore.Store
+	maxAttachments int
+	pageSize       int
 }
 
-func NewPostUseCase(p repository.Posts) *PostUseCase {
+func NewPostUseCase(p repository.Posts, c repository.Categories, up repository.Uploads, cnf *config.Config) *PostUseCase {
+	pageSize := cnf.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
 	return &PostUseCase{
-		p,
+		PostRepository: p,
+		categories:     c,
+		uploads:        up,
+		images:         imagestore.New(cnf.UploadDir, cnf.MaxUploadSize),
+		maxAttachments: cnf.MaxAttachments,
+		pageSize:       pageSize,
 	}
 }
 
-func (pu *PostUseCase) CreatePost(post entity.Post) error {
-	if err := verificatePost(post); err != nil {
+// CreatePost stores a new post and returns its id, uploads are saved as its
+// image attachments
+func (pu *PostUseCase) CreatePost(post entity.Post, uploads ...entity.Upload) (int, error) {
+	if err := verificatePost(&post); err != nil {
+		return 0, err
+	}
+
+	var err error
+	post.Category, err = knownCategories(pu.categories, post.Category)
+	if err != nil {
+		return 0, err
+	}
+
+	var images []imagestore.Image
+	images, post.Attachments, err = pu.loadAttachments(uploads)
+	if err != nil {
+		return 0, err
+	}
+
+	post.ContentHTML = markdown.Render(post.Content)
+
+	// the files are written once every upload is valid and go again when
+	// the post cannot be stored
+	if err := pu.writeAttachments(images, post.Attachments); err != nil {
+		return 0, err
+	}
+
+	id, err := pu.PostRepository.CreatePost(post)
+	if err != nil {
+		removeUnusedUploads(pu.uploads, pu.images, attachmentFiles(post.Attachments))
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdatePost edits title, content and categories of a post owned by user,
+// the previous version is kept as a revision
+func (pu *PostUseCase) UpdatePost(post entity.Post, user entity.UserModel) error {
+	old, err := pu.PostRepository.GetPostbyId(post.PostId)
+	if err != nil {
 		return err
 	}
-	_, err := pu.PostRepository.CreatePost(post)
+
+	if old.PostAuthor != user.Username {
+		return fmt.Errorf("usecase: update post: %w", ErrNotAuthor)
+	}
+
+	if err := verificatePost(&post); err != nil {
+		return err
+	}
+
+	post.Category, err = knownCategories(pu.categories, post.Category)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	post.ContentHTML = markdown.Render(post.Content)
+	return pu.PostRepository.UpdatePostById(post)
 }
 
-func (pu *PostUseCase) GetAllPosts() ([]entity.Post, error) {
-	posts, err := pu.PostRepository.GetAllPosts()
+// DeletePost removes a post owned by user and the files of its attachments
+// nothing else uses
+func (pu *PostUseCase) DeletePost(postId int, user entity.UserModel) error {
+	post, err := pu.PostRepository.GetPostbyId(postId)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for i := range posts {
-		category, err := pu.PostRepository.CategoriesByPostId(posts[i].PostId)
-		if err != nil {
-			return nil, err
-		}
-		posts[i].Category = category
+	if post.PostAuthor != user.Username {
+		return fmt.Errorf("usecase: delete post: %w", ErrNotAuthor)
 	}
 
-	return posts, nil
+	files, err := pu.PostRepository.DeletePostById(postId)
+	if err != nil {
+		return err
+	}
+
+	removeUnusedUploads(pu.uploads, pu.images, files)
+	return nil
 }
 
-func (pu *PostUseCase) GetPostsByCategory(category string) ([]entity.Post, error) {
-	posts, err := pu.PostRepository.GetPostsByCategory(category)
+// GetPostRevisions returns prior versions of a post, oldest first, each with
+// a line diff against the version that replaced it
+func (pu *PostUseCase) GetPostRevisions(postId int) ([]entity.PostRevision, error) {
+	post, err := pu.GetPostsById(postId)
 	if err != nil {
 		return nil, err
 	}
 
-	return posts, nil
+	revisions, err := pu.PostRepository.GetPostRevisions(postId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		next := post.Content
+		if i+1 < len(revisions) {
+			next = revisions[i+1].Content
+		}
+		revisions[i].Diff = diffLines(revisions[i].Content, next)
+	}
+
+	return revisions, nil
+}
+
+func (pu *PostUseCase) GetAllPosts(page entity.PageRequest) (entity.PostPage, error) {
+	return pu.listPosts(entity.PostFilter{Order: entity.OrderOldest}, page)
+}
+
+func (pu *PostUseCase) GetPostsByCategory(category string, page entity.PageRequest) (entity.PostPage, error) {
+	return pu.listPosts(entity.PostFilter{Categories: []string{category}, Order: entity.OrderOldest}, page)
 }
 
 func (pu *PostUseCase) GetPostsById(id int) (entity.Post, error) {
@@ -75,94 +176,58 @@ func (pu *PostUseCase) GetPostsById(id int) (entity.Post, error) {
 		return entity.Post{}, err
 	}
 
+	post.Attachments, err = pu.PostRepository.GetAttachmentsByPostId(post.PostId)
+	if err != nil {
+		return entity.Post{}, err
+	}
+
+	post.ContentHTML = renderedHTML(post.ContentHTML, post.Content)
+
 	return post, nil
 }
 
-func (pu *PostUseCase) GetCreatedPosts(author string) ([]entity.Post, error) {
-	posts, err := pu.PostRepository.GetCreatedPosts(author)
+func (pu *PostUseCase) GetCreatedPosts(author string, page entity.PageRequest) (entity.PostPage, error) {
+	return pu.listPosts(entity.PostFilter{Author: author, Order: entity.OrderOldest}, page)
+}
+
+// GetAllPostsFromFilter lists one page of the feed described by the query
+// string, see ParsePostFilter, limit and cursor select the page
+func (pu *PostUseCase) GetAllPostsFromFilter(user entity.UserModel, query map[string][]string) (entity.PostPage, error) {
+	filter, err := ParsePostFilter(query)
 	if err != nil {
-		return nil, err
+		return entity.PostPage{}, err
 	}
 
-	for i := range posts {
-		category, err := pu.PostRepository.CategoriesByPostId(posts[i].PostId)
-		if err != nil {
-			return nil, err
-		}
-		posts[i].Category = category
+	page, err := pageFromQuery(query, pu.pageSize)
+	if err != nil {
+		return entity.PostPage{}, err
 	}
 
-	return posts, nil
+	return pu.listPosts(filter, page)
 }
 
-func (pu *PostUseCase) GetAllPostsFromFilter(user entity.UserModel, query map[string][]string) ([]entity.Post, error) {
-	var posts []entity.Post
-	var err error
+// listPosts fetches one page of the filtered feed and fills in the categories of its posts
+func (pu *PostUseCase) listPosts(filter entity.PostFilter, page entity.PageRequest) (entity.PostPage, error) {
+	if page.Limit <= 0 {
+		page.Limit = pu.pageSize
+	}
 
-	for key, value := range query {
-		switch key {
-		case "category":
-			posts, err = pu.PostRepository.GetPostsByCategory(strings.Join(value, ""))
-			if err != nil {
-				return nil, err
-			}
-		case "time":
-			switch strings.Join(value, "") {
-			case "new":
-				posts, err = pu.PostRepository.GetNewstPosts()
-				if err != nil {
-					return nil, err
-				}
-			case "old":
-				posts, err = pu.PostRepository.GetOldesPosts()
-				if err != nil {
-					return nil, err
-				}
-			default:
-				return nil, err
-			}
-			if err != nil {
-				return nil, err
-			}
-		case "vote":
-			switch strings.Join(value, "") {
-			case "like":
-				posts, err = pu.PostRepository.GetMostLikedPosts()
-				if err != nil {
-					return nil, err
-				}
-			case "dislike":
-				posts, err = pu.PostRepository.GetMostDisikedPosts()
-				if err != nil {
-					return nil, err
-				}
-			default:
-				return nil, err
-			}
-			if err != nil {
-				return nil, err
-			}
-		case "clean":
-			switch strings.Join(value, "") {
-			case "true":
-				posts, err = pu.PostRepository.GetAllPosts()
-				if err != nil {
-					return nil, err
-				}
-			}
-		default:
-			return nil, errors.New("error posts from filters")
-		}
-		for i := range posts {
-			categories, err := pu.PostRepository.CategoriesByPostId(posts[i].PostId)
-			if err != nil {
-				return nil, err
-			}
-			posts[i].Category = categories
+	result, err := fetchPage(page, filter.Order, func(page entity.PageRequest) ([]entity.Post, error) {
+		return pu.PostRepository.GetPosts(filter, page)
+	})
+	if err != nil {
+		return entity.PostPage{}, err
+	}
+
+	for i := range result.Posts {
+		category, err := pu.PostRepository.CategoriesByPostId(result.Posts[i].PostId)
+		if err != nil {
+			return entity.PostPage{}, err
 		}
+		result.Posts[i].Category = category
 	}
 
-	return posts, nil
+	return result, nil
 }
 
 var (
@@ -171,37 +236,48 @@ var (
 	ErrNoTitle              = errors.New("no title")
 	ErrNoContent            = errors.New("empty content section")
 	ErrInvalidContent       = errors.New("invalid content")
+	ErrNotAuthor            = errors.New("user is not the author")
 )
 
-func verificatePost(post entity.Post) error {
-	if len(post.Title) > 100 {
-		return ErrInvalidTitleLength
+// verificatePost cleans title and content, see cleanText, and checks that
+// both are present and within their limits in user-perceived characters
+func verificatePost(post *entity.Post) error {
+	title, ok := cleanText(post.Title, false)
+	if !ok {
+		return ErrInvalidContent
 	}
-	if len(post.Content) > 2000 {
-		return ErrInvalidContentLength
+	post.Title = strings.TrimSpace(title)
+	if post.Title == "" {
+		return ErrNoTitle
 	}
-
-	post.Content = strings.Trim(post.Content, " \n\r")
-	if post.Content == "" {
-		return ErrNoContent
+	if tooLong(post.Title, maxTitleLength) {
+		return ErrInvalidTitleLength
 	}
 
-	for _, w := range post.Content {
-		if (w < 32 || w > 126) && (w != 13 && w != 10) {
-			return ErrInvalidContent
-		}
+	content, err := verificateContent(post.Content)
+	if err != nil {
+		return err
 	}
+	post.Content = content
 
-	post.Title = strings.Trim(post.Title, " \n\r")
-	if post.Title == "" {
-		return ErrNoTitle
+	return nil
+}
+
+// verificateContent cleans the Markdown source of a post and checks its
+// length, tabs and leading spaces are kept so code blocks stay indented
+func verificateContent(content string) (string, error) {
+	content, ok := cleanText(content, true)
+	if !ok {
+		return "", ErrInvalidContent
 	}
 
-	for _, w := range post.Title {
-		if (w < 32 || w > 126) && (w != 13 && w != 10) {
-			return ErrInvalidContent
-		}
+	content = strings.TrimRightFunc(strings.TrimLeft(content, "\n"), unicode.IsSpace)
+	if strings.TrimSpace(content) == "" {
+		return "", ErrNoContent
+	}
+	if tooLong(content, maxContentLength) {
+		return "", ErrInvalidContentLength
 	}
 
-	return nil
+	return content, nil
 }
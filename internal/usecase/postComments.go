@@ -6,15 +6,23 @@ import (
 	"fmt"
 	"strings"
 
+	"forum/config"
 	"forum/internal/entity"
 	"forum/internal/repository"
+	"forum/pkg/markdown"
+)
+
+var (
+	ErrCommentDeleted = errors.New("comment is deleted")
+	ErrInvalidParent  = errors.New("invalid parent comment")
 )
 
 type CommentsUsecase interface {
-	LikeComment(commentId int, username string) error
-	DislikeComment(commentId int, username string) error
+	Vote(commentId int, username string, value int) (entity.VoteCount, error)
 	GetCommentsByPostId(commentId int) ([]entity.Comments, error)
-	CreateComment(comment entity.Comments) error
+	CreateComment(comment entity.Comments) (int, error)
+	UpdateComment(comment entity.Comments, user entity.UserModel) error
+	DeleteComment(commentId int, user entity.UserModel) error
 	GetCommentById(commentId int) (entity.Comments, error)
 	CommentsLikes(postId int) (map[int][]string, error)
 	CommentsDislikes(postId int) (map[int][]string, error)
@@ -22,75 +30,102 @@ type CommentsUsecase interface {
 
 type CommentUsecase struct {
 	CommentRepository repository.Commenter
+	maxDepth          int
+	collapseAfter     int
 }
 
-func NewCommentUsecase(c repository.Commenter) *CommentUsecase {
+func NewCommentUsecase(c repository.Commenter, cnf *config.Config) *CommentUsecase {
 	return &CommentUsecase{
-		c,
+		CommentRepository: c,
+		maxDepth:          cnf.CommentMaxDepth,
+		collapseAfter:     cnf.CommentCollapseAfter,
 	}
 }
 
-// LikeComment likes
-func (c *CommentUsecase) LikeComment(commentId int, username string) error {
-	if err := c.CommentRepository.CommentLiked(commentId, username); err == nil {
-		if err := c.CommentRepository.RemoveLikeFromComment(commentId, username); err != nil {
-			return err
-		}
+// Vote sets the vote of the user on a comment to value, see PostVoterUsecase.Vote
+func (c *CommentUsecase) Vote(commentId int, username string, value int) (entity.VoteCount, error) {
+	if !validVote(value) {
+		return entity.VoteCount{}, fmt.Errorf("usecase: vote comment: %w", ErrInvalidVote)
+	}
 
-		return nil
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase:likecomment: %w", err)
+	count, err := c.CommentRepository.SetCommentVote(commentId, username, value)
+	if err != nil {
+		return entity.VoteCount{}, fmt.Errorf("usecase: vote comment: %w", err)
 	}
 
-	if err := c.CommentRepository.CommentDisliked(commentId, username); err == nil {
-		if err := c.CommentRepository.RemoveDislikeFromComment(commentId, username); err != nil {
-			return err
-		}
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase:likecomment: %w", err)
+	return count, nil
+}
+
+// CreateComment stores a comment and returns its id
+func (c *CommentUsecase) CreateComment(comment entity.Comments) (int, error) {
+	if err := checkComment(&comment); err != nil {
+		return 0, err
 	}
 
-	if err := c.CommentRepository.LikeComment(commentId, username); err != nil {
-		return err
+	locked, err := c.CommentRepository.IsPostLocked(comment.PostId)
+	if err != nil {
+		return 0, err
+	}
+	if locked {
+		return 0, fmt.Errorf("usecase: create comment: %w", ErrPostLocked)
 	}
 
-	return nil
+	if comment.ParentId != 0 {
+		parent, err := c.CommentRepository.GetCommentById(comment.ParentId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, fmt.Errorf("usecase: create comment: %w", ErrInvalidParent)
+			}
+			return 0, err
+		}
+		if parent.PostId != comment.PostId {
+			return 0, fmt.Errorf("usecase: create comment: %w", ErrInvalidParent)
+		}
+	}
+
+	comment.ContentHTML = markdown.Render(comment.Content)
+	return c.CommentRepository.CreateComment(comment)
 }
 
-func (c *CommentUsecase) DislikeComment(commentId int, username string) error {
-	if err := c.CommentRepository.CommentDisliked(commentId, username); err == nil {
-		if err := c.CommentRepository.RemoveDislikeFromComment(commentId, username); err != nil {
-			return err
-		}
-		return nil
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase:dislikecomment: %w", err)
+// UpdateComment edits a comment owned by user
+func (c *CommentUsecase) UpdateComment(comment entity.Comments, user entity.UserModel) error {
+	old, err := c.CommentRepository.GetCommentById(comment.CommentId)
+	if err != nil {
+		return err
 	}
 
-	if err := c.CommentRepository.CommentLiked(commentId, username); err == nil {
-		if err := c.CommentRepository.RemoveLikeFromComment(commentId, username); err != nil {
-			return err
-		}
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase:likecomment: %w", err)
+	if old.Author != user.Username {
+		return fmt.Errorf("usecase: update comment: %w", ErrNotAuthor)
+	}
+
+	if old.Deleted {
+		return fmt.Errorf("usecase: update comment: %w", ErrCommentDeleted)
 	}
 
-	if err := c.CommentRepository.DislikeComment(commentId, username); err != nil {
+	if err := checkComment(&comment); err != nil {
 		return err
 	}
-	return nil
+
+	comment.ContentHTML = markdown.Render(comment.Content)
+	return c.CommentRepository.UpdateComment(comment)
 }
 
-func (c *CommentUsecase) CreateComment(comment entity.Comments) error {
-	if err := checkComment(comment); err != nil {
+// DeleteComment leaves a "[deleted]" tombstone in place of a comment owned by user
+func (c *CommentUsecase) DeleteComment(commentId int, user entity.UserModel) error {
+	comment, err := c.CommentRepository.GetCommentById(commentId)
+	if err != nil {
 		return err
 	}
 
-	if err := c.CommentRepository.CreateComment(comment); err != nil {
-		return err
+	if comment.Author != user.Username {
+		return fmt.Errorf("usecase: delete comment: %w", ErrNotAuthor)
 	}
 
-	return nil
+	if comment.Deleted {
+		return nil
+	}
+
+	return c.CommentRepository.DeleteComment(commentId)
 }
 
 func (c *CommentUsecase) GetCommentById(commentId int) (entity.Comments, error) {
@@ -99,16 +134,77 @@ func (c *CommentUsecase) GetCommentById(commentId int) (entity.Comments, error)
 		return entity.Comments{}, err
 	}
 
+	renderComment(&comment)
 	return comment, nil
 }
 
+// GetCommentsByPostId returns top level comments of the post with replies nested into them
 func (c *CommentUsecase) GetCommentsByPostId(commentId int) ([]entity.Comments, error) {
 	comments, err := c.CommentRepository.GetCommentsByPostId(commentId)
 	if err != nil {
 		return []entity.Comments{}, err
 	}
 
-	return comments, nil
+	for i := range comments {
+		renderComment(&comments[i])
+	}
+	return buildThread(comments, c.maxDepth, c.collapseAfter), nil
+}
+
+// buildThread nests comments under their parents. Replies deeper than maxDepth
+// are shown flat under their deepest visible ancestor, replies after the first
+// collapseAfter of a comment go to CollapsedReplies. Zero disables either limit.
+func buildThread(comments []entity.Comments, maxDepth, collapseAfter int) []entity.Comments {
+	exists := make(map[int]bool, len(comments))
+	for _, comment := range comments {
+		exists[comment.CommentId] = true
+	}
+
+	children := make(map[int][]entity.Comments)
+	for _, comment := range comments {
+		parent := comment.ParentId
+		if !exists[parent] {
+			parent = 0
+		}
+		children[parent] = append(children[parent], comment)
+	}
+
+	// flatten collects all descendants of id in reply order at the given depth
+	var flatten func(id, depth int) []entity.Comments
+	flatten = func(id, depth int) []entity.Comments {
+		var flat []entity.Comments
+		for _, reply := range children[id] {
+			reply.Depth = depth
+			flat = append(flat, reply)
+			flat = append(flat, flatten(reply.CommentId, depth)...)
+		}
+		return flat
+	}
+
+	var build func(id, depth int) []entity.Comments
+	build = func(id, depth int) []entity.Comments {
+		var level []entity.Comments
+		for _, comment := range children[id] {
+			comment.Depth = depth
+
+			replies := flatten(comment.CommentId, depth+1)
+			if maxDepth <= 0 || depth+1 < maxDepth {
+				replies = build(comment.CommentId, depth+1)
+			}
+
+			if collapseAfter > 0 && len(replies) > collapseAfter {
+				comment.Replies = replies[:collapseAfter]
+				comment.CollapsedReplies = replies[collapseAfter:]
+			} else {
+				comment.Replies = replies
+			}
+
+			level = append(level, comment)
+		}
+		return level
+	}
+
+	return build(0, 0)
 }
 
 func (c *CommentUsecase) CommentsLikes(postId int) (map[int][]string, error) {
@@ -129,22 +225,21 @@ func (c *CommentUsecase) CommentsDislikes(postId int) (map[int][]string, error)
 	return users, nil
 }
 
-func checkComment(comment entity.Comments) error {
-	if len(comment.Content) > 500 {
-		return fmt.Errorf("usecase:create comment: %w", ErrInvalidContentLength)
-	}
-	comment.Content = strings.Trim(comment.Content, " \n\r")
-	comment.Content = strings.Trim(comment.Content, "\t") // ???????????
-
-	for _, w := range comment.Content {
-		if (w < 32 && w > 126) && (w != 13 && w != 10) {
-			return fmt.Errorf("usecase: create comment: %w", ErrInvalidCharacter)
-		}
+// checkComment cleans the comment content, see cleanText, and checks that it
+// is present and within the limit in user-perceived characters
+func checkComment(comment *entity.Comments) error {
+	content, ok := cleanText(comment.Content, true)
+	if !ok {
+		return fmt.Errorf("usecase: create comment: %w", ErrInvalidCharacter)
 	}
 
+	comment.Content = strings.TrimSpace(content)
 	if comment.Content == "" {
 		return fmt.Errorf("usecase: create comment: %w", ErrInvalidCharacter)
 	}
+	if tooLong(comment.Content, maxCommentLength) {
+		return fmt.Errorf("usecase: create comment: %w", ErrInvalidContentLength)
+	}
 
 	return nil
 }
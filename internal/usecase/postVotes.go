@@ -1,18 +1,19 @@
 package usecase
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 
+	"forum/internal/entity"
 	"forum/internal/repository"
 )
 
+var ErrInvalidVote = errors.New("vote must be 1, 0 or -1")
+
 type PostsVoterUsecase interface {
 	GetPostDislikes(postId int) ([]string, error)
 	GetPostLikes(postId int) ([]string, error)
-	DisikePost(postId int, username string) error
-	LikePost(postId int, username string) error
+	Vote(postId int, username string, value int) (entity.VoteCount, error)
 }
 
 type PostVoterUsecase struct {
@@ -25,55 +26,24 @@ func NewPostVotesUsecase(p repository.PostVoter) *PostVoterUsecase {
 	}
 }
 
-func (p *PostVoterUsecase) LikePost(postId int, username string) error {
-	if err := p.PostVotesRepository.LikePostByUser(postId, username); err == nil {
-		if err := p.PostVotesRepository.RemoveLikePost(postId, username); err != nil {
-			return err
-		}
-		return nil
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase:like post: %w", err)
-	}
-
-	if err := p.PostVotesRepository.DislikePostByUser(postId, username); err == nil {
-		if err := p.PostVotesRepository.RemoveDislikePost(postId, username); err != nil {
-			return err
-		}
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase:dislike post: %w", err)
+// Vote sets the vote of the user on a post to value: 1 likes it, -1
+// dislikes it and 0 takes the vote back. Voting the same value again
+// changes nothing.
+func (p *PostVoterUsecase) Vote(postId int, username string, value int) (entity.VoteCount, error) {
+	if !validVote(value) {
+		return entity.VoteCount{}, fmt.Errorf("usecase: vote post: %w", ErrInvalidVote)
 	}
 
-	if err := p.PostVotesRepository.LikePost(postId, username); err != nil {
-		return err
+	count, err := p.PostVotesRepository.SetPostVote(postId, username, value)
+	if err != nil {
+		return entity.VoteCount{}, fmt.Errorf("usecase: vote post: %w", err)
 	}
 
-	return nil
+	return count, nil
 }
 
-func (p *PostVoterUsecase) DisikePost(postId int, username string) error {
-	if err := p.PostVotesRepository.DislikePostByUser(postId, username); err == nil {
-
-		if err := p.PostVotesRepository.RemoveDislikePost(postId, username); err != nil {
-			return err
-		}
-		return nil
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase: like post: %w", err)
-	}
-
-	if err := p.PostVotesRepository.LikePostByUser(postId, username); err == nil {
-		if err := p.PostVotesRepository.RemoveLikePost(postId, username); err != nil {
-			return err
-		}
-	} else if !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("usecase: dislike post: %w", err)
-	}
-
-	if err := p.PostVotesRepository.DislikePost(postId, username); err != nil {
-		return err
-	}
-
-	return nil
+func validVote(value int) bool {
+	return value == entity.VoteUp || value == entity.VoteNone || value == entity.VoteDown
 }
 
 func (p *PostVoterUsecase) GetPostLikes(postId int) ([]string, error) {
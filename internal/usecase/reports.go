@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"forum/internal/entity"
+	"forum/internal/repository"
+)
+
+var (
+	ErrInvalidReport   = errors.New("invalid report")
+	ErrAlreadyReported = errors.New("already reported")
+)
+
+const maxReportDetails = 500
+
+type ReportsUsecase interface {
+	CreateReport(report entity.Report) error
+	GetReportQueue(moderator entity.UserModel) ([]entity.ReportGroup, error)
+	ResolveReports(moderator entity.UserModel, targetType string, targetId int, deleteContent bool) error
+	DismissReports(moderator entity.UserModel, targetType string, targetId int) error
+}
+
+type ReportUsecase struct {
+	reports   repository.Reports
+	posts     repository.Posts
+	comments  repository.Commenter
+	moderator *ModeratorUsecase
+}
+
+func NewReportUsecase(r *repository.Repository, m *ModeratorUsecase) *ReportUsecase {
+	return &ReportUsecase{
+		reports:   r.Reports,
+		posts:     r.Posts,
+		comments:  r.Commenter,
+		moderator: m,
+	}
+}
+
+// CreateReport flags a post or a comment, a reader has at most one open report per target
+func (r *ReportUsecase) CreateReport(report entity.Report) error {
+	if !entity.ValidReportReason(report.Reason) {
+		return fmt.Errorf("usecase: create report: reason: %w", ErrInvalidReport)
+	}
+
+	report.Details = strings.TrimSpace(report.Details)
+	if len(report.Details) > maxReportDetails {
+		return fmt.Errorf("usecase: create report: details: %w", ErrInvalidContentLength)
+	}
+
+	switch report.TargetType {
+	case entity.ReportTargetPost:
+		if _, err := r.posts.GetPostbyId(report.TargetId); err != nil {
+			return err
+		}
+	case entity.ReportTargetComment:
+		comment, err := r.comments.GetCommentById(report.TargetId)
+		if err != nil {
+			return err
+		}
+		if comment.Deleted {
+			return fmt.Errorf("usecase: create report: %w", ErrCommentDeleted)
+		}
+	default:
+		return fmt.Errorf("usecase: create report: target: %w", ErrInvalidReport)
+	}
+
+	reported, err := r.reports.HasOpenReport(report.Reporter, report.TargetType, report.TargetId)
+	if err != nil {
+		return err
+	}
+	if reported {
+		return fmt.Errorf("usecase: create report: %w", ErrAlreadyReported)
+	}
+
+	return r.reports.CreateReport(report)
+}
+
+// GetReportQueue groups open reports by reported content, most reported first
+func (r *ReportUsecase) GetReportQueue(moderator entity.UserModel) ([]entity.ReportGroup, error) {
+	if !moderator.Can(entity.PermHandleReports) {
+		return nil, fmt.Errorf("usecase: report queue: %w", ErrForbidden)
+	}
+
+	reports, err := r.reports.GetOpenReports()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []entity.ReportGroup
+	index := make(map[string]int)
+	for _, report := range reports {
+		key := report.TargetType + ":" + strconv.Itoa(report.TargetId)
+		i, ok := index[key]
+		if !ok {
+			groups = append(groups, entity.ReportGroup{
+				TargetType: report.TargetType,
+				TargetId:   report.TargetId,
+				PostId:     report.PostId,
+				Preview:    report.Preview,
+			})
+			i = len(groups) - 1
+			index[key] = i
+		}
+
+		group := &groups[i]
+		group.Count++
+		group.Reports = append(group.Reports, report)
+
+		counted := false
+		for j := range group.Reasons {
+			if group.Reasons[j].Reason == report.Reason {
+				group.Reasons[j].Count++
+				counted = true
+				break
+			}
+		}
+		if !counted {
+			group.Reasons = append(group.Reasons, entity.ReasonCount{Reason: report.Reason, Count: 1})
+		}
+	}
+
+	// stable sort keeps the oldest report first among groups with the same count
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+
+	return groups, nil
+}
+
+// ResolveReports closes the open reports of the target as handled,
+// optionally deleting the reported content. The reports are closed first,
+// deleting would close them without the moderator.
+func (r *ReportUsecase) ResolveReports(moderator entity.UserModel, targetType string, targetId int, deleteContent bool) error {
+	if !moderator.Can(entity.PermHandleReports) {
+		return fmt.Errorf("usecase: resolve reports: %w", ErrForbidden)
+	}
+	if targetType != entity.ReportTargetPost && targetType != entity.ReportTargetComment {
+		return fmt.Errorf("usecase: resolve reports: target: %w", ErrInvalidReport)
+	}
+
+	if err := r.close(moderator, targetType, targetId, entity.ReportResolved, entity.AuditResolveReport); err != nil {
+		return err
+	}
+
+	if deleteContent {
+		var err error
+		switch targetType {
+		case entity.ReportTargetPost:
+			err = r.moderator.DeletePost(moderator, targetId)
+		case entity.ReportTargetComment:
+			err = r.moderator.DeleteComment(moderator, targetId)
+		}
+		// content may already be gone
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DismissReports closes the open reports of the target without action
+func (r *ReportUsecase) DismissReports(moderator entity.UserModel, targetType string, targetId int) error {
+	if !moderator.Can(entity.PermHandleReports) {
+		return fmt.Errorf("usecase: dismiss reports: %w", ErrForbidden)
+	}
+
+	return r.close(moderator, targetType, targetId, entity.ReportDismissed, entity.AuditDismissReport)
+}
+
+func (r *ReportUsecase) close(moderator entity.UserModel, targetType string, targetId int, status, action string) error {
+	// the repository fills in the number of closed reports
+	entry := auditEntry(moderator, action, targetType, strconv.Itoa(targetId), "")
+	if _, err := r.reports.CloseReports(targetType, targetId, status, entry); err != nil {
+		return err
+	}
+
+	return nil
+}
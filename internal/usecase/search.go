@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"strings"
+	"unicode"
+
+	"forum/internal/entity"
+)
+
+const searchLimit = 50
+
+// Search parses raw and returns matching posts and comments, an empty query finds nothing
+func (pu *PostUseCase) Search(raw string) ([]entity.SearchResult, error) {
+	// stored text is NFC, the query has to be too for its words to match
+	raw, ok := cleanText(raw, false)
+	if !ok {
+		return nil, nil
+	}
+
+	query := ParseSearchQuery(raw)
+	if query.IsEmpty() {
+		return nil, nil
+	}
+
+	return pu.PostRepository.Search(query, searchLimit)
+}
+
+// ParseSearchQuery splits raw into words, "quoted phrases" and the
+// author:name and category:name filters, filter values may be quoted too.
+// Words without letters or digits are dropped.
+func ParseSearchQuery(raw string) entity.SearchQuery {
+	query := entity.SearchQuery{Raw: raw}
+
+	rest := strings.TrimSpace(raw)
+	for rest != "" {
+		var token string
+		quoted := false
+		token, rest, quoted = nextSearchToken(rest)
+
+		switch {
+		case quoted:
+			if hasWordChars(token) {
+				query.Phrases = append(query.Phrases, token)
+			}
+		case strings.HasPrefix(token, "author:"):
+			query.Author = strings.TrimPrefix(token, "author:")
+		case strings.HasPrefix(token, "category:"):
+			query.Category = strings.TrimPrefix(token, "category:")
+		default:
+			if hasWordChars(token) {
+				query.Terms = append(query.Terms, token)
+			}
+		}
+
+		rest = strings.TrimSpace(rest)
+	}
+
+	return query
+}
+
+// nextSearchToken cuts the first token off s, a token is a "quoted phrase",
+// a key:"quoted value" or a run of non-space characters
+func nextSearchToken(s string) (token, rest string, quoted bool) {
+	if strings.HasPrefix(s, `"`) {
+		end := strings.Index(s[1:], `"`)
+		if end < 0 {
+			return s[1:], "", true
+		}
+		return s[1 : end+1], s[end+2:], true
+	}
+
+	end := strings.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		end = len(s)
+	}
+
+	if colon := strings.Index(s[:end], `:"`); colon >= 0 {
+		value, rest, _ := nextSearchToken(s[colon+1:])
+		return s[:colon+1] + value, rest, false
+	}
+
+	return s[:end], s[end:], false
+}
+
+func hasWordChars(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
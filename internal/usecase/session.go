@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"forum/internal/entity"
+)
+
+var ErrSessionNotFound = errors.New("session is not found")
+
+const (
+	// lastSeenInterval limits how often a session in use is written back
+	lastSeenInterval = time.Minute
+	maxUserAgent     = 256
+)
+
+// startSession gives a user who proved who they are a new session token,
+// sessions on other devices stay signed in
+func (u *AuthUserUse) startSession(user entity.UserModel, client entity.Client) (entity.UserModel, error) {
+	if user.SuspendedUntil.After(time.Now()) {
+		return entity.UserModel{}, fmt.Errorf("usecase: create token: %w", ErrUserSuspended)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return entity.UserModel{}, fmt.Errorf("usecase: create token: %w", err)
+	}
+
+	now := time.Now()
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+		for !utf8.ValidString(userAgent) {
+			userAgent = userAgent[:len(userAgent)-1]
+		}
+	}
+	session := entity.Session{
+		TokenHash: hashToken(token),
+		UserId:    user.UserId,
+		CreatedAt: now,
+		ExpiresAt: now.Add(u.sessionTTL),
+		UserAgent: userAgent,
+		IP:        client.IP,
+	}
+	if err := u.Repository.CreateSession(session); err != nil {
+		return entity.UserModel{}, fmt.Errorf("usecase: create token: %w", err)
+	}
+
+	user.Token = token
+	user.ExpirationTime = session.ExpiresAt
+	return user, nil
+}
+
+// ParseToken returns the user signed in with the session token. A session
+// in use slides its expiration forward, but not past sessionMax after the
+// sign-in, renewed reports that the expiration moved.
+func (u *AuthUserUse) ParseToken(token string) (user entity.UserModel, renewed bool, err error) {
+	user, session, err := u.Repository.GetUserBySession(hashToken(token))
+	if err != nil {
+		return entity.UserModel{}, false, fmt.Errorf("usecase: parse token: %w", err)
+	}
+	user.Token = token
+	user.SessionId = session.SessionId
+	user.ExpirationTime = session.ExpiresAt
+
+	now := time.Now()
+	if session.ExpiresAt.After(now) && now.Sub(session.LastSeenAt) > lastSeenInterval {
+		expires := now.Add(u.sessionTTL)
+		if limit := session.CreatedAt.Add(u.sessionMax); expires.After(limit) {
+			expires = limit
+		}
+		if err := u.Repository.TouchSession(session.SessionId, now, expires); err != nil {
+			return entity.UserModel{}, false, fmt.Errorf("usecase: parse token: %w", err)
+		}
+		renewed = expires.After(session.ExpiresAt)
+		user.ExpirationTime = expires
+	}
+
+	return user, renewed, nil
+}
+
+// DeleteToken ends the session of the token
+func (u *AuthUserUse) DeleteToken(token string) error {
+	return u.Repository.DeleteSession(hashToken(token))
+}
+
+// GetSessions lists the active sessions of user and marks the one in use
+func (u *AuthUserUse) GetSessions(user entity.UserModel) ([]entity.Session, error) {
+	sessions, err := u.Repository.GetSessions(user.UserId, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("usecase: get sessions: %w", err)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SessionId == user.SessionId
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs one of the user's sessions out
+func (u *AuthUserUse) RevokeSession(user entity.UserModel, sessionId int) error {
+	if err := u.Repository.DeleteUserSession(user.UserId, sessionId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("usecase: revoke session: %w", ErrSessionNotFound)
+		}
+		return fmt.Errorf("usecase: revoke session: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllSessions signs the user out on every device, this one included
+func (u *AuthUserUse) RevokeAllSessions(user entity.UserModel) error {
+	if err := u.Repository.DeleteUserSessions(user.UserId); err != nil {
+		return fmt.Errorf("usecase: revoke all sessions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxTitleLength   = 100
+	maxContentLength = 2000
+	maxCommentLength = 500
+
+	// maxGraphemeBytes bounds the bytes a limited text may spend per
+	// character, so stacked combining marks cannot make a short text huge
+	maxGraphemeBytes = 32
+)
+
+// cleanText normalizes user text to NFC, turns CRLF into LF and removes
+// control characters, byte order marks and the bidi embedding, override and
+// isolate characters that can make text display in a different order than it
+// is stored. Newlines and tabs are kept only in multiline text.
+// ok is false when text is not valid UTF-8.
+func cleanText(text string, multiline bool) (cleaned string, ok bool) {
+	if !utf8.ValidString(text) {
+		return "", false
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			if multiline {
+				return r
+			}
+			return ' '
+		case unicode.IsControl(r), isBidiControl(r), r == '\uFEFF':
+			return -1
+		}
+		return r
+	}, text)
+
+	return norm.NFC.String(text), true
+}
+
+// isBidiControl reports the explicit directional formatting characters,
+// implicit marks such as U+200F RIGHT-TO-LEFT MARK are left alone
+func isBidiControl(r rune) bool {
+	return (r >= '\u202A' && r <= '\u202E') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// textLength counts user-perceived characters, so an emoji with modifiers or
+// a letter with combining accents is one character
+func textLength(text string) int {
+	return uniseg.GraphemeClusterCount(text)
+}
+
+// tooLong reports whether text is longer than limit user-perceived characters
+func tooLong(text string, limit int) bool {
+	return len(text) > limit*maxGraphemeBytes || textLength(text) > limit
+}
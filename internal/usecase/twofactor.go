@@ -0,0 +1,327 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"forum/internal/entity"
+	"forum/pkg/totp"
+)
+
+var (
+	ErrTwoFactorRequired = errors.New("two-factor code required")
+	ErrInvalidCode       = errors.New("invalid two-factor code")
+	ErrTwoFactorEnabled  = errors.New("two-factor authentication is already on")
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is off")
+)
+
+const (
+	// twoFactorChallengeTTL is how long the code may be entered after the password
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+	// totpSkew accepts the codes of one period before and after the current one
+	totpSkew = 1
+)
+
+// TwoFactorError is returned by a sign-in whose password was right when the
+// user has two-factor authentication on, Challenge is handed back to
+// CompleteTwoFactor with the code
+type TwoFactorError struct {
+	Challenge string
+}
+
+func (e *TwoFactorError) Error() string {
+	return ErrTwoFactorRequired.Error()
+}
+
+func (e *TwoFactorError) Unwrap() error {
+	return ErrTwoFactorRequired
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactorStatus tells whether two-factor authentication is on and, while
+// it is being set up, the secret for the authenticator app
+func (u *AuthUserUse) TwoFactorStatus(user entity.UserModel) (entity.TwoFactorStatus, error) {
+	tf, err := u.Repository.GetTwoFactor(user.UserId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.TwoFactorStatus{}, nil
+		}
+		return entity.TwoFactorStatus{}, err
+	}
+
+	if !tf.Enabled() {
+		return u.setupStatus(user, tf.Secret), nil
+	}
+
+	count, err := u.Repository.CountRecoveryCodes(user.UserId)
+	if err != nil {
+		return entity.TwoFactorStatus{}, err
+	}
+
+	return entity.TwoFactorStatus{Enabled: true, RecoveryCodes: count}, nil
+}
+
+// BeginTwoFactor makes a new secret for the user to add to an authenticator
+// app, it is used once EnableTwoFactor gets a code of it
+func (u *AuthUserUse) BeginTwoFactor(user entity.UserModel) (entity.TwoFactorStatus, error) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return entity.TwoFactorStatus{}, fmt.Errorf("usecase: begin two factor: %w", err)
+	}
+
+	if err := u.Repository.SaveTwoFactorSecret(user.UserId, secret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.TwoFactorStatus{}, fmt.Errorf("usecase: begin two factor: %w", ErrTwoFactorEnabled)
+		}
+		return entity.TwoFactorStatus{}, err
+	}
+
+	return u.setupStatus(user, secret), nil
+}
+
+// EnableTwoFactor turns two-factor authentication on once the user proves the
+// app has the secret and returns the recovery codes, they are not shown again
+func (u *AuthUserUse) EnableTwoFactor(user entity.UserModel, code string) ([]string, error) {
+	tf, err := u.Repository.GetTwoFactor(user.UserId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("usecase: enable two factor: %w", ErrTwoFactorDisabled)
+		}
+		return nil, err
+	}
+	if tf.Enabled() {
+		return nil, fmt.Errorf("usecase: enable two factor: %w", ErrTwoFactorEnabled)
+	}
+
+	now := u.now()
+	step, ok := totp.Validate(tf.Secret, normalizeCode(code), now, totpSkew)
+	if !ok {
+		return nil, fmt.Errorf("usecase: enable two factor: %w", ErrInvalidCode)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("usecase: enable two factor: %w", err)
+	}
+
+	if err := u.Repository.ConfirmTwoFactor(user.UserId, step, now, hashes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("usecase: enable two factor: %w", ErrTwoFactorEnabled)
+		}
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off with a code from the
+// app or a recovery code, a setup that was not finished is dropped without one.
+// wrong codes count like those of a sign-in.
+func (u *AuthUserUse) DisableTwoFactor(user entity.UserModel, code string, client entity.Client) error {
+	tf, err := u.Repository.GetTwoFactor(user.UserId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("usecase: disable two factor: %w", ErrTwoFactorDisabled)
+		}
+		return err
+	}
+
+	if tf.Enabled() {
+		if err := u.checkLimitedCode(tf, user, code, client); err != nil {
+			return err
+		}
+	}
+
+	return u.Repository.DeleteTwoFactor(user.UserId)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user, a code
+// from the app or one of the old recovery codes is needed. wrong codes count
+// like those of a sign-in.
+func (u *AuthUserUse) RegenerateRecoveryCodes(user entity.UserModel, code string, client entity.Client) ([]string, error) {
+	tf, err := u.Repository.GetTwoFactor(user.UserId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if !tf.Enabled() {
+		return nil, fmt.Errorf("usecase: regenerate recovery codes: %w", ErrTwoFactorDisabled)
+	}
+
+	if err := u.checkLimitedCode(tf, user, code, client); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("usecase: regenerate recovery codes: %w", err)
+	}
+
+	if err := u.Repository.ReplaceRecoveryCodes(user.UserId, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CompleteTwoFactor finishes a sign-in that was answered with a
+// TwoFactorError, wrong codes count against the username and address like
+// wrong passwords do
+func (u *AuthUserUse) CompleteTwoFactor(challenge, code string, client entity.Client) (entity.UserModel, error) {
+	if challenge == "" {
+		return entity.UserModel{}, fmt.Errorf("usecase: complete two factor: %w", ErrInvalidToken)
+	}
+
+	t, err := u.Repository.GetUserToken(hashToken(challenge), entity.TokenTwoFactor, u.now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.UserModel{}, fmt.Errorf("usecase: complete two factor: %w", ErrInvalidToken)
+		}
+		return entity.UserModel{}, err
+	}
+
+	user, err := u.Repository.GetUserById(t.UserId)
+	if err != nil {
+		return entity.UserModel{}, fmt.Errorf("usecase: complete two factor: %w", ErrUserNotFound)
+	}
+
+	tf, err := u.Repository.GetTwoFactor(user.UserId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return entity.UserModel{}, err
+	}
+	// an administrator may have reset it since the password was checked
+	if tf.Enabled() {
+		if err := u.checkLimitedCode(tf, user, code, client); err != nil {
+			return entity.UserModel{}, err
+		}
+	}
+
+	if _, err := u.consumeToken(challenge, entity.TokenTwoFactor); err != nil {
+		return entity.UserModel{}, err
+	}
+
+	return u.startSession(user, client)
+}
+
+// checkLimitedCode is checkTwoFactorCode behind the rate limit of the
+// two-factor step, wrong codes count against the username and the address
+// of the client and a right one clears the username
+func (u *AuthUserUse) checkLimitedCode(tf entity.TwoFactor, user entity.UserModel, code string, client entity.Client) error {
+	keys := limitKeys("two-factor", user.Username, client)
+	if err := u.allow(keys); err != nil {
+		return err
+	}
+
+	if err := u.checkTwoFactorCode(tf, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			u.failed(keys)
+		}
+		return err
+	}
+
+	if err := u.limiter.Reset(keys[1]); err != nil {
+		log.Printf("usecase: rate limit: reset %s: %v", keys[1], err)
+	}
+	return nil
+}
+
+// twoFactorChallenge returns a *TwoFactorError when user has to enter a code
+// before the sign-in is complete, nil when two-factor authentication is off
+func (u *AuthUserUse) twoFactorChallenge(user entity.UserModel) error {
+	tf, err := u.Repository.GetTwoFactor(user.UserId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if !tf.Enabled() {
+		return nil
+	}
+
+	challenge, err := randomToken()
+	if err != nil {
+		return fmt.Errorf("usecase: two factor challenge: %w", err)
+	}
+
+	now := u.now()
+	err = u.Repository.SaveUserToken(entity.UserToken{
+		TokenHash: hashToken(challenge),
+		UserId:    user.UserId,
+		Purpose:   entity.TokenTwoFactor,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(twoFactorChallengeTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return &TwoFactorError{Challenge: challenge}
+}
+
+// checkTwoFactorCode accepts a code of the app that was not used before or
+// an unused recovery code, which is then used up
+func (u *AuthUserUse) checkTwoFactorCode(tf entity.TwoFactor, code string) error {
+	code = normalizeCode(code)
+	now := u.now()
+
+	if len(code) == totp.Digits && strings.Trim(code, "0123456789") == "" {
+		step, ok := totp.Validate(tf.Secret, code, now, totpSkew)
+		if !ok {
+			return fmt.Errorf("usecase: check two factor code: %w", ErrInvalidCode)
+		}
+		if err := u.Repository.UseTwoFactorStep(tf.UserId, step); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("usecase: check two factor code: used already: %w", ErrInvalidCode)
+			}
+			return err
+		}
+		return nil
+	}
+
+	if code == "" {
+		return fmt.Errorf("usecase: check two factor code: %w", ErrInvalidCode)
+	}
+	if err := u.Repository.UseRecoveryCode(tf.UserId, hashToken(code), now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("usecase: check two factor code: %w", ErrInvalidCode)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (u *AuthUserUse) setupStatus(user entity.UserModel, secret string) entity.TwoFactorStatus {
+	return entity.TwoFactorStatus{
+		Secret: secret,
+		URI:    totp.URI(u.totpIssuer, user.Username, secret),
+	}
+}
+
+// normalizeCode drops the spaces and dashes people type into codes
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+// newRecoveryCodes returns recovery codes to show as "xxxxx-xxxxx" and the
+// hashes to store
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(raw))[:10]
+
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	return codes, hashes, nil
+}
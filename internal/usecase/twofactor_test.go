@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"forum/internal/entity"
+	"forum/internal/repository"
+	"forum/pkg/totp"
+)
+
+// testSecret is the SHA1 key of the RFC 6238 test vectors
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// twoFactorRepository keeps the steps and recovery codes of one user the way
+// the two_factor and recovery_codes tables do, the other methods are not used
+type twoFactorRepository struct {
+	repository.Authorization
+	tf   entity.TwoFactor
+	used map[string]bool
+}
+
+func newTwoFactorRepository(codes ...string) *twoFactorRepository {
+	r := &twoFactorRepository{
+		tf: entity.TwoFactor{
+			UserId:      1,
+			Secret:      testSecret,
+			ConfirmedAt: time.Unix(0, 0),
+		},
+		used: map[string]bool{},
+	}
+	for _, code := range codes {
+		r.used[hashToken(normalizeCode(code))] = false
+	}
+	return r
+}
+
+func (r *twoFactorRepository) UseTwoFactorStep(userId int, step int64) error {
+	if userId != r.tf.UserId || step <= r.tf.LastStep {
+		return sql.ErrNoRows
+	}
+	r.tf.LastStep = step
+	return nil
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(userId int, codeHash string, now time.Time) error {
+	used, ok := r.used[codeHash]
+	if userId != r.tf.UserId || !ok || used {
+		return sql.ErrNoRows
+	}
+	r.used[codeHash] = true
+	return nil
+}
+
+func TestCheckTwoFactorCode(t *testing.T) {
+	start := time.Unix(1111111111, 0)
+	step := totp.Step(start)
+	code := func(offset int64) string {
+		c, err := totp.Code(testSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// every case runs its attempts in order against a fresh repository
+	type attempt struct {
+		at      time.Time
+		code    string
+		wantErr bool
+	}
+	tests := []struct {
+		name     string
+		attempts []attempt
+	}{
+		{"current code", []attempt{
+			{start, code(0), false},
+		}},
+		{"code typed with a space", []attempt{
+			{start, code(0)[:3] + " " + code(0)[3:], false},
+		}},
+		{"same code twice", []attempt{
+			{start, code(0), false},
+			{start, code(0), true},
+		}},
+		{"same code in the next period", []attempt{
+			{start, code(0), false},
+			{start.Add(totp.Period), code(0), true},
+		}},
+		{"next code after one was used", []attempt{
+			{start, code(0), false},
+			{start.Add(totp.Period), code(1), false},
+		}},
+		{"earlier code after a later one", []attempt{
+			{start, code(1), false},
+			{start, code(0), true},
+			{start, code(-1), true},
+		}},
+		{"code out of the skew", []attempt{
+			{start, code(2), true},
+			{start, code(-2), true},
+		}},
+		{"recovery code", []attempt{
+			{start, "abcde-fghij", false},
+		}},
+		{"recovery code twice", []attempt{
+			{start, "abcde-fghij", false},
+			{start, "abcde-fghij", true},
+			{start, "ABCDEFGHIJ", true},
+		}},
+		{"other recovery code after one was used", []attempt{
+			{start, "abcde-fghij", false},
+			{start, "klmno-pqrst", false},
+		}},
+		{"unknown recovery code", []attempt{
+			{start, "zzzzz-zzzzz", true},
+		}},
+		{"empty code", []attempt{
+			{start, "", true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTwoFactorRepository("abcde-fghij", "klmno-pqrst")
+			for i, a := range tt.attempts {
+				at := a.at
+				u := &AuthUserUse{
+					Repository: repo,
+					now:        func() time.Time { return at },
+				}
+				err := u.checkTwoFactorCode(repo.tf, a.code)
+				if a.wantErr {
+					if !errors.Is(err, ErrInvalidCode) {
+						t.Fatalf("attempt %d with %q: error = %v, want %v", i, a.code, err, ErrInvalidCode)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("attempt %d with %q: %v", i, a.code, err)
+				}
+			}
+		})
+	}
+}
@@ -4,60 +4,76 @@ import (
 	"errors"
 	"strings"
 
+	"forum/config"
 	"forum/internal/entity"
 	"forum/internal/repository"
 )
 
 type UsersUsecase interface {
 	GetUserByName(username string) (entity.UserModel, error)
-	GetPostsByName(username string, query map[string][]string) ([]entity.Post, error)
+	GetPostsByName(username string, query map[string][]string) (entity.PostPage, error)
 }
 
 type UserUsecase struct {
-	ur repository.User
+	ur       repository.User
+	pageSize int
 }
 
-func NewUserUsecase(r repository.User) *UserUsecase {
+func NewUserUsecase(r repository.User, cnf *config.Config) *UserUsecase {
+	pageSize := cnf.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
 	return &UserUsecase{
-		r,
+		ur:       r,
+		pageSize: pageSize,
 	}
 }
 
 var ErrInvalidQuery = errors.New("invalid query")
 
-func (u *UserUsecase) GetPostsByName(username string, query map[string][]string) ([]entity.Post, error) {
-	var posts []entity.Post
-	var err error
-
+// GetPostsByName lists one page of the posts a user created, liked, disliked or commented
+func (u *UserUsecase) GetPostsByName(username string, query map[string][]string) (entity.PostPage, error) {
 	search, ok := query["posts"]
 	if !ok {
-		return nil, ErrInvalidQuery
+		return entity.PostPage{}, ErrInvalidQuery
 	}
 
+	page, err := pageFromQuery(query, u.pageSize)
+	if err != nil {
+		return entity.PostPage{}, err
+	}
+
+	var list func(username string, page entity.PageRequest) ([]entity.Post, error)
 	switch strings.Join(search, "") {
 	case "created":
-		posts, err = u.ur.GetPostsByName(username)
+		list = u.ur.GetPostsByName
 	case "liked":
-		posts, err = u.ur.GetLikedPostsByName(username)
+		list = u.ur.GetLikedPostsByName
 	case "disliked":
-		posts, err = u.ur.GetDislikedPostsByName(username)
+		list = u.ur.GetDislikedPostsByName
 	case "commented":
-		posts, err = u.ur.GetCommentedPostsByName(username)
+		list = u.ur.GetCommentedPostsByName
 	default:
-		return nil, err
+		return entity.PostPage{}, nil
 	}
+
+	result, err := fetchPage(page, entity.OrderOldest, func(page entity.PageRequest) ([]entity.Post, error) {
+		return list(username, page)
+	})
 	if err != nil {
-		return nil, err
+		return entity.PostPage{}, err
 	}
 
-	for i := range posts {
-		category, err := u.ur.GetAllCategoriesByPostId(posts[i].PostId)
+	for i := range result.Posts {
+		category, err := u.ur.GetAllCategoriesByPostId(result.Posts[i].PostId)
 		if err != nil {
-			return nil, err
+			return entity.PostPage{}, err
 		}
-		posts[i].Category = category
+		result.Posts[i].Category = category
 	}
-	return posts, nil
+	return result, nil
 }
 
 func (u *UserUsecase) GetUserByName(username string) (entity.UserModel, error) {
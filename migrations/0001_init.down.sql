@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS dislikes;
+DROP TABLE IF EXISTS likes;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts_category;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS user;
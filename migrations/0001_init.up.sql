@@ -52,4 +52,4 @@ CREATE TABLE IF NOT EXISTS dislikes(
     	commentsId INTEGER DEFAULT NULL,
     	FOREIGN KEY (postId) REFERENCES posts(postId) ON DELETE CASCADE,
 		FOREIGN KEY (commentsId) REFERENCES comments(commentsId) ON DELETE CASCADE
-);
\ No newline at end of file
+);
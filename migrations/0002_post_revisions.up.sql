@@ -0,0 +1,11 @@
+ALTER TABLE posts ADD COLUMN updatedAt DATETIME DEFAULT NULL;
+
+CREATE TABLE IF NOT EXISTS post_revisions(
+		revisionId INTEGER PRIMARY KEY AUTOINCREMENT,
+		postId INTEGER,
+		title TEXT,
+		content TEXT,
+		categories TEXT,
+		editedAt DATETIME DEFAULT (datetime('now')),
+		FOREIGN KEY (postId) REFERENCES posts(postId) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN updatedAt;
+
+ALTER TABLE comments DROP COLUMN deleted;
@@ -0,0 +1,3 @@
+ALTER TABLE comments ADD COLUMN deleted INTEGER DEFAULT 0;
+
+ALTER TABLE comments ADD COLUMN updatedAt DATETIME DEFAULT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE comments ADD COLUMN parentId INTEGER DEFAULT NULL REFERENCES comments(commentsId);
+
+CREATE INDEX IF NOT EXISTS comments_parent_idx ON comments(parentId);
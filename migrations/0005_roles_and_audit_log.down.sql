@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS audit_log;
+
+ALTER TABLE posts DROP COLUMN locked;
+
+ALTER TABLE user DROP COLUMN suspendedUntil;
+
+ALTER TABLE user DROP COLUMN role;
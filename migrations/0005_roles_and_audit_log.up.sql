@@ -0,0 +1,15 @@
+ALTER TABLE user ADD COLUMN role TEXT DEFAULT 'user';
+
+ALTER TABLE user ADD COLUMN suspendedUntil DATETIME DEFAULT NULL;
+
+ALTER TABLE posts ADD COLUMN locked INTEGER DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS audit_log(
+		auditId INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT,
+		action TEXT,
+		targetType TEXT,
+		targetId TEXT,
+		details TEXT DEFAULT '',
+		createdAt DATETIME DEFAULT (datetime('now'))
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reports_target_idx;
+
+DROP TABLE IF EXISTS reports;
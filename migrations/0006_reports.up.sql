@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reports(
+		reportId INTEGER PRIMARY KEY AUTOINCREMENT,
+		reporter TEXT,
+		targetType TEXT,
+		targetId INTEGER,
+		reason TEXT,
+		details TEXT DEFAULT '',
+		status TEXT DEFAULT 'open',
+		resolvedBy TEXT DEFAULT NULL,
+		createdAt DATETIME DEFAULT (datetime('now')),
+		resolvedAt DATETIME DEFAULT NULL,
+		FOREIGN KEY (reporter) REFERENCES user(username)
+);
+
+CREATE INDEX IF NOT EXISTS reports_target_idx ON reports(status, targetType, targetId);
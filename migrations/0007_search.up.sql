@@ -0,0 +1,42 @@
+-- full-text indexes over posts and comments, requires the sqlite_fts5 build tag
+CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(
+		title,
+		content,
+		content = 'posts',
+		content_rowid = 'postId'
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(
+		content,
+		content = 'comments',
+		content_rowid = 'commentsId'
+);
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO posts_fts (rowid, title, content) VALUES (new.postId, new.title, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_delete AFTER DELETE ON posts BEGIN
+		INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.postId, old.title, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+		INSERT INTO posts_fts (posts_fts, rowid, title, content) VALUES ('delete', old.postId, old.title, old.content);
+		INSERT INTO posts_fts (rowid, title, content) VALUES (new.postId, new.title, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_fts_insert AFTER INSERT ON comments BEGIN
+		INSERT INTO comments_fts (rowid, content) VALUES (new.commentsId, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_fts_delete AFTER DELETE ON comments BEGIN
+		INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.commentsId, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_fts_update AFTER UPDATE OF content ON comments BEGIN
+		INSERT INTO comments_fts (comments_fts, rowid, content) VALUES ('delete', old.commentsId, old.content);
+		INSERT INTO comments_fts (rowid, content) VALUES (new.commentsId, new.content);
+END;
+
+INSERT INTO posts_fts (posts_fts) VALUES ('rebuild');
+INSERT INTO comments_fts (comments_fts) VALUES ('rebuild');
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS posts_author_idx;
+DROP INDEX IF EXISTS posts_dislikes_idx;
+DROP INDEX IF EXISTS posts_likes_idx;
+DROP INDEX IF EXISTS posts_creation_idx;
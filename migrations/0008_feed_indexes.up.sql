@@ -0,0 +1,5 @@
+-- keyset pagination walks these indexes instead of sorting whole tables
+CREATE INDEX IF NOT EXISTS posts_creation_idx ON posts(creationDate, postId);
+CREATE INDEX IF NOT EXISTS posts_likes_idx ON posts(likes, postId);
+CREATE INDEX IF NOT EXISTS posts_dislikes_idx ON posts(dislikes, postId);
+CREATE INDEX IF NOT EXISTS posts_author_idx ON posts(author);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS posts_category_category_idx;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS categories(
+		categoryId INTEGER PRIMARY KEY AUTOINCREMENT,
+		slug TEXT UNIQUE,
+		name TEXT UNIQUE,
+		description TEXT DEFAULT '',
+		position INTEGER DEFAULT 0
+);
+
+INSERT OR IGNORE INTO categories (slug, name, description, position) VALUES
+		('hobby', 'Hobby', 'Things you do for fun', 1),
+		('travel', 'Travel', 'Trips, places and travel advice', 2),
+		('education', 'Education', 'Learning, courses and schools', 3),
+		('sport', 'Sport', 'Games, teams and training', 4),
+		('programming', 'Programming', 'Code, tools and careers in software', 5);
+
+CREATE INDEX IF NOT EXISTS posts_category_category_idx ON posts_category(category);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS attachments(
+		attachmentId INTEGER PRIMARY KEY AUTOINCREMENT,
+		postId INTEGER,
+		filename TEXT,
+		thumbnail TEXT,
+		originalName TEXT DEFAULT '',
+		contentType TEXT,
+		size INTEGER,
+		width INTEGER,
+		height INTEGER,
+		createdAt DATETIME DEFAULT (datetime('now')),
+		FOREIGN KEY (postId) REFERENCES posts(postId) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS attachments_post_idx ON attachments(postId);
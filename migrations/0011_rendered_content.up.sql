@@ -0,0 +1,2 @@
+ALTER TABLE posts ADD COLUMN contentHtml TEXT DEFAULT '';
+ALTER TABLE comments ADD COLUMN contentHtml TEXT DEFAULT '';
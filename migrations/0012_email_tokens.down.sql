@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS user_tokens_user_idx;
+
+DROP TABLE IF EXISTS user_tokens;
+
+ALTER TABLE user DROP COLUMN emailVerified;
@@ -0,0 +1,14 @@
+ALTER TABLE user ADD COLUMN emailVerified INTEGER DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS user_tokens(
+		tokenHash TEXT PRIMARY KEY,
+		userId INTEGER,
+		purpose TEXT,
+		email TEXT,
+		createdAt DATETIME DEFAULT (datetime('now')),
+		expiresAt DATETIME,
+		usedAt DATETIME DEFAULT NULL,
+		FOREIGN KEY (userId) REFERENCES user(userId) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_idx ON user_tokens(userId, purpose);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS user_identities_user_idx;
+
+DROP TABLE IF EXISTS user_identities;
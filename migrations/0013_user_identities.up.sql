@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_identities(
+		identityId INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER,
+		provider TEXT,
+		subject TEXT,
+		email TEXT DEFAULT '',
+		createdAt DATETIME DEFAULT (datetime('now')),
+		UNIQUE (provider, subject),
+		FOREIGN KEY (userId) REFERENCES user(userId) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_idx ON user_identities(userId);
@@ -0,0 +1,6 @@
+ALTER TABLE user ADD COLUMN token TEXT DEFAULT NULL;
+ALTER TABLE user ADD COLUMN expiresAt DATETIME DEFAULT NULL;
+
+DROP INDEX IF EXISTS sessions_user_idx;
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS sessions(
+		sessionId INTEGER PRIMARY KEY AUTOINCREMENT,
+		tokenHash TEXT UNIQUE,
+		userId INTEGER,
+		createdAt DATETIME DEFAULT (datetime('now')),
+		lastSeenAt DATETIME DEFAULT (datetime('now')),
+		expiresAt DATETIME,
+		userAgent TEXT DEFAULT '',
+		ip TEXT DEFAULT '',
+		FOREIGN KEY (userId) REFERENCES user(userId) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions(userId);
+
+-- the single session of the user row is replaced, its plain token is not
+-- carried over so everyone signs in once again
+ALTER TABLE user DROP COLUMN token;
+ALTER TABLE user DROP COLUMN expiresAt;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS likes(
+		likeId INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT,
+		postId INTEGER DEFAULT NULL,
+		commentsId INTEGER DEFAULT NULL,
+		FOREIGN KEY (postId) REFERENCES posts(postId) ON DELETE CASCADE,
+		FOREIGN KEY (commentsId) REFERENCES comments(commentsId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS dislikes(
+		dislikeId INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT,
+		postId INTEGER DEFAULT NULL,
+		commentsId INTEGER DEFAULT NULL,
+		FOREIGN KEY (postId) REFERENCES posts(postId) ON DELETE CASCADE,
+		FOREIGN KEY (commentsId) REFERENCES comments(commentsId) ON DELETE CASCADE
+);
+
+INSERT INTO likes (username, postId) SELECT username, targetId FROM votes WHERE targetType = 'post' AND value = 1;
+INSERT INTO likes (username, commentsId) SELECT username, targetId FROM votes WHERE targetType = 'comment' AND value = 1;
+INSERT INTO dislikes (username, postId) SELECT username, targetId FROM votes WHERE targetType = 'post' AND value = -1;
+INSERT INTO dislikes (username, commentsId) SELECT username, targetId FROM votes WHERE targetType = 'comment' AND value = -1;
+
+DROP TRIGGER IF EXISTS votes_after_update;
+DROP TRIGGER IF EXISTS votes_after_delete;
+DROP TRIGGER IF EXISTS votes_after_insert;
+
+DROP INDEX IF EXISTS votes_target_idx;
+DROP INDEX IF EXISTS votes_user_target_idx;
+
+DROP TABLE IF EXISTS votes;
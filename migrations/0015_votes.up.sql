@@ -0,0 +1,63 @@
+-- one row per user and target replaces the likes and dislikes tables, the
+-- unique index makes a second vote of the same user impossible
+CREATE TABLE IF NOT EXISTS votes(
+		voteId INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		targetType TEXT NOT NULL CHECK (targetType IN ('post', 'comment')),
+		targetId INTEGER NOT NULL,
+		value INTEGER NOT NULL CHECK (value IN (-1, 1)),
+		createdAt DATETIME DEFAULT (datetime('now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS votes_user_target_idx ON votes(username, targetType, targetId);
+CREATE INDEX IF NOT EXISTS votes_target_idx ON votes(targetType, targetId, value);
+
+-- a user who somehow has both a like and a dislike keeps the like
+INSERT OR IGNORE INTO votes (username, targetType, targetId, value)
+	SELECT username, 'post', postId, 1 FROM likes WHERE postId IS NOT NULL AND username IS NOT NULL;
+INSERT OR IGNORE INTO votes (username, targetType, targetId, value)
+	SELECT username, 'comment', commentsId, 1 FROM likes WHERE commentsId IS NOT NULL AND username IS NOT NULL;
+INSERT OR IGNORE INTO votes (username, targetType, targetId, value)
+	SELECT username, 'post', postId, -1 FROM dislikes WHERE postId IS NOT NULL AND username IS NOT NULL;
+INSERT OR IGNORE INTO votes (username, targetType, targetId, value)
+	SELECT username, 'comment', commentsId, -1 FROM dislikes WHERE commentsId IS NOT NULL AND username IS NOT NULL;
+
+-- the counters on posts and comments follow the vote rows
+CREATE TRIGGER IF NOT EXISTS votes_after_insert AFTER INSERT ON votes
+BEGIN
+	UPDATE posts SET likes = likes + (NEW.value = 1), dislikes = dislikes + (NEW.value = -1)
+		WHERE NEW.targetType = 'post' AND postId = NEW.targetId;
+	UPDATE comments SET likes = likes + (NEW.value = 1), dislikes = dislikes + (NEW.value = -1)
+		WHERE NEW.targetType = 'comment' AND commentsId = NEW.targetId;
+END;
+
+CREATE TRIGGER IF NOT EXISTS votes_after_delete AFTER DELETE ON votes
+BEGIN
+	UPDATE posts SET likes = likes - (OLD.value = 1), dislikes = dislikes - (OLD.value = -1)
+		WHERE OLD.targetType = 'post' AND postId = OLD.targetId;
+	UPDATE comments SET likes = likes - (OLD.value = 1), dislikes = dislikes - (OLD.value = -1)
+		WHERE OLD.targetType = 'comment' AND commentsId = OLD.targetId;
+END;
+
+CREATE TRIGGER IF NOT EXISTS votes_after_update AFTER UPDATE OF value ON votes
+BEGIN
+	UPDATE posts SET
+		likes = likes - (OLD.value = 1) + (NEW.value = 1),
+		dislikes = dislikes - (OLD.value = -1) + (NEW.value = -1)
+		WHERE NEW.targetType = 'post' AND postId = NEW.targetId;
+	UPDATE comments SET
+		likes = likes - (OLD.value = 1) + (NEW.value = 1),
+		dislikes = dislikes - (OLD.value = -1) + (NEW.value = -1)
+		WHERE NEW.targetType = 'comment' AND commentsId = NEW.targetId;
+END;
+
+-- start from counters that match the rows
+UPDATE posts SET
+	likes = (SELECT COUNT(*) FROM votes WHERE targetType = 'post' AND targetId = posts.postId AND value = 1),
+	dislikes = (SELECT COUNT(*) FROM votes WHERE targetType = 'post' AND targetId = posts.postId AND value = -1);
+UPDATE comments SET
+	likes = (SELECT COUNT(*) FROM votes WHERE targetType = 'comment' AND targetId = comments.commentsId AND value = 1),
+	dislikes = (SELECT COUNT(*) FROM votes WHERE targetType = 'comment' AND targetId = comments.commentsId AND value = -1);
+
+DROP TABLE IF EXISTS likes;
+DROP TABLE IF EXISTS dislikes;
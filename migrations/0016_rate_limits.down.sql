@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS rate_limits_expires_idx;
+
+DROP TABLE IF EXISTS rate_limits;
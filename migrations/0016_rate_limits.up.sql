@@ -0,0 +1,13 @@
+-- token buckets and lockouts of the sign-in and sign-up limiter when
+-- rateLimitStore is "sqlite", times are UTC text with microseconds
+CREATE TABLE IF NOT EXISTS rate_limits(
+		key TEXT PRIMARY KEY,
+		tokens REAL NOT NULL DEFAULT 0,
+		updatedAt TEXT NOT NULL DEFAULT '',
+		failures INTEGER NOT NULL DEFAULT 0,
+		failedAt TEXT NOT NULL DEFAULT '',
+		lockedUntil TEXT NOT NULL DEFAULT '',
+		expiresAt TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_expires_idx ON rate_limits(expiresAt);
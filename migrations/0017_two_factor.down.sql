@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS recovery_codes_user_idx;
+
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS two_factor;
@@ -0,0 +1,21 @@
+-- the TOTP secret of a user, it is only asked for at sign-in once confirmedAt
+-- is set. lastStep is the time step of the last accepted code so no code
+-- works twice.
+CREATE TABLE IF NOT EXISTS two_factor(
+		userId INTEGER PRIMARY KEY,
+		secret TEXT NOT NULL,
+		createdAt DATETIME DEFAULT (datetime('now')),
+		confirmedAt DATETIME DEFAULT NULL,
+		lastStep INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (userId) REFERENCES user(userId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes(
+		codeId INTEGER PRIMARY KEY AUTOINCREMENT,
+		userId INTEGER NOT NULL,
+		codeHash TEXT NOT NULL,
+		usedAt DATETIME DEFAULT NULL,
+		FOREIGN KEY (userId) REFERENCES user(userId) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS recovery_codes_user_idx ON recovery_codes(userId, codeHash);
@@ -0,0 +1,7 @@
+ALTER TABLE user DROP COLUMN website;
+
+ALTER TABLE user DROP COLUMN avatar;
+
+ALTER TABLE user DROP COLUMN bio;
+
+ALTER TABLE user DROP COLUMN displayName;
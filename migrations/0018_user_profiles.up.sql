@@ -0,0 +1,7 @@
+ALTER TABLE user ADD COLUMN displayName TEXT DEFAULT '';
+
+ALTER TABLE user ADD COLUMN bio TEXT DEFAULT '';
+
+ALTER TABLE user ADD COLUMN avatar TEXT DEFAULT '';
+
+ALTER TABLE user ADD COLUMN website TEXT DEFAULT '';
@@ -0,0 +1,10 @@
+// Package migrations embeds the numbered sql files of the forum schema
+// so the binary always knows which schema version it was built for.
+package migrations
+
+import "embed"
+
+// FS holds every NNNN_name.up.sql and NNNN_name.down.sql file
+//
+//go:embed *.sql
+var FS embed.FS
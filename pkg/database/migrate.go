@@ -0,0 +1,251 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	ErrDatabaseNewer    = errors.New("database schema is newer than the binary")
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrInvalidSteps     = errors.New("steps must be at least 1")
+)
+
+// migration file names look like 0002_add_index.up.sql / 0002_add_index.down.sql
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a known migration was applied to the database
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// LoadMigrations reads all migration files from fsys sorted by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("load migrations: read dir: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("load migrations: %s: %w", entry.Name(), ErrInvalidMigration)
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("load migrations: read file: %w", err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("load migrations: version %d has two names: %w", version, ErrInvalidMigration)
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("load migrations: version %d has no up file: %w", m.Version, ErrInvalidMigration)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrate applies every pending up migration, each one in its own transaction.
+// It refuses to run when the database was migrated by a newer binary.
+func Migrate(db *sql.DB, fsys fs.FS) error {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	if err := checkNotNewer(applied, migrations); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		if err := runMigration(db, m.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2);`, m.Version, m.Name); err != nil {
+			return fmt.Errorf("migrate: up %04d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Rollback reverts the last steps applied migrations using their down files
+func Rollback(db *sql.DB, fsys fs.FS, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("rollback: %d: %w", steps, ErrInvalidSteps)
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return err
+	}
+
+	if err := checkNotNewer(applied, migrations); err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+		if m.Down == "" {
+			return fmt.Errorf("rollback: %04d_%s has no down file: %w", m.Version, m.Name, ErrInvalidMigration)
+		}
+
+		if err := runMigration(db, m.Down, `DELETE FROM schema_migrations WHERE version = $1;`, m.Version); err != nil {
+			return fmt.Errorf("rollback: down %04d_%s: %w", m.Version, m.Name, err)
+		}
+		steps--
+	}
+
+	return nil
+}
+
+// Status lists known migrations and whether each one is applied
+func Status(db *sql.DB, fsys fs.FS) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		statuses = append(statuses, MigrationStatus{Migration: m, Applied: applied[m.Version]})
+	}
+
+	return statuses, checkNotNewer(applied, migrations)
+}
+
+// appliedVersions creates the bookkeeping table if needed and returns applied versions
+func appliedVersions(db *sql.DB) (map[int]bool, error) {
+	query := `CREATE TABLE IF NOT EXISTS schema_migrations(
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		appliedAt DATETIME DEFAULT (datetime('now'))
+	);`
+	if _, err := db.Exec(query); err != nil {
+		return nil, fmt.Errorf("applied versions: create table: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT version FROM schema_migrations;`)
+	if err != nil {
+		return nil, fmt.Errorf("applied versions: query: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("applied versions: scan: %w", err)
+		}
+		applied[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("applied versions: rows error: %w", err)
+	}
+
+	return applied, nil
+}
+
+func checkNotNewer(applied map[int]bool, migrations []Migration) error {
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("database has version %d: %w", version, ErrDatabaseNewer)
+		}
+	}
+
+	return nil
+}
+
+// runMigration executes the migration script and the bookkeeping query in one transaction
+func runMigration(db *sql.DB, script, bookkeeping string, args ...interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("exec: %w", err)
+	}
+
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("bookkeeping: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	return nil
+}
@@ -2,8 +2,6 @@ package database
 
 import (
 	"database/sql"
-	"fmt"
-	"os"
 
 	"forum/config"
 
@@ -23,15 +21,3 @@ func InitDB(cfg *config.Config) (*sql.DB, error) {
 
 	return db, nil
 }
-
-// CreateTables executes all tables for forum
-func CreateTables(db *sql.DB) error {
-	migrationData, err := os.ReadFile("./migrations/up.sql")
-	if err != nil {
-		return fmt.Errorf("create tables: read file: %w", err)
-	}
-	if _, err = db.Exec(string(migrationData)); err != nil {
-		return fmt.Errorf("db.Exec: %w", err)
-	}
-	return nil
-}
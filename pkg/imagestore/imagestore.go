@@ -0,0 +1,209 @@
+// Package imagestore keeps uploaded images on disk under the hash of their content
+// and makes a small thumbnail for each of them.
+package imagestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register the gif decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrTooLarge    = errors.New("image is too large")
+	ErrUnsupported = errors.New("unsupported image type")
+)
+
+const (
+	// ThumbnailSize is the longest side of a thumbnail in pixels
+	ThumbnailSize = 320
+	// maxPixels guards against small files that decode into huge images
+	maxPixels = 40 * 1000 * 1000
+)
+
+// extensions of the accepted types, keyed by the sniffed content type
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image describes a stored image, file names are relative to the store directory
+type Image struct {
+	Filename    string
+	Thumbnail   string
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+
+	// data and thumb are the files of a loaded image until Write
+	data, thumb []byte
+}
+
+type Store struct {
+	dir     string
+	maxSize int64
+}
+
+// New returns a store writing to dir, the directory is created on the first save
+func New(dir string, maxSize int64) *Store {
+	return &Store{
+		dir:     dir,
+		maxSize: maxSize,
+	}
+}
+
+// Dir is the directory images are stored in
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Load reads an image of at most the store's size limit from r and makes its
+// thumbnail, nothing is written yet. The type is sniffed from the content,
+// the client supplied name and type are not trusted.
+func (s *Store) Load(r io.Reader) (Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return Image{}, fmt.Errorf("imagestore: read: %w", err)
+	}
+	if int64(len(data)) > s.maxSize {
+		return Image{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return Image{}, fmt.Errorf("imagestore: %s: %w", contentType, ErrUnsupported)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || "image/"+format != contentType {
+		return Image{}, fmt.Errorf("imagestore: decode config: %w", ErrUnsupported)
+	}
+	if config.Width*config.Height > maxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("imagestore: decode: %w", ErrUnsupported)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	img := Image{
+		Filename:    hash + ext,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       config.Width,
+		Height:      config.Height,
+		data:        data,
+	}
+
+	// jpeg thumbnails stay jpeg, the others keep transparency as png
+	var thumb bytes.Buffer
+	if contentType == "image/jpeg" {
+		img.Thumbnail = hash + "_thumb.jpg"
+		err = jpeg.Encode(&thumb, thumbnail(src, ThumbnailSize), &jpeg.Options{Quality: 85})
+	} else {
+		img.Thumbnail = hash + "_thumb.png"
+		err = png.Encode(&thumb, thumbnail(src, ThumbnailSize))
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("imagestore: encode thumbnail: %w", err)
+	}
+	img.thumb = thumb.Bytes()
+
+	return img, nil
+}
+
+// Write stores a loaded image and its thumbnail. Saving the same content
+// twice stores it once.
+func (s *Store) Write(img Image) error {
+	if img.data == nil || img.thumb == nil {
+		return errors.New("imagestore: write: image is not loaded")
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("imagestore: create dir: %w", err)
+	}
+	if err := s.write(img.Filename, img.data); err != nil {
+		return err
+	}
+
+	return s.write(img.Thumbnail, img.thumb)
+}
+
+// WriteThumbnail stores only the thumbnail of a loaded image, for avatars
+// and other uses that never show the full upload
+func (s *Store) WriteThumbnail(img Image) error {
+	if img.thumb == nil {
+		return errors.New("imagestore: write thumbnail: image is not loaded")
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("imagestore: create dir: %w", err)
+	}
+
+	return s.write(img.Thumbnail, img.thumb)
+}
+
+// Remove deletes stored files, names missing from the store are skipped.
+// Files are shared by everything with the same content, callers remove only
+// the names nothing refers to any more.
+func (s *Store) Remove(names ...string) error {
+	for _, name := range names {
+		if name == "" || name != filepath.Base(name) {
+			return fmt.Errorf("imagestore: remove %q: invalid name", name)
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("imagestore: remove: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// write stores data under name unless a file with that name exists already,
+// content addressed names make an existing file identical
+func (s *Store) write(name string, data []byte) error {
+	path := filepath.Join(s.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("imagestore: create temp: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("imagestore: write: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("imagestore: close: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("imagestore: chmod: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("imagestore: rename: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._@-]`)
+
+// FileMailer stores every message as an .eml file in a directory instead of
+// sending it, or writes it to the log when no directory is set. It is meant
+// for local development and testing.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir, from string) *FileMailer {
+	return &FileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	now := time.Now()
+	data, err := build(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	if m.dir == "" {
+		log.Printf("mailer: message to %s:\n%s", msg.To, data)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("mailer: create dir: %w", err)
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", now.UTC().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	if err := os.WriteFile(filepath.Join(m.dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("mailer: write message: %w", err)
+	}
+
+	return nil
+}
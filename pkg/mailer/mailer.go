@@ -0,0 +1,57 @@
+// Package mailer sends the plain text emails of the forum, such as email
+// verification and password reset links, through SMTP or into local files.
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Message is one plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages, From is set by the implementation
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// build renders msg as an RFC 5322 message with a quoted-printable UTF-8 body
+func build(from string, msg Message, now time.Time) ([]byte, error) {
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return nil, fmt.Errorf("mailer: recipient %q: %w", msg.To, ErrInvalidMessage)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("mailer: subject: %w", ErrInvalidMessage)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	if _, err := w.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, fmt.Errorf("mailer: encode body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("mailer: encode body: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
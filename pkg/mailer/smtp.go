@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP server. The connection is
+// upgraded with STARTTLS when the server offers it, credentials are only
+// sent over TLS or to localhost.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTP returns a mailer for the server at host:port, username may be empty
+// for servers that do not require authentication
+func NewSMTP(host string, port int, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(host, fmt.Sprint(port)),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := build(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("mailer: smtp send: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// renderInline writes text with code spans, emphasis, strikethrough and links
+func renderInline(b *strings.Builder, text string) {
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			b.WriteString(escape(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if n := codeSpan(b, text[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if n := emphasis(b, text, i); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '[':
+			if n := link(b, text[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if n := autolink(b, text[i:]); n > 0 {
+				i += n
+				continue
+			}
+
+		case c == '\n':
+			b.WriteString("\n")
+			i++
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteString(escape(text[i : i+size]))
+		i += size
+	}
+}
+
+// codeSpan writes a `code` span at the start of text and returns the number
+// of bytes it consumed, 0 when the backticks are not closed
+func codeSpan(b *strings.Builder, text string) int {
+	ticks := 0
+	for ticks < len(text) && text[ticks] == '`' {
+		ticks++
+	}
+
+	// the closing run must have exactly the same length
+	for j := ticks; j < len(text); {
+		if text[j] != '`' {
+			j++
+			continue
+		}
+		k := j
+		for k < len(text) && text[k] == '`' {
+			k++
+		}
+		if k-j != ticks {
+			j = k
+			continue
+		}
+
+		code := text[ticks:j]
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+			code = code[1 : len(code)-1]
+		}
+		b.WriteString("<code>")
+		b.WriteString(escape(code))
+		b.WriteString("</code>")
+		return k
+	}
+
+	return 0
+}
+
+// emphasis writes *em*, **strong** or ~~del~~ starting at text[i] and returns
+// the number of bytes it consumed, 0 when there is no matching closing run
+func emphasis(b *strings.Builder, text string, i int) int {
+	c := text[i]
+	run := 1
+	if i+1 < len(text) && text[i+1] == c {
+		run = 2
+	}
+	if c == '~' && run != 2 {
+		return 0
+	}
+
+	delim := text[i : i+run]
+	start := i + run
+	// the opening run must be followed by text, and underscores inside a
+	// word such as snake_case are not emphasis
+	if start >= len(text) || isSpace(text[start]) {
+		return 0
+	}
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return 0
+	}
+
+	for search := start; search < len(text); {
+		end := strings.Index(text[search:], delim)
+		if end < 0 {
+			return 0
+		}
+		end += search
+		search = end + 1
+
+		if end == start || isSpace(text[end-1]) || text[end-1] == c || text[end-1] == '\\' {
+			continue
+		}
+		after := end + run
+		if after < len(text) && text[after] == c {
+			// part of a longer run, e.g. the closing ** of a nested *em*
+			continue
+		}
+		if c == '_' && after < len(text) && isWordByte(text[after]) {
+			continue
+		}
+
+		tag := "em"
+		switch {
+		case c == '~':
+			tag = "del"
+		case run == 2:
+			tag = "strong"
+		}
+		b.WriteString("<" + tag + ">")
+		renderInline(b, text[start:end])
+		b.WriteString("</" + tag + ">")
+		return after - i
+	}
+
+	return 0
+}
+
+// link writes [text](url) at the start of text and returns the number of
+// bytes it consumed. A link to a disallowed URL is written as plain text.
+func link(b *strings.Builder, text string) int {
+	depth := 0
+	closeBracket := -1
+	for i := 0; i < len(text) && closeBracket < 0; i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeBracket = i
+			}
+		}
+	}
+	if closeBracket < 0 || closeBracket+1 >= len(text) || text[closeBracket+1] != '(' {
+		return 0
+	}
+
+	// parentheses inside the URL must be balanced
+	closeParen := -1
+	depth = 0
+	for i := closeBracket + 1; i < len(text) && closeParen < 0; i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				closeParen = i
+			}
+		}
+	}
+	if closeParen < 0 {
+		return 0
+	}
+
+	label := text[1:closeBracket]
+	target := strings.TrimSpace(text[closeBracket+2 : closeParen])
+	if strings.ContainsAny(target, " \t\n") {
+		return 0
+	}
+
+	href, ok := safeURL(target)
+	if !ok {
+		renderInline(b, label)
+		return closeParen + 1
+	}
+
+	b.WriteString(`<a href="` + escape(href) + `" rel="nofollow noopener noreferrer">`)
+	renderInline(b, label)
+	b.WriteString("</a>")
+	return closeParen + 1
+}
+
+// autolink writes <https://example.com> at the start of text as a link
+func autolink(b *strings.Builder, text string) int {
+	end := strings.IndexByte(text, '>')
+	if end < 0 {
+		return 0
+	}
+	target := text[1:end]
+	if strings.ContainsAny(target, " \t\n<") {
+		return 0
+	}
+	href, ok := safeURL(target)
+	if !ok || strings.HasPrefix(href, "/") || strings.HasPrefix(href, "#") {
+		return 0
+	}
+
+	b.WriteString(`<a href="` + escape(href) + `" rel="nofollow noopener noreferrer">`)
+	b.WriteString(escape(target))
+	b.WriteString("</a>")
+	return end + 1
+}
+
+// safeURL allows absolute http, https and mailto URLs and paths or fragments
+// on this site, everything else (javascript:, data:, protocol relative //host)
+// is rejected
+func safeURL(raw string) (string, bool) {
+	if raw == "" {
+		return "", false
+	}
+	for _, r := range raw {
+		if r < 0x21 || r == 0x7f || unicode.IsControl(r) {
+			return "", false
+		}
+	}
+
+	lower := strings.ToLower(raw)
+	for _, scheme := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) && len(raw) > len(scheme) {
+			return raw, true
+		}
+	}
+	if raw[0] == '#' || (raw[0] == '/' && !strings.HasPrefix(raw, "//") && !strings.HasPrefix(raw, "/\\")) {
+		return raw, true
+	}
+
+	return "", false
+}
+
+var escaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+// escape makes text safe both as element content and as a quoted attribute value
+func escape(text string) string {
+	return escaper.Replace(text)
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c >= 0x80 || isAlnum(rune(c))
+}
+
+func isAlnum(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
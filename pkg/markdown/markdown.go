@@ -0,0 +1,273 @@
+// Package markdown renders the Markdown subset used by forum posts and
+// comments: headings, emphasis, inline code, code fences, links, lists,
+// block quotes and horizontal rules.
+//
+// The output is safe to embed in a page as it is. Every piece of source text
+// is HTML-escaped, raw HTML in the source is shown literally instead of being
+// passed through, only the tags written by the renderer itself appear in the
+// result and links are kept only for allowed URL schemes.
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Render converts Markdown source to sanitized HTML
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return b.String()
+}
+
+// renderBlocks writes the block elements found in lines
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case isFence(trimmed):
+			i = renderFence(b, lines, i)
+
+		case headingLevel(trimmed) > 0:
+			level := headingLevel(trimmed)
+			text := strings.TrimSpace(trimmed[level:])
+			text = strings.TrimSpace(strings.TrimRight(text, "#"))
+			tag := "h" + strconv.Itoa(level)
+			b.WriteString("<" + tag + ">")
+			renderInline(b, text)
+			b.WriteString("</" + tag + ">\n")
+			i++
+
+		case isRule(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines); i++ {
+				line := strings.TrimLeft(lines[i], " ")
+				if !strings.HasPrefix(line, ">") {
+					break
+				}
+				line = strings.TrimPrefix(line, ">")
+				quoted = append(quoted, strings.TrimPrefix(line, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case listMarker(lines[i]) != nil:
+			i = renderList(b, lines, i)
+
+		default:
+			i = renderParagraph(b, lines, i)
+		}
+	}
+}
+
+// isFence reports whether the line opens or closes a fenced code block
+func isFence(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// renderFence writes the code block starting at lines[i] and returns the
+// index of the first line after it. An unclosed fence runs to the end.
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	open := strings.TrimSpace(lines[i])
+	fence := open[:3]
+	lang := codeLanguage(strings.TrimSpace(strings.TrimLeft(open, fence[:1])))
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + lang + `"`)
+	}
+	b.WriteString(">")
+	b.WriteString(escape(strings.Join(code, "\n")))
+	b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// codeLanguage keeps the first word of a fence info string when it looks
+// like a language name, anything else is dropped
+func codeLanguage(info string) string {
+	if fields := strings.Fields(info); len(fields) > 0 {
+		info = fields[0]
+	}
+	if len(info) > 20 {
+		return ""
+	}
+	for _, r := range info {
+		if !isAlnum(r) && r != '-' && r != '+' && r != '_' && r != '#' {
+			return ""
+		}
+	}
+	return info
+}
+
+// headingLevel returns 1-6 for an ATX heading line and 0 otherwise
+func headingLevel(trimmed string) int {
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0
+	}
+	if level < len(trimmed) && trimmed[level] != ' ' && trimmed[level] != '\t' {
+		return 0
+	}
+	return level
+}
+
+// isRule reports whether the line is a horizontal rule such as --- or * * *
+func isRule(trimmed string) bool {
+	if len(trimmed) < 3 {
+		return false
+	}
+	marker := trimmed[0]
+	if marker != '-' && marker != '*' && marker != '_' {
+		return false
+	}
+	count := 0
+	for i := 0; i < len(trimmed); i++ {
+		switch trimmed[i] {
+		case marker:
+			count++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+type marker struct {
+	ordered bool
+	start   int
+	indent  int // width of the indentation before the marker
+	width   int // width of the marker including the space after it
+}
+
+// listMarker parses a list item marker: "- ", "* ", "+ ", "1. " or "1) "
+func listMarker(line string) *marker {
+	indent := len(line) - len(strings.TrimLeft(line, " "))
+	rest := line[indent:]
+
+	if len(rest) >= 2 && (rest[0] == '-' || rest[0] == '*' || rest[0] == '+') && rest[1] == ' ' {
+		if isRule(strings.TrimSpace(rest)) {
+			return nil
+		}
+		return &marker{indent: indent, width: 2}
+	}
+
+	digits := 0
+	for digits < len(rest) && digits < 9 && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits+1 >= len(rest) {
+		return nil
+	}
+	if (rest[digits] != '.' && rest[digits] != ')') || rest[digits+1] != ' ' {
+		return nil
+	}
+	start, _ := strconv.Atoi(rest[:digits])
+	return &marker{ordered: true, start: start, indent: indent, width: digits + 2}
+}
+
+// renderList writes the list starting at lines[i] and returns the index of the
+// first line after it. Lines indented past the marker belong to the current
+// item, so nested lists are rendered inside it.
+func renderList(b *strings.Builder, lines []string, i int) int {
+	first := listMarker(lines[i])
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+
+	b.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		b.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	b.WriteString(">\n")
+
+	for i < len(lines) {
+		m := listMarker(lines[i])
+		if m == nil || m.ordered != first.ordered || m.indent > first.indent+1 {
+			break
+		}
+
+		text := strings.TrimSpace(lines[i][m.indent+m.width:])
+		content := m.indent + m.width
+		var nested []string
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			next := listMarker(line)
+			if indent < content && (next == nil || next.indent <= m.indent) {
+				if next != nil || len(nested) > 0 {
+					break
+				}
+				// lazy continuation of the item text
+				text += "\n" + strings.TrimSpace(line)
+				continue
+			}
+			if indent > content {
+				indent = content
+			}
+			nested = append(nested, line[indent:])
+		}
+
+		b.WriteString("<li>")
+		renderInline(b, text)
+		if len(nested) > 0 {
+			b.WriteString("\n")
+			renderBlocks(b, nested)
+		}
+		b.WriteString("</li>\n")
+	}
+
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderParagraph writes the paragraph starting at lines[i], it ends at a
+// blank line or at the start of another block
+func renderParagraph(b *strings.Builder, lines []string, i int) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" {
+			break
+		}
+		if len(text) > 0 && (isFence(trimmed) || headingLevel(trimmed) > 0 || isRule(trimmed) ||
+			strings.HasPrefix(trimmed, ">") || listMarker(lines[i]) != nil) {
+			break
+		}
+		text = append(text, trimmed)
+	}
+
+	b.WriteString("<p>")
+	renderInline(b, strings.Join(text, "\n"))
+	b.WriteString("</p>\n")
+	return i
+}
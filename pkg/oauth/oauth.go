@@ -0,0 +1,258 @@
+// Package oauth implements the OAuth2 authorization code flow with PKCE for
+// signing in with GitHub or an OpenID Connect provider such as Google.
+//
+// The identity of the user is read from the provider's user info endpoint
+// with the access token, so no ID token signatures have to be checked.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrExchange = errors.New("oauth exchange failed")
+
+// Endpoint holds the URLs of a provider. EmailsURL is only used by GitHub,
+// whose user endpoint leaves out private email addresses.
+type Endpoint struct {
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	EmailsURL   string
+}
+
+var (
+	GitHub = Endpoint{
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+	}
+	Google = Endpoint{
+		AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:    "https://oauth2.googleapis.com/token",
+		UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+	}
+)
+
+// DefaultScopes are requested when the configuration names none
+var DefaultScopes = map[string][]string{
+	"github": {"read:user", "user:email"},
+	"google": {"openid", "email", "profile"},
+}
+
+// Identity is the account of a user at a provider
+type Identity struct {
+	Provider      string
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+}
+
+// Provider is one configured OAuth2 provider. A provider named "github" reads
+// the GitHub user API, every other provider is treated as OpenID Connect.
+type Provider struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Endpoint     Endpoint
+
+	client *http.Client
+}
+
+func NewProvider(name, clientID, clientSecret, redirectURL string, scopes []string, endpoint Endpoint) *Provider {
+	return &Provider{
+		Name:         name,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		Endpoint:     endpoint,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL is where the user is sent to sign in, state and the PKCE
+// verifier come back to the callback through the caller
+func (p *Provider) AuthCodeURL(state, verifier string) string {
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"state":                 {state},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if len(p.Scopes) > 0 {
+		v.Set("scope", strings.Join(p.Scopes, " "))
+	}
+
+	sep := "?"
+	if strings.Contains(p.Endpoint.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.Endpoint.AuthURL + sep + v.Encode()
+}
+
+// Exchange trades the authorization code for an access token
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("oauth: exchange: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return "", fmt.Errorf("oauth: exchange: %w", err)
+	}
+	// GitHub reports errors with status 200
+	if token.Error != "" || token.AccessToken == "" {
+		return "", fmt.Errorf("oauth: exchange: %s: %w", token.Error, ErrExchange)
+	}
+
+	return token.AccessToken, nil
+}
+
+// Identity reads the signed in user from the provider
+func (p *Provider) Identity(ctx context.Context, accessToken string) (Identity, error) {
+	if p.Name == "github" {
+		return p.githubIdentity(ctx, accessToken)
+	}
+
+	var info struct {
+		Subject           string `json:"sub"`
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+		Name              string `json:"name"`
+	}
+	if err := p.get(ctx, p.Endpoint.UserInfoURL, accessToken, &info); err != nil {
+		return Identity{}, fmt.Errorf("oauth: user info: %w", err)
+	}
+	if info.Subject == "" {
+		return Identity{}, fmt.Errorf("oauth: user info: no subject: %w", ErrExchange)
+	}
+
+	username := info.PreferredUsername
+	if username == "" {
+		username = info.Name
+	}
+	return Identity{
+		Provider:      p.Name,
+		Subject:       info.Subject,
+		Username:      username,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+	}, nil
+}
+
+func (p *Provider) githubIdentity(ctx context.Context, accessToken string) (Identity, error) {
+	var user struct {
+		Id    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := p.get(ctx, p.Endpoint.UserInfoURL, accessToken, &user); err != nil {
+		return Identity{}, fmt.Errorf("oauth: github user: %w", err)
+	}
+	if user.Id == 0 {
+		return Identity{}, fmt.Errorf("oauth: github user: no id: %w", ErrExchange)
+	}
+
+	identity := Identity{
+		Provider: p.Name,
+		Subject:  strconv.FormatInt(user.Id, 10),
+		Username: user.Login,
+	}
+
+	if p.Endpoint.EmailsURL != "" {
+		var emails []struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}
+		if err := p.get(ctx, p.Endpoint.EmailsURL, accessToken, &emails); err != nil {
+			return Identity{}, fmt.Errorf("oauth: github emails: %w", err)
+		}
+		for _, e := range emails {
+			if e.Primary {
+				identity.Email, identity.EmailVerified = e.Email, e.Verified
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *Provider) get(ctx context.Context, endpoint, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	return p.do(req, v)
+}
+
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d: %w", req.URL.Path, resp.StatusCode, ErrExchange)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s: decode: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// RandomString returns a URL safe random string, used for state and PKCE verifiers
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge is the S256 PKCE code challenge of verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,147 @@
+// Package password hashes passwords with bcrypt or argon2id, checks a
+// password against any hash either of them made and tells when a hash was
+// made with other settings than the current ones, so it can be replaced at
+// the next sign-in. It also checks new passwords against a Policy.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match")
+	ErrUnknownHash   = errors.New("unknown password hash")
+	ErrInvalidConfig = errors.New("invalid password hasher config")
+)
+
+// Hasher makes the hashes of new passwords, Current tells whether hash was
+// made by it with its settings
+type Hasher interface {
+	Hash(password string) (string, error)
+	Current(hash string) bool
+}
+
+// Compare checks password against a hash of bcrypt or argon2id, it returns
+// ErrMismatch when they do not match or the hash is empty
+func Compare(password, hash string) error {
+	switch {
+	case hash == "":
+		return ErrMismatch
+	case strings.HasPrefix(hash, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return ErrMismatch
+			}
+			return err
+		}
+		return nil
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	default:
+		return ErrUnknownHash
+	}
+}
+
+// BcryptMaxBytes is the length of the part of a password bcrypt reads, the
+// rest makes no difference to the hash
+const BcryptMaxBytes = 72
+
+// Bcrypt hashes with bcrypt at Cost
+type Bcrypt struct {
+	Cost int
+}
+
+func NewBcrypt(cost int) (*Bcrypt, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, ErrInvalidConfig
+	}
+	return &Bcrypt{Cost: cost}, nil
+}
+
+func (b *Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b *Bcrypt) Current(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == b.Cost
+}
+
+// Argon2id hashes with argon2id, Memory is in KiB. Hashes are stored in the
+// PHC string format: $argon2id$v=19$m=65536,t=3,p=2$salt$key
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+func NewArgon2id(time, memory uint32, threads uint8) (*Argon2id, error) {
+	if time == 0 || threads == 0 || memory < 8*uint32(threads) {
+		return nil, ErrInvalidConfig
+	}
+	return &Argon2id{Time: time, Memory: memory, Threads: threads}, nil
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, a.Memory, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (a *Argon2id) Current(hash string) bool {
+	params, _, key, err := decodeArgon2id(hash)
+	return err == nil && params == *a && len(key) == argon2KeyLen
+}
+
+// decodeArgon2id splits a hash made by Argon2id.Hash into its parts
+func decodeArgon2id(hash string) (params Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2id{}, nil, nil, ErrUnknownHash
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil || params.Time == 0 || params.Threads == 0 {
+		return Argon2id{}, nil, nil, ErrUnknownHash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return Argon2id{}, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, ErrUnknownHash
+	}
+
+	return params, salt, key, nil
+}
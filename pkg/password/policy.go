@@ -0,0 +1,110 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeak = errors.New("password does not meet the policy")
+
+// WeakError tells which rule of the policy a new password breaks, Reason
+// reads after "the password", e.g. "must contain a number"
+type WeakError struct {
+	Reason string
+}
+
+func (e *WeakError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrWeak, e.Reason)
+}
+
+func (e *WeakError) Unwrap() error {
+	return ErrWeak
+}
+
+// Policy is what a new password must look like. Length is counted in
+// characters, MaxBytes bounds the UTF-8 length for hashers that only read so
+// many bytes. Common holds lower case passwords that are refused in any case.
+type Policy struct {
+	MinLength     int
+	MaxLength     int
+	MaxBytes      int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Common        map[string]bool
+}
+
+// Check returns a *WeakError for the first rule password breaks
+func (p *Policy) Check(password string) error {
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsNumber(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			symbol = true
+		case unicode.IsControl(c):
+			return &WeakError{Reason: "must not contain control characters"}
+		}
+	}
+
+	length := utf8.RuneCountInString(password)
+	switch {
+	case length < p.MinLength:
+		return &WeakError{Reason: fmt.Sprintf("must be at least %d characters long", p.MinLength)}
+	case p.MaxLength > 0 && length > p.MaxLength:
+		return &WeakError{Reason: fmt.Sprintf("must be at most %d characters long", p.MaxLength)}
+	case p.MaxBytes > 0 && len(password) > p.MaxBytes:
+		return &WeakError{Reason: fmt.Sprintf("must be at most %d bytes long, accented letters and emoji take more than one", p.MaxBytes)}
+	case p.RequireUpper && !upper:
+		return &WeakError{Reason: "must contain an upper case letter"}
+	case p.RequireLower && !lower:
+		return &WeakError{Reason: "must contain a lower case letter"}
+	case p.RequireDigit && !digit:
+		return &WeakError{Reason: "must contain a number"}
+	case p.RequireSymbol && !symbol:
+		return &WeakError{Reason: "must contain a special symbol"}
+	case p.Common[strings.ToLower(password)]:
+		return &WeakError{Reason: "is too common, choose another one"}
+	}
+
+	return nil
+}
+
+// ReadList reads a list of common or breached passwords, one per line,
+// blank lines and lines starting with # are skipped
+func ReadList(r io.Reader) (map[string]bool, error) {
+	list := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = true
+	}
+
+	return list, scanner.Err()
+}
+
+// LoadList reads the list in the file at path
+func LoadList(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadList(f)
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import "sync"
+
+// sweepEvery is how many updates the memory store takes between two passes
+// that drop expired keys
+const sweepEvery = 1024
+
+// Memory keeps the state in the process, it is lost on restart and not
+// shared between several instances of the forum
+type Memory struct {
+	mu      sync.Mutex
+	states  map[string]State
+	updates int
+}
+
+func NewMemory() *Memory {
+	return &Memory{states: make(map[string]State)}
+}
+
+func (m *Memory) Update(key string, fn func(s *State)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.states[key]
+	fn(&s)
+	m.states[key] = s
+
+	m.updates++
+	if m.updates%sweepEvery == 0 {
+		// s.Updated is the time of the limiter, the store has no clock of its own
+		for k, other := range m.states {
+			if other.Expires.Before(s.Updated) {
+				delete(m.states, k)
+			}
+		}
+	}
+
+	return nil
+}
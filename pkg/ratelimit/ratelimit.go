@@ -0,0 +1,147 @@
+// Package ratelimit throttles attempts per key, such as an IP address or a
+// username, with a token bucket and locks a key out for a while after
+// repeated failures. The state lives in memory or in the SQLite database.
+package ratelimit
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidConfig = errors.New("invalid rate limit config")
+
+// State is what the limiter remembers about one key. A key whose Expires has
+// passed behaves like a key never seen, so a store may forget it.
+type State struct {
+	Tokens      float64
+	Updated     time.Time
+	Failures    int
+	FailedAt    time.Time
+	LockedUntil time.Time
+	Expires     time.Time
+}
+
+// Store keeps the state of every key, Update loads the state of key, zero
+// when unknown, lets fn change it and saves it without another Update of
+// the same key in between
+type Store interface {
+	Update(key string, fn func(s *State)) error
+}
+
+// Config of a limiter: Burst attempts are allowed at once and one more every
+// Every. After MaxFailures failures, each within Lockout of the previous one,
+// the key is locked for Lockout. MaxFailures 0 never locks.
+type Config struct {
+	Burst       int
+	Every       time.Duration
+	MaxFailures int
+	Lockout     time.Duration
+}
+
+type Limiter struct {
+	store Store
+	cnf   Config
+	now   func() time.Time
+}
+
+func New(store Store, cnf Config) (*Limiter, error) {
+	if cnf.Burst <= 0 || cnf.Every <= 0 || cnf.MaxFailures < 0 || (cnf.MaxFailures > 0 && cnf.Lockout <= 0) {
+		return nil, ErrInvalidConfig
+	}
+
+	return &Limiter{
+		store: store,
+		cnf:   cnf,
+		now:   time.Now,
+	}, nil
+}
+
+// Allow takes a token of key, when key is locked or has no token left it
+// takes nothing and returns how long to wait before the next attempt
+func (l *Limiter) Allow(key string) (time.Duration, error) {
+	var wait time.Duration
+
+	err := l.store.Update(key, func(s *State) {
+		now := l.now()
+		l.refill(s, now)
+
+		switch {
+		case now.Before(s.LockedUntil):
+			wait = s.LockedUntil.Sub(now)
+		case s.Tokens < 1:
+			wait = time.Duration((1 - s.Tokens) * float64(l.cnf.Every))
+		default:
+			s.Tokens--
+		}
+
+		l.expire(s)
+	})
+
+	return wait, err
+}
+
+// Fail counts a failed attempt of key and locks it on the last one allowed
+func (l *Limiter) Fail(key string) error {
+	if l.cnf.MaxFailures == 0 {
+		return nil
+	}
+
+	return l.store.Update(key, func(s *State) {
+		now := l.now()
+		l.refill(s, now)
+
+		s.Failures++
+		s.FailedAt = now
+		if s.Failures >= l.cnf.MaxFailures {
+			s.Failures = 0
+			s.LockedUntil = now.Add(l.cnf.Lockout)
+		}
+
+		l.expire(s)
+	})
+}
+
+// Reset forgets the failures of key after a successful attempt, a running
+// lockout stays
+func (l *Limiter) Reset(key string) error {
+	return l.store.Update(key, func(s *State) {
+		l.refill(s, l.now())
+
+		s.Failures = 0
+		s.FailedAt = time.Time{}
+
+		l.expire(s)
+	})
+}
+
+// refill adds the tokens earned since the last update and forgets failures
+// that are too old to count
+func (l *Limiter) refill(s *State, now time.Time) {
+	if s.Updated.IsZero() {
+		s.Tokens = float64(l.cnf.Burst)
+	} else if elapsed := now.Sub(s.Updated); elapsed > 0 {
+		s.Tokens += float64(elapsed) / float64(l.cnf.Every)
+		if s.Tokens > float64(l.cnf.Burst) {
+			s.Tokens = float64(l.cnf.Burst)
+		}
+	}
+	s.Updated = now
+
+	if s.Failures > 0 && now.Sub(s.FailedAt) >= l.cnf.Lockout {
+		s.Failures = 0
+		s.FailedAt = time.Time{}
+	}
+}
+
+// expire sets when the state is back to that of an unknown key
+func (l *Limiter) expire(s *State) {
+	missing := float64(l.cnf.Burst) - s.Tokens
+	s.Expires = s.Updated.Add(time.Duration(missing * float64(l.cnf.Every)))
+
+	if s.LockedUntil.After(s.Expires) {
+		s.Expires = s.LockedUntil
+	}
+	if s.Failures > 0 && s.FailedAt.Add(l.cnf.Lockout).After(s.Expires) {
+		s.Expires = s.FailedAt.Add(l.cnf.Lockout)
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// timeLayout sorts as text and keeps the fractions of a second the token
+// bucket needs
+const timeLayout = "2006-01-02 15:04:05.000000"
+
+// SQLite keeps the state in the rate_limits table so it survives a restart.
+// Updates are serialized within the process, several processes sharing the
+// database may now and then lose an update of the same key.
+type SQLite struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+func (q *SQLite) Update(key string, fn func(s *State)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return fmt.Errorf("ratelimit: begin %w", err)
+	}
+
+	var (
+		s                                       State
+		updated, failedAt, lockedUntil, expires string
+	)
+	query := `SELECT tokens, updatedAt, failures, failedAt, lockedUntil, expiresAt FROM rate_limits WHERE key = $1;`
+	err = tx.QueryRow(query, key).Scan(&s.Tokens, &updated, &s.Failures, &failedAt, &lockedUntil, &expires)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		tx.Rollback()
+		return fmt.Errorf("ratelimit: select %w", err)
+	default:
+		s.Updated = parseTime(updated)
+		s.FailedAt = parseTime(failedAt)
+		s.LockedUntil = parseTime(lockedUntil)
+		s.Expires = parseTime(expires)
+	}
+
+	fn(&s)
+
+	query = `INSERT INTO rate_limits (key, tokens, updatedAt, failures, failedAt, lockedUntil, expiresAt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (key) DO UPDATE SET tokens = excluded.tokens, updatedAt = excluded.updatedAt,
+		failures = excluded.failures, failedAt = excluded.failedAt,
+		lockedUntil = excluded.lockedUntil, expiresAt = excluded.expiresAt;`
+	_, err = tx.Exec(query, key, s.Tokens, formatTime(s.Updated), s.Failures,
+		formatTime(s.FailedAt), formatTime(s.LockedUntil), formatTime(s.Expires))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("ratelimit: save %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM rate_limits WHERE expiresAt < $1;`, formatTime(s.Updated)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("ratelimit: delete expired %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ratelimit: commit %w", err)
+	}
+
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
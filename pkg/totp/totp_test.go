@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, the last six of the eight digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %q, want %q", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	for _, secret := range []string{"", "not base32!"} {
+		if _, err := Code(secret, 1); err != ErrInvalidSecret {
+			t.Errorf("Code(%q) error = %v, want %v", secret, err, ErrInvalidSecret)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	code := func(offset int64) string {
+		c, err := Code(rfcSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(0), 1, step, true},
+		{"one step behind", code(-1), 1, step - 1, true},
+		{"one step ahead", code(1), 1, step + 1, true},
+		{"two steps behind", code(-2), 1, 0, false},
+		{"two steps ahead", code(2), 1, 0, false},
+		{"no skew, one step behind", code(-1), 0, 0, false},
+		{"no skew, current step", code(0), 0, step, true},
+		{"wrong code", "000000", 1, 0, false},
+		{"too short", code(0)[:5], 1, 0, false},
+		{"too long", code(0) + "0", 1, 0, false},
+		{"empty", "", 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := Validate(rfcSecret, tt.code, now, tt.skew)
+			if gotOK != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}